@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/bot"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/klines"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/recorder"
+	"os"
+	"time"
+)
+
+// snapshotFlags are the command line flags that select the snapshots to replay.
+type snapshotFlags struct {
+	useKlines  *bool
+	marketName *string
+	interval   *string
+	resolution *time.Duration
+	from       *string
+	to         *string
+}
+
+func addSnapshotFlags(flags *flag.FlagSet) snapshotFlags {
+	return snapshotFlags{
+		useKlines:  flags.Bool("klines", false, "replay imported klines instead of recordings"),
+		marketName: flags.String("market", "binance", "name of the market the imported klines belong to"),
+		interval:   flags.String("interval", "1m", "interval of the imported klines to replay"),
+		resolution: flags.Duration("resolution", time.Minute, "time between two snapshots of imported klines"),
+		from:       flags.String("from", "", "only replay imported klines from this date onwards (e.g. 2024-01-01)"),
+		to:         flags.String("to", "", "only replay imported klines up to this date (e.g. 2024-02-01)"),
+	}
+}
+
+// load loads the snapshots from either the imported klines or the given recordings.
+func (f snapshotFlags) load(c config.Configuration, recordings []string) ([]market.Snapshot, error) {
+	if !*f.useKlines {
+		return loadRecordings(recordings)
+	}
+
+	from, err := parseDate(*f.from)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseDate(*f.to)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.NewSqliteDatabase(klinesDatabaseFileName, c.LoggingOptions)
+
+	return klines.Load(db, *f.marketName, *f.interval, *f.resolution, from, to)
+}
+
+// backtest replays recorded snapshots through the bot and prints a summary of the results.
+func backtest(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("backtest", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: voltra backtest [-config config.yml] recording%s...\n", recorder.FileExtension)
+		_, _ = fmt.Fprintln(flags.Output(), "       voltra backtest [-config config.yml] -klines [-resolution 1m] [-from date] [-to date]")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "path to the config file with the trading options to test")
+	snapshotOptions := addSnapshotFlags(flags)
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 && !*snapshotOptions.useKlines {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := loadConfig(*configPath)
+
+	snapshots, err := snapshotOptions.load(c, flags.Args())
+	if err != nil {
+		panic(fmt.Errorf("failed to load snapshots: %w", err))
+	}
+
+	report, err := bot.Backtest(ctx, c, snapshots)
+	if err != nil {
+		panic(fmt.Errorf("failed to run backtest: %w", err))
+	}
+
+	_ = report.Print(os.Stdout)
+}
+
+// loadRecordings reads all snapshots from the given recordings.
+func loadRecordings(paths []string) ([]market.Snapshot, error) {
+	var snapshots []market.Snapshot
+	for _, path := range paths {
+		s, err := recorder.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		snapshots = append(snapshots, s...)
+	}
+	return snapshots, nil
+}
+
+// parseDate parses a date or date-time given on the command line.
+// An empty string results in a zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
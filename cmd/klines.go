@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/klines"
+	"os"
+)
+
+// klinesDatabaseFileName is the name of the database that stores imported klines.
+// It's kept separate from the trading database because it can grow very large.
+const klinesDatabaseFileName = "klines.db"
+
+// importKlines imports kline CSV dumps into the local klines database.
+func importKlines(args []string) {
+	flags := flag.NewFlagSet("import-klines", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(flags.Output(), "Usage: voltra import-klines [-symbol BTCUSDT] [-interval 1m] file.csv|file.zip...")
+		flags.PrintDefaults()
+	}
+	symbol := flags.String("symbol", "", "symbol of the klines (derived from the file name by default, e.g. BTCUSDT-1m-2024-01.zip)")
+	interval := flags.String("interval", "", "interval of the klines (derived from the file name by default, e.g. BTCUSDT-1m-2024-01.zip)")
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	db := database.NewSqliteDatabase(klinesDatabaseFileName, config.LoggingOptions{})
+
+	for _, path := range flags.Args() {
+		s := *symbol
+		if s == "" {
+			s = klines.SymbolFromFileName(path)
+		}
+
+		i := *interval
+		if i == "" {
+			i = klines.IntervalFromFileName(path)
+		}
+		if i == "" {
+			panic(fmt.Errorf("failed to derive the interval of %s, please set it with -interval", path))
+		}
+
+		k, err := klines.ParseFile(path, s, i)
+		if err != nil {
+			panic(fmt.Errorf("failed to parse %s: %w", path, err))
+		}
+
+		if err = db.SaveKlines(k); err != nil {
+			panic(fmt.Errorf("failed to save klines of %s: %w", path, err))
+		}
+
+		fmt.Printf("Imported %d %s klines of %s from %s.\n", len(k), i, s, path)
+	}
+}
@@ -4,22 +4,149 @@ import (
 	"context"
 	"fmt"
 	"github.com/sleeyax/voltra/internal/bot"
+	"github.com/sleeyax/voltra/internal/clock"
 	"github.com/sleeyax/voltra/internal/config"
 	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/database/models"
 	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/recorder"
+	"github.com/sleeyax/voltra/internal/utils"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"time"
 )
 
+// databaseFileName is the name of the database file with the orders of the bot.
+const databaseFileName = "voltra.db"
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	c, err := config.Load()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backtest":
+			backtest(ctx, os.Args[2:])
+			return
+		case "sweep":
+			sweep(ctx, os.Args[2:])
+			return
+		case "reconcile":
+			reconcile(ctx, os.Args[2:])
+			return
+		case "walk-forward":
+			walkForward(ctx, os.Args[2:])
+			return
+		case "import-klines":
+			importKlines(os.Args[2:])
+			return
+		}
+	}
+
+	c := loadConfig()
+	db := database.NewSqliteDatabase(databaseFileName, c.LoggingOptions)
+
+	// Each market is traded by its own bot. They share the database, in which all orders are keyed by the name of their market.
+	var wg sync.WaitGroup
+	var markets []market.Market
+	for _, enabled := range newMarkets(c) {
+		m, closeRecording := decorateMarket(ctx, enabled, db)
+		defer closeRecording()
+		markets = append(markets, m)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.New(&enabled.config, m, db, clock.Real{}).Start(ctx)
+		}()
+	}
+
+	if c.ArbitrageOptions.Enable {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.NewArbitrageDetector(&c, markets, db, db, clock.Real{}).Start(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// decorateMarket wraps the given enabled market in the decorators that are enabled in its config, and streams its prices if supported.
+// In test mode, the coins of the open test mode positions in the given database are restored in the virtual wallet.
+// The returned function closes the recording of the market, if any.
+func decorateMarket(ctx context.Context, enabled enabledMarket, db database.Database) (market.Market, func()) {
+	c := enabled.config
+
+	if binance, ok := enabled.market.(*market.Binance); ok && c.Markets.Binance.EnableStreaming {
+		go binance.Stream(ctx, func(err error) {
+			log.Printf("Price stream error: %s.", err)
+		})
+	}
+
+	var m market.Market = market.NewRetry(enabled.market, logRetry)
+
+	if c.EnableTestMode {
+		simulator := market.NewSimulator(m, c, clock.Real{})
+		restoreTestPositions(simulator, db, c.TradingOptions.PairWith)
+		m = simulator
+	}
+
+	if !c.EnableRecording {
+		return m, func() {}
+	}
+
+	w, path, err := recorder.Create(m.Name(), time.Now())
+	if err != nil {
+		panic(fmt.Errorf("failed to create recording: %w", err))
+	}
+
+	// The buy and sell loops both fetch the coins, so record them at most as often as the most frequent of the two.
+	interval := min(
+		utils.CalculateTimeDuration(c.TradingOptions.TimeDifference, c.TradingOptions.RecheckInterval),
+		time.Duration(c.TradingOptions.SellTimeout)*time.Second,
+	)
+
+	log.Printf("Recording snapshots of %s to %s.", m.Name(), path)
+	m = recorder.NewMarket(m, w, clock.Real{}, interval, func(err error) {
+		log.Printf("Failed to record snapshot: %s.", err)
+	})
+
+	return m, func() {
+		_ = w.Close()
+	}
+}
+
+// restoreTestPositions deposits the coins of the open test mode positions of the given simulator's market into its virtual wallet, so that they can still be sold after a restart.
+func restoreTestPositions(simulator *market.Simulator, db database.Database, pairWith string) {
+	for _, order := range db.GetOrders(models.BuyOrder, simulator.Name()) {
+		if order.IsTestMode {
+			simulator.Deposit(strings.TrimSuffix(order.Symbol, pairWith), order.Volume)
+		}
+	}
+}
+
+// loadConfig loads the config file from the default locations or from the given path if it's not empty.
+func loadConfig(configPaths ...string) config.Configuration {
+	var paths []string
+	for _, configPath := range configPaths {
+		if configPath != "" {
+			paths = append(paths, configPath)
+		}
+	}
+
+	c, err := config.Load(paths...)
 	if err != nil {
 		panic(fmt.Errorf("failed to load config file: %w", err))
 	}
 
-	b := bot.New(&c, market.NewBinance(c), database.NewSqliteDatabase("voltra.db", c.LoggingOptions))
-	b.Start(ctx)
+	return c
+}
+
+// logRetry logs a failed market request that is about to be retried.
+func logRetry(err error, delay time.Duration) {
+	log.Printf("Market request failed, retrying in %s: %s.", delay.Round(time.Millisecond), err)
 }
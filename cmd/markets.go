@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+)
+
+// enabledMarket is a market that is enabled in the config, along with the config to trade on it.
+type enabledMarket struct {
+	market market.Market
+	config config.Configuration
+}
+
+// newMarkets returns the markets that are enabled in the given config.
+// Binance is used if no market is enabled.
+func newMarkets(c config.Configuration) []enabledMarket {
+	var markets []enabledMarket
+	add := func(options config.MarketOptions, newMarket func(c config.Configuration) market.Market) {
+		if options.Enable {
+			marketConfig := c.ForMarket(options)
+			markets = append(markets, enabledMarket{market: newMarket(marketConfig), config: marketConfig})
+		}
+	}
+
+	add(c.Markets.Binance.MarketOptions, func(c config.Configuration) market.Market { return market.NewBinance(c) })
+	add(c.Markets.Kraken.MarketOptions, func(c config.Configuration) market.Market { return market.NewKraken(c) })
+	add(c.Markets.Coinbase.MarketOptions, func(c config.Configuration) market.Market { return market.NewCoinbase(c) })
+	add(c.Markets.KuCoin.MarketOptions, func(c config.Configuration) market.Market { return market.NewKuCoin(c) })
+	add(c.Markets.Bybit.MarketOptions, func(c config.Configuration) market.Market { return market.NewBybit(c) })
+
+	if len(markets) == 0 {
+		marketConfig := c.ForMarket(c.Markets.Binance.MarketOptions)
+		markets = append(markets, enabledMarket{market: market.NewBinance(marketConfig), config: marketConfig})
+	}
+
+	return markets
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/bot"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/market"
+)
+
+// reconcile compares the open positions in the database with the balances on each enabled market and prints the differences.
+func reconcile(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to the config file")
+	fix := flags.Bool("fix", false, "update the open positions to match the balances on the market")
+	_ = flags.Parse(args)
+
+	c := loadConfig(*configPath)
+
+	db := database.NewSqliteDatabase(databaseFileName, c.LoggingOptions)
+	markets := newMarkets(c)
+
+	for _, enabled := range markets {
+		m := market.NewRetry(enabled.market, logRetry)
+		b := bot.New(&enabled.config, m, db, clock.Real{})
+
+		discrepancies, err := b.Reconcile(ctx, *fix)
+		if err != nil {
+			panic(fmt.Errorf("failed to reconcile %s: %w", m.Name(), err))
+		}
+
+		if len(markets) > 1 {
+			fmt.Printf("%s:\n", m.Name())
+		}
+		if len(discrepancies) == 0 {
+			fmt.Println("The open positions match the balances on the market.")
+			continue
+		}
+		for _, d := range discrepancies {
+			fmt.Printf("%s.\n", d)
+		}
+	}
+}
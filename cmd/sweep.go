@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/optimize"
+	"github.com/sleeyax/voltra/internal/recorder"
+	"os"
+)
+
+// sweep backtests every combination of the given trading option ranges and prints the ranked results.
+func sweep(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: voltra sweep [-config config.yml] -grid sweep.yml recording%s...\n", recorder.FileExtension)
+		_, _ = fmt.Fprintln(flags.Output(), "       voltra sweep [-config config.yml] -grid sweep.yml -klines [-resolution 1m] [-from date] [-to date]")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "path to the config file with the trading options that aren't swept")
+	gridPath := flags.String("grid", "", "path to a YAML file with the values to try per trading option, e.g. `change_in_price: 3..10 step 1`")
+	top := flags.Int("top", 20, "number of results to print, or 0 to print all of them")
+	snapshotOptions := addSnapshotFlags(flags)
+	_ = flags.Parse(args)
+
+	if *gridPath == "" || (flags.NArg() == 0 && !*snapshotOptions.useKlines) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := loadConfig(*configPath)
+
+	parameters, err := optimize.LoadParameters(*gridPath)
+	if err != nil {
+		panic(fmt.Errorf("failed to load grid: %w", err))
+	}
+
+	snapshots, err := snapshotOptions.load(c, flags.Args())
+	if err != nil {
+		panic(fmt.Errorf("failed to load snapshots: %w", err))
+	}
+
+	results, err := optimize.Sweep(ctx, c, snapshots, parameters)
+	if err != nil {
+		panic(fmt.Errorf("failed to run sweep: %w", err))
+	}
+
+	fmt.Printf("Backtested %d combinations.\n\n", len(results))
+	_ = optimize.PrintResults(os.Stdout, parameters, results, *top)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/optimize"
+	"github.com/sleeyax/voltra/internal/recorder"
+	"os"
+	"time"
+)
+
+// walkForward optimizes the given trading option ranges on rolling in-sample periods, validates them on the out-of-sample periods that follow and prints the results.
+func walkForward(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("walk-forward", flag.ExitOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: voltra walk-forward [-config config.yml] -grid sweep.yml [-in-sample 72h] [-out-of-sample 24h] [-output best.yml] recording%s...\n", recorder.FileExtension)
+		_, _ = fmt.Fprintln(flags.Output(), "       voltra walk-forward [-config config.yml] -grid sweep.yml [-in-sample 72h] [-out-of-sample 24h] [-output best.yml] -klines [-resolution 1m] [-from date] [-to date]")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "path to the config file with the trading options that aren't optimized")
+	gridPath := flags.String("grid", "", "path to a YAML file with the values to try per trading option, e.g. `change_in_price: 3..10 step 1`")
+	inSample := flags.Duration("in-sample", 72*time.Hour, "length of each period the trading options are optimized on")
+	outOfSample := flags.Duration("out-of-sample", 24*time.Hour, "length of each period the optimized trading options are validated on")
+	outputPath := flags.String("output", "", "path to write a config file with the trading options of the most recent period to")
+	snapshotOptions := addSnapshotFlags(flags)
+	_ = flags.Parse(args)
+
+	if *gridPath == "" || (flags.NArg() == 0 && !*snapshotOptions.useKlines) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := loadConfig(*configPath)
+
+	parameters, err := optimize.LoadParameters(*gridPath)
+	if err != nil {
+		panic(fmt.Errorf("failed to load grid: %w", err))
+	}
+
+	snapshots, err := snapshotOptions.load(c, flags.Args())
+	if err != nil {
+		panic(fmt.Errorf("failed to load snapshots: %w", err))
+	}
+
+	report, err := optimize.WalkForward(ctx, c, snapshots, parameters, *inSample, *outOfSample)
+	if err != nil {
+		panic(fmt.Errorf("failed to run walk-forward analysis: %w", err))
+	}
+
+	_ = report.Print(os.Stdout)
+
+	if *outputPath != "" {
+		best, err := report.Apply(c)
+		if err != nil {
+			panic(fmt.Errorf("failed to apply the optimized trading options: %w", err))
+		}
+		if err = config.Save(*outputPath, best); err != nil {
+			panic(fmt.Errorf("failed to save config file: %w", err))
+		}
+		fmt.Printf("\nSaved the trading options of the most recent period to %s.\n", *outputPath)
+	}
+}
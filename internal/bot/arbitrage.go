@@ -0,0 +1,340 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/utils"
+	"go.uber.org/zap"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// arbitrageDefaultInterval is the interval at which the prices on all markets are compared if none is configured.
+const arbitrageDefaultInterval = 10 * time.Second
+
+// arbitrageDefaultSpreadTolerance is the change in PERCENTAGE points of the spread of an opportunity before it's stored again if none is configured.
+const arbitrageDefaultSpreadTolerance = 0.1
+
+// ArbitrageDetector compares the prices of the same coins between markets and flags the differences that are large enough to profit from.
+// All markets use the same symbols (e.g. BTCUSDT), so the coins of different markets can be compared by symbol.
+type ArbitrageDetector struct {
+	markets     []market.Market
+	db          database.Database
+	arbitrageDb database.ArbitrageDatabase
+	config      *config.Configuration
+	clock       clock.Clock
+	log         *zap.SugaredLogger
+
+	// The spreads with which the current opportunities were last stored, keyed by symbol and markets.
+	// Opportunities are only stored again when their spread changes by more than the configured tolerance, so that they aren't stored on every comparison for as long as they last.
+	spreads map[string]float64
+}
+
+// NewArbitrageDetector creates a new detector that compares the prices on the given markets according to the given configuration.
+// The orders of the bots in db are only read, so that their open positions aren't sold. The opportunities it finds and trades are stored in arbitrageDb.
+func NewArbitrageDetector(config *config.Configuration, markets []market.Market, db database.Database, arbitrageDb database.ArbitrageDatabase, clock clock.Clock) *ArbitrageDetector {
+	return &ArbitrageDetector{
+		markets:     markets,
+		db:          db,
+		arbitrageDb: arbitrageDb,
+		config:      config,
+		clock:       clock,
+		log:         createLogger(config.LoggingOptions).Named("arbitrage"),
+		spreads:     make(map[string]float64),
+	}
+}
+
+// Start compares the prices on all markets at the configured interval until the given context is cancelled.
+func (d *ArbitrageDetector) Start(ctx context.Context) {
+	defer func() {
+		_ = d.log.Sync()
+	}()
+
+	if len(d.markets) < 2 {
+		d.log.Warn("Arbitrage detection requires at least two enabled markets. Not comparing prices.")
+		return
+	}
+
+	interval := time.Duration(d.config.ArbitrageOptions.Interval) * time.Second
+	if interval <= 0 {
+		interval = arbitrageDefaultInterval
+	}
+
+	d.log.Infof("Comparing prices between %d markets every %s.", len(d.markets), interval)
+
+	ticker := d.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.Detect(ctx)
+
+		select {
+		case <-ctx.Done():
+			d.log.Debug("Stopped comparing prices.")
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Detect compares the current prices on all markets once, and logs, stores and optionally trades the opportunities it finds.
+// Opportunities are only stored when they're new, their spread changed by more than the configured tolerance since they were last stored, or orders were placed for them.
+// Markets whose prices can't be fetched are left out of the comparison.
+func (d *ArbitrageDetector) Detect(ctx context.Context) []models.ArbitrageOpportunity {
+	prices := d.fetchPrices(ctx)
+	opportunities := findArbitrageOpportunities(prices, d.config)
+	spreads := make(map[string]float64, len(opportunities))
+
+	tolerance := d.config.ArbitrageOptions.SpreadTolerance
+	if tolerance <= 0 {
+		tolerance = arbitrageDefaultSpreadTolerance
+	}
+
+	for i := range opportunities {
+		o := &opportunities[i]
+		d.log.Infof("%s is %.2f%% cheaper on %s (%g) than on %s (%g), an expected profit of %.2f%% after costs.",
+			o.Symbol, o.Spread, o.BuyMarket, o.BuyPrice, o.SellMarket, o.SellPrice, o.NetSpread)
+
+		if d.config.ArbitrageOptions.EnableTrading {
+			if err := d.trade(ctx, o); err != nil {
+				d.log.Warnf("Not trading %s between %s and %s: %s.", o.Symbol, o.BuyMarket, o.SellMarket, err)
+			}
+		}
+
+		key := o.Symbol + "/" + o.BuyMarket + "/" + o.SellMarket
+		spread, ok := d.spreads[key]
+		if !ok || math.Abs(o.Spread-spread) > tolerance || o.BuyOrder.FilledQuantity > 0 || o.SellOrder.FilledQuantity > 0 {
+			d.arbitrageDb.SaveArbitrageOpportunity(*o)
+			spread = o.Spread
+		}
+		spreads[key] = spread
+	}
+
+	// Opportunities that disappeared are new again when they come back.
+	d.spreads = spreads
+
+	return opportunities
+}
+
+// fetchPrices fetches the current prices of all markets concurrently, keyed by the name of the market.
+func (d *ArbitrageDetector) fetchPrices(ctx context.Context) map[string]market.Coins {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	prices := make(map[string]market.Coins, len(d.markets))
+
+	for _, m := range d.markets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			coins, err := m.GetCoins(ctx)
+			if err != nil {
+				d.log.Errorf("Failed to fetch the prices of %s: %s.", m.Name(), err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			prices[m.Name()] = coins
+		}()
+	}
+	wg.Wait()
+
+	return prices
+}
+
+// findArbitrageOpportunities compares the given prices per market and returns the best opportunity per symbol, most profitable first.
+// Only symbols that are available for trading according to the given configuration are compared.
+func findArbitrageOpportunities(prices map[string]market.Coins, c *config.Configuration) []models.ArbitrageOpportunity {
+	options := c.TradingOptions
+
+	// Iterate the markets in a fixed order, so that ties are resolved the same way every time.
+	names := make([]string, 0, len(prices))
+	for name := range prices {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	type quote struct {
+		market string
+		price  float64
+	}
+	lowest := make(map[string]quote)
+	highest := make(map[string]quote)
+
+	for _, name := range names {
+		for symbol, coin := range prices[name] {
+			if coin.Price <= 0 || !strings.HasSuffix(symbol, options.PairWith) || !coin.IsAvailableForTrading(options.AllowList, options.DenyList, options.PairWith, 0) {
+				continue
+			}
+			if low, ok := lowest[symbol]; !ok || coin.Price < low.price {
+				lowest[symbol] = quote{name, coin.Price}
+			}
+			if high, ok := highest[symbol]; !ok || coin.Price > high.price {
+				highest[symbol] = quote{name, coin.Price}
+			}
+		}
+	}
+
+	var opportunities []models.ArbitrageOpportunity
+	for symbol, low := range lowest {
+		high := highest[symbol]
+		if high.market == low.market {
+			continue
+		}
+
+		netSpread := arbitrageNetSpread(low.price, high.price, c)
+		if netSpread <= 0 || netSpread < c.ArbitrageOptions.MinSpread {
+			continue
+		}
+
+		opportunities = append(opportunities, models.ArbitrageOpportunity{
+			Symbol:     symbol,
+			BuyMarket:  low.market,
+			BuyPrice:   low.price,
+			SellMarket: high.market,
+			SellPrice:  high.price,
+			Spread:     (high.price - low.price) / low.price * 100,
+			NetSpread:  netSpread,
+			IsTestMode: c.EnableTestMode,
+		})
+	}
+
+	slices.SortFunc(opportunities, func(a, b models.ArbitrageOpportunity) int {
+		return cmp.Or(cmp.Compare(b.NetSpread, a.NetSpread), cmp.Compare(a.Symbol, b.Symbol))
+	})
+
+	return opportunities
+}
+
+// arbitrageNetSpread returns the expected profit in PERCENTAGE of the configured quantity of buying a coin at the given buy price and selling it at the given sell price.
+// The taker fee is paid on both markets, and the withdrawal cost to move the coins between them is paid once.
+func arbitrageNetSpread(buyPrice, sellPrice float64, c *config.Configuration) float64 {
+	quantity := c.TradingOptions.Quantity
+	if quantity <= 0 {
+		return 0
+	}
+
+	fee := c.TradingOptions.TradingFeeTaker / 100
+	volume := quantity / buyPrice
+	proceeds := volume * sellPrice * (1 - fee)
+	cost := quantity*(1+fee) + c.ArbitrageOptions.WithdrawalCost
+
+	return (proceeds - cost) / quantity * 100
+}
+
+// trade buys the symbol of the given opportunity on its buy market and sells it on its sell market at the same time.
+// Both legs are only placed when the balances on both markets allow it, so that the bot never ends up with only one of them.
+// The orders are stored with the opportunity rather than with the orders of the bots, so that the bots don't sell the bought coins a second time.
+func (d *ArbitrageDetector) trade(ctx context.Context, o *models.ArbitrageOpportunity) error {
+	buyMarket, sellMarket := d.market(o.BuyMarket), d.market(o.SellMarket)
+	if buyMarket == nil || sellMarket == nil {
+		return errors.New("market not found")
+	}
+
+	buyInfo, err := buyMarket.GetSymbolInfo(ctx, o.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the symbol info on %s: %w", o.BuyMarket, err)
+	}
+	sellInfo, err := sellMarket.GetSymbolInfo(ctx, o.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the symbol info on %s: %w", o.SellMarket, err)
+	}
+	if buyInfo.Halted || sellInfo.Halted {
+		return errors.New("trading is halted")
+	}
+
+	// The volume must be valid on both markets.
+	volume := d.config.TradingOptions.Quantity / o.BuyPrice
+	for _, info := range []market.SymbolInfo{buyInfo, sellInfo} {
+		if info.StepSize > 0 {
+			volume = utils.FloorStepSize(volume, info.StepSize)
+		}
+	}
+	if minQuantity := math.Max(buyInfo.MinQuantity, sellInfo.MinQuantity); volume <= 0 || volume < minQuantity {
+		return fmt.Errorf("volume %g is below the minimum quantity", volume)
+	}
+
+	pairWith := d.config.TradingOptions.PairWith
+	baseAsset := strings.TrimSuffix(o.Symbol, pairWith)
+
+	buyBalances, err := buyMarket.GetBalances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the balances on %s: %w", o.BuyMarket, err)
+	}
+	if cost := volume * o.BuyPrice * (1 + d.config.TradingOptions.TradingFeeTaker/100); buyBalances[pairWith].Free-d.config.TradingOptions.BalanceReserve < cost {
+		return fmt.Errorf("not enough %s on %s", pairWith, o.BuyMarket)
+	}
+
+	sellBalances, err := sellMarket.GetBalances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the balances on %s: %w", o.SellMarket, err)
+	}
+	// Don't sell the coins the bot of the sell market holds as open positions.
+	available := sellBalances[baseAsset].Free
+	for _, order := range d.db.GetOrders(models.BuyOrder, o.SellMarket) {
+		if order.Symbol == o.Symbol {
+			available -= order.Volume
+		}
+	}
+	if available < volume {
+		return fmt.Errorf("not enough untracked %s on %s", baseAsset, o.SellMarket)
+	}
+
+	d.log.Infof("Buying %g %s on %s and selling it on %s.", volume, o.Symbol, o.BuyMarket, o.SellMarket)
+
+	var wg sync.WaitGroup
+	var buyOrder, sellOrder market.Order
+	var buyErr, sellErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		buyOrder, buyErr = buyMarket.Buy(ctx, o.Symbol, volume)
+	}()
+	go func() {
+		defer wg.Done()
+		sellOrder, sellErr = sellMarket.Sell(ctx, o.Symbol, volume)
+	}()
+	wg.Wait()
+
+	if buyErr == nil {
+		o.BuyOrder = buyOrder
+	}
+	if sellErr == nil {
+		o.SellOrder = sellOrder
+	}
+
+	if buyErr != nil || sellErr != nil {
+		// Either leg may have been filled, which needs to be resolved manually.
+		return fmt.Errorf("failed to trade both legs, check the balances on both markets: %w", errors.Join(buyErr, sellErr))
+	}
+	if buyOrder.FilledQuantity <= 0 || sellOrder.FilledQuantity <= 0 {
+		return fmt.Errorf("not both legs were filled (buy %s, sell %s), check the balances on both markets", buyOrder.Status, sellOrder.Status)
+	}
+
+	o.Traded = true
+	d.log.Infof("Bought %g %s on %s at %g and sold %g on %s at %g.",
+		buyOrder.FilledQuantity, o.Symbol, o.BuyMarket, buyOrder.Price, sellOrder.FilledQuantity, o.SellMarket, sellOrder.Price)
+
+	return nil
+}
+
+// market returns the market with the given name, or nil if it doesn't exist.
+func (d *ArbitrageDetector) market(name string) market.Market {
+	for _, m := range d.markets {
+		if m.Name() == name {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package bot
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"slices"
+	"testing"
+	"time"
+)
+
+// arbitrageMarket is a market with fixed prices and balances that fills all orders at its price.
+type arbitrageMarket struct {
+	market.Market
+	name     string
+	coins    market.Coins
+	balances market.Balances
+
+	// The quantities of all orders placed, by side.
+	bought []float64
+	sold   []float64
+
+	// Whether orders are canceled without filling anything.
+	unfilled bool
+}
+
+func newArbitrageMarket(name string, prices map[string]float64, balances market.Balances) *arbitrageMarket {
+	coins := make(market.Coins, len(prices))
+	for symbol, price := range prices {
+		coins[symbol] = market.Coin{Symbol: symbol, Price: price, Time: time.Now()}
+	}
+	return &arbitrageMarket{name: name, coins: coins, balances: balances}
+}
+
+func (m *arbitrageMarket) Name() string {
+	return m.name
+}
+
+func (m *arbitrageMarket) GetCoins(_ context.Context) (market.Coins, error) {
+	return m.coins, nil
+}
+
+func (m *arbitrageMarket) GetSymbolInfo(_ context.Context, symbol string) (market.SymbolInfo, error) {
+	return market.SymbolInfo{Symbol: symbol, StepSize: 0.001, MinQuantity: 0.001}, nil
+}
+
+func (m *arbitrageMarket) GetBalances(_ context.Context) (market.Balances, error) {
+	return m.balances, nil
+}
+
+func (m *arbitrageMarket) Buy(_ context.Context, coin string, quantity float64) (market.Order, error) {
+	m.bought = append(m.bought, quantity)
+	return m.executeOrder(coin, quantity), nil
+}
+
+func (m *arbitrageMarket) Sell(_ context.Context, coin string, quantity float64) (market.Order, error) {
+	m.sold = append(m.sold, quantity)
+	return m.executeOrder(coin, quantity), nil
+}
+
+func (m *arbitrageMarket) executeOrder(coin string, quantity float64) market.Order {
+	if m.unfilled {
+		return market.Order{Symbol: coin, Status: market.CanceledOrderStatus}
+	}
+	return market.Order{Symbol: coin, Price: m.coins[coin].Price, FilledQuantity: quantity, Status: market.FilledOrderStatus}
+}
+
+func newArbitrageConfig() *config.Configuration {
+	return &config.Configuration{
+		TradingOptions: config.TradingOptions{
+			PairWith:        "USDT",
+			Quantity:        100,
+			TradingFeeTaker: 0.1,
+			DenyList:        []string{"EURUSDT"},
+		},
+		ArbitrageOptions: config.ArbitrageOptions{
+			MinSpread:      0.5,
+			WithdrawalCost: 1,
+		},
+	}
+}
+
+func TestArbitrageNetSpread(t *testing.T) {
+	c := newArbitrageConfig()
+
+	// 1 BTC is bought for 100 USDT + 0.1 fee, sold for 102 USDT - 0.102 fee and moved for 1 USDT.
+	assert.InDelta(t, 0.798, arbitrageNetSpread(100, 102, c), 1e-9)
+	assert.Less(t, arbitrageNetSpread(100, 101, c), 0.0)
+
+	c.TradingOptions.Quantity = 0
+	assert.Equal(t, 0.0, arbitrageNetSpread(100, 102, c))
+}
+
+func TestFindArbitrageOpportunities(t *testing.T) {
+	prices := map[string]market.Coins{
+		"binance": {
+			"BTCUSDT": {Symbol: "BTCUSDT", Price: 100},
+			"ETHUSDT": {Symbol: "ETHUSDT", Price: 10},
+			"EURUSDT": {Symbol: "EURUSDT", Price: 1},
+			"ETHBTC":  {Symbol: "ETHBTC", Price: 0.1},
+			"SOLUSDT": {Symbol: "SOLUSDT", Price: 5},
+		},
+		"kraken": {
+			"BTCUSDT": {Symbol: "BTCUSDT", Price: 101},
+			"ETHUSDT": {Symbol: "ETHUSDT", Price: 10.5},
+			"EURUSDT": {Symbol: "EURUSDT", Price: 2},
+			"ETHBTC":  {Symbol: "ETHBTC", Price: 0.2},
+		},
+		"kucoin": {
+			"BTCUSDT": {Symbol: "BTCUSDT", Price: 103},
+			"ETHUSDT": {Symbol: "ETHUSDT", Price: 10.1, Halted: true},
+		},
+	}
+
+	opportunities := findArbitrageOpportunities(prices, newArbitrageConfig())
+
+	// The deny list, other quote assets, halted coins and coins that are only on one market are ignored.
+	// The best buy and sell market are picked per symbol and the most profitable opportunity comes first.
+	assert.Equal(t, 2, len(opportunities))
+	eth, btc := opportunities[0], opportunities[1]
+
+	assert.Equal(t, "ETHUSDT", eth.Symbol)
+	assert.Equal(t, "binance", eth.BuyMarket)
+	assert.Equal(t, "kraken", eth.SellMarket)
+	assert.InDelta(t, 5.0, eth.Spread, 1e-9)
+
+	assert.Equal(t, "BTCUSDT", btc.Symbol)
+	assert.Equal(t, "binance", btc.BuyMarket)
+	assert.Equal(t, 100.0, btc.BuyPrice)
+	assert.Equal(t, "kucoin", btc.SellMarket)
+	assert.Equal(t, 103.0, btc.SellPrice)
+	assert.InDelta(t, 3.0, btc.Spread, 1e-9)
+	assert.InDelta(t, arbitrageNetSpread(100, 103, newArbitrageConfig()), btc.NetSpread, 1e-9)
+
+	// Spreads that don't cover the costs aren't flagged.
+	c := newArbitrageConfig()
+	c.ArbitrageOptions.MinSpread = 3
+	assert.Equal(t, 1, len(findArbitrageOpportunities(prices, c)))
+}
+
+func TestArbitrageDetector_Detect(t *testing.T) {
+	c := newArbitrageConfig()
+	c.ArbitrageOptions.EnableTrading = true
+
+	cheap := newArbitrageMarket("binance", map[string]float64{"BTCUSDT": 100, "ETHUSDT": 10}, market.Balances{
+		"USDT": {Asset: "USDT", Free: 1000},
+	})
+	expensive := newArbitrageMarket("kraken", map[string]float64{"BTCUSDT": 103, "ETHUSDT": 11}, market.Balances{
+		"BTC": {Asset: "BTC", Free: 2},
+	})
+	db := database.NewMemoryDatabase(clock.Real{})
+	d := NewArbitrageDetector(c, []market.Market{cheap, expensive}, db, db, clock.Real{})
+
+	opportunities := d.Detect(context.Background())
+	assert.Equal(t, 2, len(opportunities))
+
+	// Both legs are traded when the balances on both markets allow it.
+	saved := db.GetArbitrageOpportunities()
+	assert.Equal(t, 2, len(saved))
+	for _, o := range saved {
+		assert.Equal(t, o.Symbol == "BTCUSDT", o.Traded, o.Symbol)
+	}
+	assert.Equal(t, []float64{1}, cheap.bought)
+	assert.Equal(t, []float64{1}, expensive.sold)
+
+	// Both legs are stored with the opportunity, where the bots don't pick them up.
+	btc := saved[slices.IndexFunc(saved, func(o models.ArbitrageOpportunity) bool { return o.Symbol == "BTCUSDT" })]
+	assert.Equal(t, 100.0, btc.BuyOrder.Price)
+	assert.Equal(t, 1.0, btc.BuyOrder.FilledQuantity)
+	assert.Equal(t, 103.0, btc.SellOrder.Price)
+	assert.Equal(t, 1.0, btc.SellOrder.FilledQuantity)
+	assert.Empty(t, db.GetOrders(models.BuyOrder, "binance"))
+	assert.Empty(t, db.GetOrders(models.SellOrder, "kraken"))
+}
+
+func TestArbitrageDetector_Detect_spread_tolerance(t *testing.T) {
+	cheap := newArbitrageMarket("binance", map[string]float64{"BTCUSDT": 100, "ETHUSDT": 10}, nil)
+	expensive := newArbitrageMarket("kraken", map[string]float64{"BTCUSDT": 103, "ETHUSDT": 11}, nil)
+	db := database.NewMemoryDatabase(clock.Real{})
+	d := NewArbitrageDetector(newArbitrageConfig(), []market.Market{cheap, expensive}, db, db, clock.Real{})
+
+	d.Detect(context.Background())
+	assert.Equal(t, 2, len(db.GetArbitrageOpportunities()))
+
+	// Opportunities whose spread didn't change by more than the tolerance aren't stored again.
+	d.Detect(context.Background())
+	expensive.coins["ETHUSDT"] = market.Coin{Symbol: "ETHUSDT", Price: 11.005, Time: time.Now()}
+	d.Detect(context.Background())
+	assert.Equal(t, 2, len(db.GetArbitrageOpportunities()))
+
+	// The spread is compared to the spread that was stored last, so that slow changes are stored too.
+	expensive.coins["ETHUSDT"] = market.Coin{Symbol: "ETHUSDT", Price: 11.02, Time: time.Now()}
+	d.Detect(context.Background())
+	saved := db.GetArbitrageOpportunities()
+	assert.Equal(t, 3, len(saved))
+	assert.Equal(t, "ETHUSDT", saved[2].Symbol)
+	assert.InDelta(t, 10.2, saved[2].Spread, 1e-9)
+
+	// Opportunities that disappeared are stored again when they come back.
+	expensive.coins["BTCUSDT"] = market.Coin{Symbol: "BTCUSDT", Price: 100, Time: time.Now()}
+	d.Detect(context.Background())
+	expensive.coins["BTCUSDT"] = market.Coin{Symbol: "BTCUSDT", Price: 103, Time: time.Now()}
+	d.Detect(context.Background())
+	saved = db.GetArbitrageOpportunities()
+	assert.Equal(t, 4, len(saved))
+	assert.Equal(t, "BTCUSDT", saved[3].Symbol)
+}
+
+func TestArbitrageDetector_Detect_insufficient_funds(t *testing.T) {
+	c := newArbitrageConfig()
+	c.ArbitrageOptions.EnableTrading = true
+
+	cheap := newArbitrageMarket("binance", map[string]float64{"BTCUSDT": 100}, market.Balances{
+		"USDT": {Asset: "USDT", Free: 50},
+	})
+	expensive := newArbitrageMarket("kraken", map[string]float64{"BTCUSDT": 103}, market.Balances{
+		"BTC": {Asset: "BTC", Free: 2},
+	})
+	db := database.NewMemoryDatabase(clock.Real{})
+	d := NewArbitrageDetector(c, []market.Market{cheap, expensive}, db, db, clock.Real{})
+
+	// Nothing is traded when either market lacks the funds.
+	d.Detect(context.Background())
+	assert.Empty(t, cheap.bought)
+	assert.Empty(t, expensive.sold)
+
+	// The coins the bot of the sell market holds as open positions don't count.
+	cheap.balances["USDT"] = market.Balance{Asset: "USDT", Free: 1000}
+	db.SaveOrder(models.Order{Order: market.Order{Symbol: "BTCUSDT"}, Market: "kraken", Type: models.BuyOrder, Volume: 1.5})
+	d.Detect(context.Background())
+	assert.Empty(t, cheap.bought)
+	assert.Empty(t, expensive.sold)
+
+	saved := db.GetArbitrageOpportunities()
+	assert.Equal(t, 1, len(saved))
+	assert.False(t, saved[0].Traded)
+}
+
+func TestArbitrageDetector_Detect_unfilled(t *testing.T) {
+	c := newArbitrageConfig()
+	c.ArbitrageOptions.EnableTrading = true
+
+	cheap := newArbitrageMarket("binance", map[string]float64{"BTCUSDT": 100}, market.Balances{
+		"USDT": {Asset: "USDT", Free: 1000},
+	})
+	expensive := newArbitrageMarket("kraken", map[string]float64{"BTCUSDT": 103}, market.Balances{
+		"BTC": {Asset: "BTC", Free: 2},
+	})
+	expensive.unfilled = true
+	db := database.NewMemoryDatabase(clock.Real{})
+	d := NewArbitrageDetector(c, []market.Market{cheap, expensive}, db, db, clock.Real{})
+
+	// Only the leg that was filled is stored, with the opportunity.
+	d.Detect(context.Background())
+	saved := db.GetArbitrageOpportunities()
+	assert.Equal(t, 1, len(saved))
+	assert.False(t, saved[0].Traded)
+	assert.Equal(t, 1.0, saved[0].BuyOrder.FilledQuantity)
+	assert.Equal(t, 0.0, saved[0].SellOrder.FilledQuantity)
+	assert.Empty(t, db.GetOrders(models.BuyOrder, "binance"))
+}
+
+func TestArbitrageDetector_Start_single_market(t *testing.T) {
+	m := newArbitrageMarket("binance", map[string]float64{"BTCUSDT": 100}, nil)
+	db := database.NewMemoryDatabase(clock.Real{})
+	d := NewArbitrageDetector(newArbitrageConfig(), []market.Market{m}, db, db, clock.Real{})
+
+	// Returns right away, because there's nothing to compare.
+	d.Start(context.Background())
+	assert.Empty(t, db.GetArbitrageOpportunities())
+}
@@ -0,0 +1,255 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/utils"
+	"io"
+	"math"
+	"text/tabwriter"
+	"time"
+)
+
+// replayMarketName is the market name used when the replayed snapshots don't specify one.
+const replayMarketName = "replay"
+
+type BacktestReport struct {
+	// The time of the first replayed snapshot.
+	Start time.Time
+
+	// The time of the last replayed snapshot.
+	End time.Time
+
+	// All sell orders that were executed during the backtest, in chronological order.
+	Trades []models.Order
+
+	// Number of trades that were sold with a profit.
+	Wins int
+
+	// Number of trades that were sold with a loss.
+	Losses int
+
+	// Sum of the realized profit or loss of all trades.
+	NetProfitLoss float64
+
+	// Largest decline of the cumulative realized profit or loss from its highest point.
+	MaxDrawdown float64
+
+	// Buy orders that were still open at the end of the backtest.
+	OpenPositions []models.Order
+
+	// Profit or loss of the open positions at the last replayed prices.
+	UnrealizedProfitLoss float64
+}
+
+// WinRate returns the percentage of trades that were sold with a profit.
+func (r BacktestReport) WinRate() float64 {
+	if len(r.Trades) == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(len(r.Trades)) * 100
+}
+
+// Returns returns the realized return of each trade as a fraction of the amount that was invested in it.
+func (r BacktestReport) Returns() []float64 {
+	returns := make([]float64, 0, len(r.Trades))
+	for _, trade := range r.Trades {
+		buyPrice := trade.Price
+		if trade.PriceChangePercentage != nil {
+			buyPrice = trade.Price / (1 + *trade.PriceChangePercentage/100)
+		}
+		if cost := buyPrice * trade.Volume; cost != 0 {
+			returns = append(returns, *trade.RealizedProfitLoss/cost)
+		}
+	}
+	return returns
+}
+
+// SharpeRatio returns the mean return per trade divided by the standard deviation of those returns.
+// The ratio is not annualized, so it's only meant to compare backtests over the same period of time.
+// Returns 0 when there are too few trades to calculate it.
+func (r BacktestReport) SharpeRatio() float64 {
+	returns := r.Returns()
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, ret := range returns {
+		mean += ret
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, ret := range returns {
+		variance += (ret - mean) * (ret - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(len(returns)-1))
+	if stdDev == 0 {
+		return 0
+	}
+
+	return mean / stdDev
+}
+
+// Print writes a human-readable summary of the backtest to the given writer.
+func (r BacktestReport) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintf(tw, "Backtest from %s to %s (%s)\n\n", r.Start.Format(time.DateTime), r.End.Format(time.DateTime), r.End.Sub(r.Start))
+
+	_, _ = fmt.Fprintln(tw, "TIME\tSYMBOL\tVOLUME\tSELL PRICE\tCHANGE\tP/L")
+	for _, trade := range r.Trades {
+		var change float64
+		if trade.PriceChangePercentage != nil {
+			change = *trade.PriceChangePercentage
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%g\t%g\t%.2f%%\t$%.2f\n", trade.TransactionTime.Format(time.DateTime), trade.Symbol, trade.Volume, trade.Price, change, *trade.RealizedProfitLoss)
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	_, _ = fmt.Fprintf(tw, "Trades:\t%d\n", len(r.Trades))
+	_, _ = fmt.Fprintf(tw, "Win rate:\t%.2f%% (%d wins, %d losses)\n", r.WinRate(), r.Wins, r.Losses)
+	_, _ = fmt.Fprintf(tw, "Net P/L:\t$%.2f\n", r.NetProfitLoss)
+	_, _ = fmt.Fprintf(tw, "Sharpe ratio:\t%.2f\n", r.SharpeRatio())
+	_, _ = fmt.Fprintf(tw, "Max drawdown:\t$%.2f\n", r.MaxDrawdown)
+	_, _ = fmt.Fprintf(tw, "Open positions:\t%d (unrealized P/L: $%.2f)\n", len(r.OpenPositions), r.UnrealizedProfitLoss)
+
+	return tw.Flush()
+}
+
+// Backtest replays the given snapshots through the buy and sell logic of the bot, using the given configuration.
+// Time is simulated, so the backtest runs as fast as possible while the bot still observes the configured intervals.
+// Orders are simulated at the replayed prices; no real market or local database is ever touched.
+func Backtest(ctx context.Context, config config.Configuration, snapshots []market.Snapshot) (BacktestReport, error) {
+	if len(snapshots) == 0 {
+		return BacktestReport{}, errors.New("no snapshots to replay")
+	}
+
+	name := snapshots[0].Market
+	if name == "" {
+		name = replayMarketName
+	}
+
+	sim := clock.NewSimulated(time.Time{})
+	replay := market.NewReplay(name, snapshots, sim)
+	db := database.NewMemoryDatabase(sim)
+
+	// Orders are simulated at the replayed prices, using the virtual wallet of test mode.
+	config.EnableTestMode = true
+
+	b := New(&config, market.NewSimulator(replay, config, sim), db, sim)
+	defer b.flushLogs()
+
+	recheckInterval := time.Duration(0)
+	if config.TradingOptions.RecheckInterval != 0 {
+		recheckInterval = utils.CalculateTimeDuration(config.TradingOptions.TimeDifference, config.TradingOptions.RecheckInterval)
+	}
+	sellTimeout := time.Second * time.Duration(config.TradingOptions.SellTimeout)
+
+	// next returns the time at which a loop that runs at the given interval should run again.
+	// Without an interval the bot checks as fast as it can, which corresponds to every snapshot.
+	next := func(t time.Time, interval time.Duration) time.Time {
+		if interval > 0 {
+			return t.Add(interval)
+		}
+		if n, ok := replay.Next(t); ok {
+			return n
+		}
+		return replay.End().Add(time.Nanosecond)
+	}
+
+	now := replay.Start()
+	nextBuy, nextSell, nextVolumeUpdate := now, now, now
+	initialized := false
+
+	for !now.After(replay.End()) {
+		if err := ctx.Err(); err != nil {
+			return BacktestReport{}, err
+		}
+
+		// The fill latency of simulated orders may have moved the clock past the next check already.
+		if now.After(sim.Now()) {
+			sim.Set(now)
+		}
+
+		if config.TradingOptions.MinQuoteVolumeTraded != 0.0 && !now.Before(nextVolumeUpdate) {
+			if err := b.updateVolumeTraded(ctx); err != nil {
+				return BacktestReport{}, fmt.Errorf("failed to update volume traded: %w", err)
+			}
+			nextVolumeUpdate = now.Add(volumeTradedUpdateInterval)
+		}
+
+		if !now.Before(nextBuy) {
+			if err := b.updateLatestCoins(ctx); err != nil {
+				return BacktestReport{}, fmt.Errorf("failed to update latest coins: %w", err)
+			}
+			// Just like the live bot, the first record only initializes the volatility window.
+			if initialized {
+				b.buyVolatileCoins(ctx)
+			}
+			initialized = true
+			nextBuy = next(now, recheckInterval)
+		}
+
+		if !now.Before(nextSell) {
+			coins, err := replay.GetCoins(ctx)
+			if err != nil {
+				return BacktestReport{}, fmt.Errorf("failed to fetch coins: %w", err)
+			}
+			b.sellBoughtCoins(ctx, coins)
+			nextSell = next(now, sellTimeout)
+		}
+
+		now = nextBuy
+		if nextSell.Before(now) {
+			now = nextSell
+		}
+	}
+
+	lastCoins, err := replay.GetCoins(ctx)
+	if err != nil {
+		return BacktestReport{}, err
+	}
+
+	return newBacktestReport(replay, db, lastCoins), nil
+}
+
+// newBacktestReport summarizes the orders that were saved to the given database during a backtest.
+func newBacktestReport(replay *market.Replay, db database.Database, lastCoins market.Coins) BacktestReport {
+	report := BacktestReport{
+		Start:         replay.Start(),
+		End:           replay.End(),
+		Trades:        db.GetOrders(models.SellOrder, replay.Name()),
+		OpenPositions: db.GetOrders(models.BuyOrder, replay.Name()),
+	}
+
+	var cumulativeProfitLoss, peak float64
+	for _, trade := range report.Trades {
+		profitLoss := *trade.RealizedProfitLoss
+		if profitLoss > 0 {
+			report.Wins++
+		} else {
+			report.Losses++
+		}
+
+		cumulativeProfitLoss += profitLoss
+		peak = max(peak, cumulativeProfitLoss)
+		report.MaxDrawdown = max(report.MaxDrawdown, peak-cumulativeProfitLoss)
+	}
+	report.NetProfitLoss = cumulativeProfitLoss
+
+	for _, position := range report.OpenPositions {
+		if coin, ok := lastCoins[position.Symbol]; ok {
+			report.UnrealizedProfitLoss += (coin.Price - position.Price) * position.Volume
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBacktest(t *testing.T) {
+	c := config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			PairWith:        "USDT",
+			Quantity:        100,
+			TimeDifference:  2,
+			RecheckInterval: 2, // check every minute
+			SellTimeout:     60,
+			ChangeInPrice:   10,
+			TakeProfit:      5,
+			StopLoss:        5,
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := func(minutes int, btc, eth float64) market.Snapshot {
+		return market.Snapshot{
+			Market: "binance",
+			Time:   start.Add(time.Duration(minutes) * time.Minute),
+			Coins: market.Coins{
+				"BTCUSDT": {Symbol: "BTCUSDT", Price: btc},
+				"ETHUSDT": {Symbol: "ETHUSDT", Price: eth},
+			},
+		}
+	}
+
+	report, err := Backtest(context.Background(), c, []market.Snapshot{
+		snapshot(0, 100, 100),
+		snapshot(1, 115, 120), // both coins are volatile and get bought
+		snapshot(2, 125, 121), // BTC reaches its take profit
+		snapshot(3, 125, 110), // ETH reaches its stop loss
+		snapshot(4, 125, 110),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, start, report.Start)
+	assert.Equal(t, start.Add(4*time.Minute), report.End)
+	assert.Equal(t, 2, len(report.Trades))
+	assert.Equal(t, "BTCUSDT", report.Trades[0].Symbol)
+	assert.Equal(t, "ETHUSDT", report.Trades[1].Symbol)
+	assert.Equal(t, 1, report.Wins)
+	assert.Equal(t, 1, report.Losses)
+	assert.Equal(t, 50.0, report.WinRate())
+	assert.InDelta(t, 100.0/115*10-100.0/120*10, report.NetProfitLoss, 1e-9)
+	assert.InDelta(t, 100.0/120*10, report.MaxDrawdown, 1e-9)
+	assert.Equal(t, 0, len(report.OpenPositions))
+
+	returns := report.Returns()
+	assert.Equal(t, 2, len(returns))
+	assert.InDelta(t, 10.0/115, returns[0], 1e-9)
+	assert.InDelta(t, -10.0/120, returns[1], 1e-9)
+	assert.InDelta(t, 0.01504, report.SharpeRatio(), 1e-4)
+}
+
+func TestBacktest_without_snapshots(t *testing.T) {
+	_, err := Backtest(context.Background(), config.Configuration{}, nil)
+	assert.Error(t, err)
+}
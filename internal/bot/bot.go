@@ -2,7 +2,9 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/sleeyax/voltra/internal/clock"
 	"github.com/sleeyax/voltra/internal/config"
 	"github.com/sleeyax/voltra/internal/database"
 	"github.com/sleeyax/voltra/internal/database/models"
@@ -12,30 +14,52 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"math"
+	"strings"
 	"sync"
 	"time"
 )
 
 const significantPriceChangeThreshold = 0.8
 
+// volumeTradedUpdateInterval is the interval at which the volume traded of all coins is refreshed.
+// We want to update the volume traded every hour to avoid API rate limiting. This can be a configurable option in the future.
+const volumeTradedUpdateInterval = 1 * time.Hour
+
+// symbolInfoUpdateInterval is the interval at which the symbol info of all coins is refreshed.
+// Exchanges rarely change the filters or trading status of a symbol, so this doesn't need to happen often.
+const symbolInfoUpdateInterval = 1 * time.Hour
+
+// startupRetryInterval is the time to wait before retrying to load the data the bot can't start without, e.g. during an outage of the market.
+// Transient errors are already retried by the market itself (see market.Retry), so this only kicks in once those retries are exhausted.
+const startupRetryInterval = 30 * time.Second
+
 type Bot struct {
 	market           market.Market
 	db               database.Database
 	volatilityWindow *VolatilityWindow
 	tradeVolumes     market.TradeVolumes
 	config           *config.Configuration
+	clock            clock.Clock
 	botLog           *zap.SugaredLogger
 	buyLog           *zap.SugaredLogger
 	sellLog          *zap.SugaredLogger
+
+	// The preloaded symbol info of all coins, which is refreshed by the buy goroutine while the sell goroutine reads it.
+	symbolInfo   map[string]market.SymbolInfo
+	symbolInfoMu sync.RWMutex
 }
 
-func New(config *config.Configuration, market market.Market, db database.Database) *Bot {
-	sugaredLogger := createLogger(config.LoggingOptions).Named("bot")
+// New creates a new bot that trades on the given market according to the given configuration.
+// All time-dependent logic is based on the given clock, which should be clock.Real outside of tests and simulations.
+// Multiple bots can share the same database, as long as they trade on different markets. Their logs are tagged with the name of their market.
+func New(config *config.Configuration, market market.Market, db database.Database, clock clock.Clock) *Bot {
+	sugaredLogger := createLogger(config.LoggingOptions).Named("bot").With("market", market.Name())
 	return &Bot{
 		market:           market,
 		db:               db,
-		volatilityWindow: NewVolatilityWindow(config.TradingOptions.RecheckInterval),
+		volatilityWindow: NewVolatilityWindow(config.TradingOptions.RecheckInterval, clock),
 		config:           config,
+		clock:            clock,
 		botLog:           sugaredLogger,
 		buyLog:           sugaredLogger.Named("buy"),
 		sellLog:          sugaredLogger.Named("sell"),
@@ -54,8 +78,21 @@ func (b *Bot) Start(ctx context.Context) {
 	b.botLog.Info("Bot started. Press CTRL + C to quit.")
 
 	if b.config.TradingOptions.MinQuoteVolumeTraded != 0.0 {
-		if err := b.updateVolumeTraded(ctx); err != nil {
-			panic(fmt.Sprintf("failed to load initial volume traded: %s", err))
+		if err := b.retryUntilDone(ctx, "initial volume traded", b.updateVolumeTraded); err != nil {
+			b.botLog.Errorf("Bot stopped before the initial volume traded could be loaded: %s.", err)
+			return
+		}
+	}
+
+	if err := b.updateSymbolInfo(ctx); err != nil {
+		b.botLog.Errorf("Failed to load symbol info. Falling back to fetching it per trade: %s.", err)
+	}
+
+	b.checkFunds(ctx)
+
+	if policy := b.config.ReconciliationPolicy; !b.config.EnableTestMode && (policy == config.ReportPolicy || policy == config.FixPolicy) {
+		if _, err := b.Reconcile(ctx, policy == config.FixPolicy); err != nil {
+			b.botLog.Errorf("Failed to reconcile the open positions: %s.", err)
 		}
 	}
 
@@ -75,120 +112,172 @@ func (b *Bot) buy(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 	b.buyLog.Debug("Watching coins to buy.")
 
-	if err := b.updateLatestCoins(ctx); err != nil {
-		panic(fmt.Sprintf("failed to load initial latest coins: %s", err))
+	if err := b.retryUntilDone(ctx, "initial latest coins", b.updateLatestCoins); err != nil {
+		b.buyLog.Errorf("Bot stopped before the initial latest coins could be loaded: %s.", err)
+		return
 	}
 
-	ticker := time.NewTicker(1 * time.Hour)
+	ticker := b.clock.NewTicker(volumeTradedUpdateInterval)
 	defer ticker.Stop()
 
+	symbolInfoTicker := b.clock.NewTicker(symbolInfoUpdateInterval)
+	defer symbolInfoTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			b.buyLog.Debug("Bot stopped buying coins.")
 			return
-		case <-ticker.C:
-			// We want to update the volume traded every hour to avoid API rate limiting. This can be a configurable option in the future.
+		case <-ticker.C():
 			if err := b.updateVolumeTraded(ctx); err != nil {
 				b.buyLog.Errorf("Failed to update volume traded: %s.", err)
 				continue
 			}
+		case <-symbolInfoTicker.C():
+			if err := b.updateSymbolInfo(ctx); err != nil {
+				b.buyLog.Errorf("Failed to update symbol info: %s.", err)
+				continue
+			}
 		default:
 			// Wait until the next recheck interval.
 			lastRecord := b.volatilityWindow.GetLatestRecord()
 			delta := utils.CalculateTimeDuration(b.config.TradingOptions.TimeDifference, b.config.TradingOptions.RecheckInterval)
-			if time.Since(lastRecord.time) < delta {
-				interval := delta - time.Since(lastRecord.time)
+			if b.clock.Since(lastRecord.time) < delta {
+				interval := delta - b.clock.Since(lastRecord.time)
 				b.buyLog.Debugf("Waiting %s.", interval.Round(time.Second))
-				time.Sleep(interval)
+				if err := b.clock.SleepContext(ctx, interval); err != nil {
+					// The bot is stopping.
+					continue
+				}
 			}
 
 			// Fetch the latest coins again after the waiting period.
 			if err := b.updateLatestCoins(ctx); err != nil {
 				b.buyLog.Errorf("Failed to update latest coins: %s.", err)
+				// The latest record hasn't changed, so wait a full interval before trying again unless the bot is stopping.
+				_ = b.clock.SleepContext(ctx, delta)
 				continue
 			}
 
-			// Identify volatile coins in the current time window and trade them if any are found.
-			volatileCoins := b.volatilityWindow.IdentifyVolatileCoins(b.config.TradingOptions.ChangeInPrice)
-			b.buyLog.Debugf("Found %d volatile coins.", len(volatileCoins))
-			for _, volatileCoin := range volatileCoins {
-
-				if !volatileCoin.Coin.IsAvailableForTrading(b.config.TradingOptions.AllowList, b.config.TradingOptions.DenyList, b.config.TradingOptions.PairWith, b.config.TradingOptions.MinQuoteVolumeTraded) {
-					b.buyLog.Debugf("Coin %s is not available for trading. Skipping.", volatileCoin.Symbol)
-					continue
-				}
+			b.buyVolatileCoins(ctx)
+		}
+	}
+}
 
-				b.buyLog.Infof("Coin %s has gained %.2f%% within the last %d minutes.", volatileCoin.Symbol, volatileCoin.Percentage, b.config.TradingOptions.TimeDifference)
+// retryUntilDone calls the given function until it succeeds or the given context is cancelled, waiting startupRetryInterval between attempts.
+// Returns the last error if the context was cancelled first.
+func (b *Bot) retryUntilDone(ctx context.Context, name string, f func(ctx context.Context) error) error {
+	for {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
 
-				// Skip if the coin has already been bought.
-				if b.db.HasOrder(models.BuyOrder, b.market.Name(), volatileCoin.Symbol) {
-					b.buyLog.Warnf("Already bought %s. Skipping.", volatileCoin.Symbol)
-					continue
-				}
+		b.botLog.Errorf("Failed to load %s, retrying in %s: %s.", name, startupRetryInterval, err)
+		if b.clock.SleepContext(ctx, startupRetryInterval) != nil {
+			return err
+		}
+	}
+}
 
-				// Skip if the max amount of buy orders has been reached.
-				if maxBuyOrders := int64(b.config.TradingOptions.MaxCoins); maxBuyOrders != 0 && b.db.CountOrders(models.BuyOrder, b.market.Name()) >= maxBuyOrders {
-					b.buyLog.Warnf("Max amount of buy orders reached. Skipping.")
-					continue
-				}
+// buyVolatileCoins identifies volatile coins in the current time window and buys them if they meet the configured criteria.
+func (b *Bot) buyVolatileCoins(ctx context.Context) {
+	volatileCoins := b.volatilityWindow.IdentifyVolatileCoins(b.config.TradingOptions.ChangeInPrice)
+	b.buyLog.Debugf("Found %d volatile coins.", len(volatileCoins))
+	for _, volatileCoin := range volatileCoins {
 
-				// Skip if the coin has been sold very recently (within the cool-off period)
-				if coolOffDelay := time.Duration(b.config.TradingOptions.CoolOffDelay) * time.Minute; coolOffDelay != 0 {
-					lastOrder, ok := b.db.GetLastOrder(models.SellOrder, b.market.Name(), volatileCoin.Symbol)
-					if ok && time.Since(lastOrder.CreatedAt) < coolOffDelay {
-						b.buyLog.Warnf("Already bought %s within the configured cool-off period of %s. Skipping.", volatileCoin.Symbol, coolOffDelay)
-						continue
-					}
-				}
+		if !volatileCoin.Coin.IsAvailableForTrading(b.config.TradingOptions.AllowList, b.config.TradingOptions.DenyList, b.config.TradingOptions.PairWith, b.config.TradingOptions.MinQuoteVolumeTraded) {
+			b.buyLog.Debugf("Coin %s is not available for trading. Skipping.", volatileCoin.Symbol)
+			continue
+		}
 
-				// Determine the correct volume to buy based on the configured quantity.
-				volume, err := b.convertVolume(ctx, b.config.TradingOptions.Quantity, volatileCoin)
-				if err != nil {
-					b.buyLog.Errorf("Failed to convert volume. Skipping the trade: %s", err)
-					continue
-				}
+		b.buyLog.Infof("Coin %s has gained %.2f%% within the last %d minutes.", volatileCoin.Symbol, volatileCoin.Percentage, b.config.TradingOptions.TimeDifference)
 
-				b.buyLog.Infow(fmt.Sprintf("Buying %g %s of %s.", volume, b.config.TradingOptions.PairWith, volatileCoin.Symbol),
-					"volume", volume,
-					"pair_with", b.config.TradingOptions.PairWith,
-					"symbol", volatileCoin.Symbol,
-					"price", volatileCoin.Price,
-					"percentage", volatileCoin.Percentage,
-					"testMode", b.config.EnableTestMode,
-				)
-
-				order := models.Order{
-					Market:     b.market.Name(),
-					Type:       models.BuyOrder,
-					Volume:     volume,
-					TakeProfit: &b.config.TradingOptions.TakeProfit,
-					StopLoss:   &b.config.TradingOptions.StopLoss,
-				}
+		// Skip if the coin has already been bought.
+		if b.db.HasOrder(models.BuyOrder, b.market.Name(), volatileCoin.Symbol) {
+			b.buyLog.Warnf("Already bought %s. Skipping.", volatileCoin.Symbol)
+			continue
+		}
 
-				// Pretend to buy the coin and save the order if test mode is enabled.
-				if b.config.EnableTestMode {
-					order.Order = market.Order{
-						OrderID:         0,
-						Symbol:          volatileCoin.Symbol,
-						Price:           volatileCoin.Price,
-						TransactionTime: time.Now(),
-					}
-					order.IsTestMode = true
-				} else {
-					// Otherwise, buy the coin and save the real order.
-					buyOrder, err := b.market.Buy(ctx, volatileCoin.Symbol, volume)
-					if err != nil {
-						b.buyLog.Errorf("Failed to buy %s: %s.", volatileCoin.Symbol, err)
-						continue
-					}
-
-					order.Order = buyOrder
-				}
+		// Skip if the max amount of buy orders has been reached.
+		if maxBuyOrders := int64(b.config.TradingOptions.MaxCoins); maxBuyOrders != 0 && b.db.CountOrders(models.BuyOrder, b.market.Name()) >= maxBuyOrders {
+			b.buyLog.Warnf("Max amount of buy orders reached. Skipping.")
+			continue
+		}
 
-				b.db.SaveOrder(order)
+		// Skip if the coin has been sold very recently (within the cool-off period)
+		if coolOffDelay := time.Duration(b.config.TradingOptions.CoolOffDelay) * time.Minute; coolOffDelay != 0 {
+			lastOrder, ok := b.db.GetLastOrder(models.SellOrder, b.market.Name(), volatileCoin.Symbol)
+			if ok && b.clock.Since(lastOrder.CreatedAt) < coolOffDelay {
+				b.buyLog.Warnf("Already bought %s within the configured cool-off period of %s. Skipping.", volatileCoin.Symbol, coolOffDelay)
+				continue
 			}
 		}
+
+		// Determine the correct volume to buy based on the configured quantity.
+		volume, err := b.convertVolume(ctx, b.config.TradingOptions.Quantity, volatileCoin)
+		if err != nil {
+			b.buyLog.Errorf("Failed to convert volume of %s. Skipping the trade: %s.", volatileCoin.Symbol, err)
+			continue
+		}
+
+		// Skip if there are not enough funds to buy the coin.
+		cost := volume * volatileCoin.Price
+		funds, err := b.availableFunds(ctx)
+		if err != nil {
+			b.buyLog.Errorf("Failed to fetch the available funds. Skipping the trade of %s: %s.", volatileCoin.Symbol, err)
+			continue
+		}
+		if funds < cost {
+			b.buyLog.Warnf("Insufficient funds to buy %s for %.2f %s, only %.2f %s is available. Skipping.", volatileCoin.Symbol, cost, b.config.TradingOptions.PairWith, math.Max(funds, 0), b.config.TradingOptions.PairWith)
+			continue
+		}
+
+		b.buyLog.Infow(fmt.Sprintf("Buying %g %s of %s.", volume, b.config.TradingOptions.PairWith, volatileCoin.Symbol),
+			"volume", volume,
+			"pair_with", b.config.TradingOptions.PairWith,
+			"symbol", volatileCoin.Symbol,
+			"price", volatileCoin.Price,
+			"percentage", volatileCoin.Percentage,
+			"testMode", b.config.EnableTestMode,
+		)
+
+		buyOrder, err := b.market.Buy(ctx, volatileCoin.Symbol, volume)
+		if err != nil {
+			b.buyLog.Errorf("Failed to buy %s: %s.", volatileCoin.Symbol, err)
+			continue
+		}
+
+		// The order may have been cancelled or expired without filling anything, e.g. when a limit order timed out.
+		if buyOrder.FilledQuantity <= 0 {
+			b.buyLog.Warnf("The buy order of %s wasn't filled (status %s). Skipping.", volatileCoin.Symbol, buyOrder.Status)
+			continue
+		}
+
+		if buyOrder.Status == market.PartiallyFilledOrderStatus {
+			b.buyLog.Warnf("Only bought %g of %g %s.", buyOrder.FilledQuantity, volume, volatileCoin.Symbol)
+		}
+		volume = buyOrder.FilledQuantity
+		// Commissions paid in the coin itself are deducted from the bought quantity, so we hold less than what was filled.
+		volume -= buyOrder.Commissions[strings.TrimSuffix(volatileCoin.Symbol, b.config.TradingOptions.PairWith)]
+		// Any dust that was left behind by the previous sale of the coin is sold along with this position.
+		volume += b.takeDust(volatileCoin.Symbol)
+
+		order := models.Order{
+			Order:      buyOrder,
+			Market:     b.market.Name(),
+			Type:       models.BuyOrder,
+			Volume:     volume,
+			TakeProfit: &b.config.TradingOptions.TakeProfit,
+			StopLoss:   &b.config.TradingOptions.StopLoss,
+			IsTestMode: b.config.EnableTestMode,
+		}
+		order.OCO = b.placeOCO(ctx, order)
+
+		b.db.SaveOrder(order)
 	}
 }
 
@@ -202,173 +291,373 @@ func (b *Bot) sell(ctx context.Context, wg *sync.WaitGroup) {
 			b.sellLog.Debug("Bot stopped selling coins.")
 			return
 		default:
-			coins, err := b.market.GetCoins(ctx)
-			if err != nil {
+			// Always wait before the next attempt, even if fetching the coins failed, so that a failing market isn't hammered with requests.
+			if coins, err := b.market.GetCoins(ctx); err != nil {
 				b.sellLog.Errorf("Failed to fetch coins: %s.", err)
-				continue
+			} else {
+				b.sellBoughtCoins(ctx, coins)
 			}
 
-			orders := b.db.GetOrders(models.BuyOrder, b.market.Name())
-			for _, boughtCoin := range orders {
-				takeProfit := boughtCoin.Price + (boughtCoin.Price*(*boughtCoin.TakeProfit))/100
-				stopLoss := boughtCoin.Price + (boughtCoin.Price*(-1*math.Abs(*boughtCoin.StopLoss)))/100
-				currentPrice := coins[boughtCoin.Symbol].Price
-				buyPrice := boughtCoin.Price
-				priceChangePercentage := (currentPrice - buyPrice) / buyPrice * 100
-				sellFee := currentPrice * (b.config.TradingOptions.TradingFeeTaker / 100)
-				buyFee := buyPrice * (b.config.TradingOptions.TradingFeeTaker / 100)
-				fees := buyFee + sellFee
-
-				// Check that the price is above the take profit and readjust SL and TP accordingly if trialing stop loss is used.
-				if b.config.TradingOptions.TrailingStopOptions.Enable && currentPrice >= takeProfit {
-					trailingStopOptions := b.config.TradingOptions.TrailingStopOptions
-
-					// Calculate trailing stop loss and take profit.
-					tp := priceChangePercentage + trailingStopOptions.TrailingTakeProfit
-					var sl float64
-					var msg string
-					if priceChangePercentage >= significantPriceChangeThreshold {
-						// If the price has changed much we make the stop loss trail closely match the take profit.
-						// This way we don't lose this increase in price if it falls back.
-						sl = tp - trailingStopOptions.TrailingStopLoss
-						msg = "Large change in price occurred."
-					} else {
-						// If the price has changed little we make the stop loss trail loosely match the take profit.
-						// This way we don't get locked out of the trade prematurely.
-						sl = *boughtCoin.TakeProfit - trailingStopOptions.TrailingStopLoss
-						msg = "Small change in price occurred."
-					}
-					if sl <= 0 {
-						// Revert to the current stop loss if the calculated stop loss ends up being negative.
-						sl = *boughtCoin.StopLoss
-						msg += " (stop loss became negative, reverted)"
-					}
-					b.sellLog.Debugw(
-						msg,
-						"significantPriceChangeThreshold", significantPriceChangeThreshold,
-						"priceChangePercentage", priceChangePercentage,
-						"trailingStopLoss", trailingStopOptions.TrailingStopLoss,
-						"trailingTakeProfit", trailingStopOptions.TrailingTakeProfit,
-						"currentStopLoss", *boughtCoin.StopLoss,
-						"currentTakeProfit", *boughtCoin.TakeProfit,
-						"nextStopLoss", sl,
-						"nextTakeProfit", tp,
-					)
-
-					boughtCoin.StopLoss = &sl
-					boughtCoin.TakeProfit = &tp
-
-					b.sellLog.Debugf("Price of %s reached more than the trading profit (TP). Adjusting stop loss (SL) to %g and trading profit (TP) to %g.", boughtCoin.Symbol, sl, tp)
-
-					b.db.SaveOrder(boughtCoin)
+			b.clock.Sleep(time.Second * time.Duration(b.config.TradingOptions.SellTimeout))
+		}
+	}
+}
+
+// sellBoughtCoins checks the bought coins against the given current coin prices and sells them when the stop loss or take profit is reached.
+// Trailing stop loss and take profit are readjusted here as well, if enabled.
+// Coins that are protected by an OCO order are sold by the market itself, so the sale is only recorded once the OCO order has been filled.
+func (b *Bot) sellBoughtCoins(ctx context.Context, coins market.Coins) {
+	orders := b.db.GetOrders(models.BuyOrder, b.market.Name())
+	for _, boughtCoin := range orders {
+		takeProfit := takeProfitPrice(boughtCoin)
+		stopLoss := stopLossPrice(boughtCoin)
+		currentPrice := coins[boughtCoin.Symbol].Price
+		buyPrice := boughtCoin.Price
+		priceChangePercentage := (currentPrice - buyPrice) / buyPrice * 100
+		fees := b.tradingFee(boughtCoin.Order, boughtCoin.Volume, coins) + currentPrice*boughtCoin.Volume*b.config.TradingOptions.TradingFeeTaker/100
+		estimatedProfitLoss := (currentPrice-buyPrice)*boughtCoin.Volume - fees
+
+		// Check whether the market has sold the coin already.
+		if boughtCoin.OCO.OrderListID != 0 {
+			sellOrder, filled, err := b.market.GetOCOFill(ctx, boughtCoin.OCO)
+			if errors.Is(err, market.OCOCanceledError) {
+				b.sellLog.Warnf("The OCO order of %s has been cancelled. Monitoring the price instead.", boughtCoin.Symbol)
+				boughtCoin.OCO = market.OCO{}
+				b.db.SaveOrder(boughtCoin)
+			} else if err != nil {
+				b.sellLog.Errorf("Failed to check the OCO order of %s: %s.", boughtCoin.Symbol, err)
+				continue
+			} else if filled {
+				b.recordSellOrder(boughtCoin, sellOrder, boughtCoin.Volume, coins, currentPrice, priceChangePercentage, estimatedProfitLoss)
+				continue
+			}
+		}
 
+		// Check that the price is above the take profit and readjust SL and TP accordingly if trialing stop loss is used.
+		if b.config.TradingOptions.TrailingStopOptions.Enable && currentPrice >= takeProfit {
+			trailingStopOptions := b.config.TradingOptions.TrailingStopOptions
+
+			// Calculate trailing stop loss and take profit.
+			tp := priceChangePercentage + trailingStopOptions.TrailingTakeProfit
+			var sl float64
+			var msg string
+			if priceChangePercentage >= significantPriceChangeThreshold {
+				// If the price has changed much we make the stop loss trail closely match the take profit.
+				// This way we don't lose this increase in price if it falls back.
+				sl = tp - trailingStopOptions.TrailingStopLoss
+				msg = "Large change in price occurred."
+			} else {
+				// If the price has changed little we make the stop loss trail loosely match the take profit.
+				// This way we don't get locked out of the trade prematurely.
+				sl = *boughtCoin.TakeProfit - trailingStopOptions.TrailingStopLoss
+				msg = "Small change in price occurred."
+			}
+			if sl <= 0 {
+				// Revert to the current stop loss if the calculated stop loss ends up being negative.
+				sl = *boughtCoin.StopLoss
+				msg += " (stop loss became negative, reverted)"
+			}
+			b.sellLog.Debugw(
+				msg,
+				"significantPriceChangeThreshold", significantPriceChangeThreshold,
+				"priceChangePercentage", priceChangePercentage,
+				"trailingStopLoss", trailingStopOptions.TrailingStopLoss,
+				"trailingTakeProfit", trailingStopOptions.TrailingTakeProfit,
+				"currentStopLoss", *boughtCoin.StopLoss,
+				"currentTakeProfit", *boughtCoin.TakeProfit,
+				"nextStopLoss", sl,
+				"nextTakeProfit", tp,
+			)
+
+			// Replace the OCO order with one at the new stop loss and take profit.
+			if boughtCoin.OCO.OrderListID != 0 {
+				if err := b.market.CancelOCO(ctx, boughtCoin.OCO); err != nil {
+					b.sellLog.Errorf("Failed to cancel the OCO order of %s: %s.", boughtCoin.Symbol, err)
 					continue
 				}
+			}
+
+			boughtCoin.StopLoss = &sl
+			boughtCoin.TakeProfit = &tp
+			if boughtCoin.OCO.OrderListID != 0 {
+				boughtCoin.OCO = b.placeOCO(ctx, boughtCoin)
+			}
 
-				// If the price of the coin is below the stop loss or above take profit then sell it.
-				if currentPrice <= stopLoss || currentPrice >= takeProfit {
-					estimatedProfitLoss := (currentPrice-buyPrice)*boughtCoin.Volume - fees
-					estimatedProfitLossPercentage := estimatedProfitLoss / (buyPrice * boughtCoin.Volume) * 100
-					msg := fmt.Sprintf(
-						"Selling %g %s. Estimated %s: $%.2f %.2f%%",
-						boughtCoin.Volume,
-						boughtCoin.Symbol,
-						b.getProfitOrLossText(priceChangePercentage),
-						estimatedProfitLoss,
-						estimatedProfitLossPercentage,
-					)
-
-					b.sellLog.Infow(
-						msg,
-						"buyPrice", buyPrice,
-						"currentPrice", currentPrice,
-						"priceChangePercentage", priceChangePercentage,
-						"tradingFeeMaker", b.config.TradingOptions.TradingFeeMaker,
-						"tradingFeeTaker", b.config.TradingOptions.TradingFeeTaker,
-						"fees", fees,
-						"quantity", b.config.TradingOptions.Quantity,
-						"testMode", b.config.EnableTestMode,
-					)
-
-					order := models.Order{
-						Market:                b.market.Name(),
-						Type:                  models.SellOrder,
-						Volume:                boughtCoin.Volume,
-						PriceChangePercentage: &priceChangePercentage,
-						EstimatedProfitLoss:   &estimatedProfitLoss,
-					}
-
-					if b.config.EnableTestMode {
-						order.Order = market.Order{
-							OrderID:         0,
-							Symbol:          boughtCoin.Symbol,
-							TransactionTime: time.Now(),
-							Price:           currentPrice,
-						}
-						order.IsTestMode = true
-					} else {
-						sellOrder, err := b.market.Sell(ctx, boughtCoin.Symbol, boughtCoin.Volume)
-						if err != nil {
-							b.sellLog.Errorf("Failed to sell %s: %s.", boughtCoin.Symbol, err)
-							continue
-						}
-
-						order.Order = sellOrder
-					}
-
-					// Determine actual profit/loss of the executed order.
-					sellPrice := order.Price
-					sellFee = sellPrice * (b.config.TradingOptions.TradingFeeTaker / 100)
-					priceChangePercentage = (sellPrice - buyPrice) / buyPrice * 100
-					fees = buyFee + sellFee
-					profitLoss := (sellPrice-buyPrice)*order.Volume - fees
-					profitLossPercentage := profitLoss / (buyPrice * order.Volume) * 100
-					order.RealizedProfitLoss = &profitLoss
-					msg = fmt.Sprintf(
-						"Sold %g %s. %s: $%.2f %.2f%%",
-						boughtCoin.Volume,
-						boughtCoin.Symbol,
-						cases.Title(language.English).String(b.getProfitOrLossText(profitLossPercentage)),
-						profitLoss,
-						profitLossPercentage,
-					)
-
-					b.sellLog.Infow(
-						msg,
-						"buyPrice", buyPrice,
-						"currentPrice", currentPrice,
-						"sellPrice", sellPrice,
-						"priceChangePercentage", priceChangePercentage,
-						"tradingFeeMaker", b.config.TradingOptions.TradingFeeMaker,
-						"tradingFeeTaker", b.config.TradingOptions.TradingFeeTaker,
-						"fees", fees,
-						"quantity", b.config.TradingOptions.Quantity,
-						"testMode", b.config.EnableTestMode,
-					)
-
-					if b.config.TradingOptions.EnableDynamicQuantity {
-						b.config.TradingOptions.Quantity += profitLoss / float64(b.config.TradingOptions.MaxCoins)
-					}
-
-					b.db.SaveOrder(order)
-					b.db.DeleteOrder(boughtCoin)
+			b.sellLog.Debugf("Price of %s reached more than the trading profit (TP). Adjusting stop loss (SL) to %g and trading profit (TP) to %g.", boughtCoin.Symbol, sl, tp)
 
+			b.db.SaveOrder(boughtCoin)
+
+			continue
+		}
+
+		// If the price of the coin is below the stop loss or above take profit then sell it.
+		if currentPrice <= stopLoss || currentPrice >= takeProfit {
+			// The market should have sold the coin already, so only sell it if the OCO order can still be cancelled.
+			if boughtCoin.OCO.OrderListID != 0 {
+				if err := b.market.CancelOCO(ctx, boughtCoin.OCO); err != nil {
+					b.sellLog.Errorf("Failed to cancel the OCO order of %s: %s.", boughtCoin.Symbol, err)
 					continue
 				}
+				boughtCoin.OCO = market.OCO{}
+			}
+
+			// Only whole steps can be sold, the rest is left behind as dust.
+			volume, err := b.sellableVolume(ctx, boughtCoin)
+			if err != nil {
+				b.sellLog.Errorf("Failed to determine the volume of %s to sell: %s.", boughtCoin.Symbol, err)
+				b.db.SaveOrder(boughtCoin)
+				continue
+			}
+			if volume == 0 {
+				b.sellLog.Warnf("Only %g %s of dust is left, which can't be sold. Removing the position.", boughtCoin.Volume, boughtCoin.Symbol)
+				b.addDust(boughtCoin.Symbol, boughtCoin.Volume)
+				b.db.DeleteOrder(boughtCoin)
+				continue
+			}
+
+			estimatedProfitLossPercentage := estimatedProfitLoss / (buyPrice * boughtCoin.Volume) * 100
+			msg := fmt.Sprintf(
+				"Selling %g %s. Estimated %s: $%.2f %.2f%%",
+				volume,
+				boughtCoin.Symbol,
+				b.getProfitOrLossText(priceChangePercentage),
+				estimatedProfitLoss,
+				estimatedProfitLossPercentage,
+			)
+
+			b.sellLog.Infow(
+				msg,
+				"buyPrice", buyPrice,
+				"currentPrice", currentPrice,
+				"priceChangePercentage", priceChangePercentage,
+				"tradingFeeMaker", b.config.TradingOptions.TradingFeeMaker,
+				"tradingFeeTaker", b.config.TradingOptions.TradingFeeTaker,
+				"fees", fees,
+				"quantity", b.config.TradingOptions.Quantity,
+				"testMode", b.config.EnableTestMode,
+			)
+
+			sellOrder, err := b.market.Sell(ctx, boughtCoin.Symbol, volume)
+			if err != nil {
+				b.sellLog.Errorf("Failed to sell %s: %s.", boughtCoin.Symbol, err)
+				// Keep track of the cancelled OCO order.
+				b.db.SaveOrder(boughtCoin)
+				continue
+			}
 
-				b.sellLog.Debugw(
-					fmt.Sprintf("Price of %s is %.2f%% away from the buy price. Hodl.", boughtCoin.Symbol, priceChangePercentage),
-					"symbol", boughtCoin.Symbol,
-					"buyPrice", buyPrice,
-					"currentPrice", currentPrice,
-					"takeProfit", takeProfit,
-					"stopLoss", stopLoss,
-				)
+			// Keep the position if nothing was sold, e.g. because the order expired, so that it's sold later on.
+			if sellOrder.FilledQuantity <= 0 {
+				b.sellLog.Warnf("The sell order of %s wasn't filled (status %s). Keeping the position.", boughtCoin.Symbol, sellOrder.Status)
+				b.db.SaveOrder(boughtCoin)
+				continue
 			}
 
-			time.Sleep(time.Second * time.Duration(b.config.TradingOptions.SellTimeout))
+			b.recordSellOrder(boughtCoin, sellOrder, volume, coins, currentPrice, priceChangePercentage, estimatedProfitLoss)
+
+			continue
+		}
+
+		b.sellLog.Debugw(
+			fmt.Sprintf("Price of %s is %.2f%% away from the buy price. Hodl.", boughtCoin.Symbol, priceChangePercentage),
+			"symbol", boughtCoin.Symbol,
+			"buyPrice", buyPrice,
+			"currentPrice", currentPrice,
+			"takeProfit", takeProfit,
+			"stopLoss", stopLoss,
+		)
+	}
+}
+
+// recordSellOrder saves the given filled sell order of the given bought coin along with its realized profit or loss.
+// The volume is the quantity that was offered for sale, which is recorded if the market didn't report the filled quantity.
+// The bought coin is removed, unless the sell order was only partially filled. Any dust that couldn't be offered is kept track of in the sell order, so that it's sold along with the next buy of the coin.
+// Commissions that weren't paid in the quote currency are converted using the given current coin prices.
+func (b *Bot) recordSellOrder(boughtCoin models.Order, sellOrder market.Order, volume float64, coins market.Coins, currentPrice, priceChangePercentage, estimatedProfitLoss float64) {
+	order := models.Order{
+		Order:                 sellOrder,
+		Market:                b.market.Name(),
+		Type:                  models.SellOrder,
+		Volume:                volume,
+		PriceChangePercentage: &priceChangePercentage,
+		EstimatedProfitLoss:   &estimatedProfitLoss,
+		IsTestMode:            b.config.EnableTestMode,
+	}
+	if sellOrder.FilledQuantity > 0 {
+		order.Volume = sellOrder.FilledQuantity
+	}
+
+	// Determine actual profit/loss of the executed order.
+	buyPrice := boughtCoin.Price
+	sellPrice := order.Price
+	priceChangePercentage = (sellPrice - buyPrice) / buyPrice * 100
+	buyValue := quoteValue(boughtCoin.Order, order.Volume)
+	fees := b.tradingFee(boughtCoin.Order, order.Volume, coins) + b.tradingFee(order.Order, order.Volume, coins)
+	profitLoss := quoteValue(order.Order, order.Volume) - buyValue - fees
+	profitLossPercentage := profitLoss / buyValue * 100
+	order.RealizedProfitLoss = &profitLoss
+	msg := fmt.Sprintf(
+		"Sold %g %s. %s: $%.2f %.2f%%",
+		order.Volume,
+		boughtCoin.Symbol,
+		cases.Title(language.English).String(b.getProfitOrLossText(profitLossPercentage)),
+		profitLoss,
+		profitLossPercentage,
+	)
+
+	b.sellLog.Infow(
+		msg,
+		"buyPrice", buyPrice,
+		"currentPrice", currentPrice,
+		"sellPrice", sellPrice,
+		"priceChangePercentage", priceChangePercentage,
+		"tradingFeeMaker", b.config.TradingOptions.TradingFeeMaker,
+		"tradingFeeTaker", b.config.TradingOptions.TradingFeeTaker,
+		"fees", fees,
+		"quantity", b.config.TradingOptions.Quantity,
+		"testMode", b.config.EnableTestMode,
+	)
+
+	if b.config.TradingOptions.EnableDynamicQuantity {
+		b.config.TradingOptions.Quantity += profitLoss / float64(b.config.TradingOptions.MaxCoins)
+	}
+
+	// Keep tracking whatever couldn't be sold, so that it's sold later on.
+	if sellOrder.Status == market.PartiallyFilledOrderStatus && order.Volume < boughtCoin.Volume {
+		b.db.SaveOrder(order)
+		b.sellLog.Warnf("Only sold %g of %g %s.", order.Volume, boughtCoin.Volume, boughtCoin.Symbol)
+		boughtCoin.Volume -= order.Volume
+		b.db.SaveOrder(boughtCoin)
+		return
+	}
+
+	if dust := boughtCoin.Volume - order.Volume; dust > 0 {
+		b.sellLog.Debugf("Leaving %g %s of dust behind until the next buy.", dust, boughtCoin.Symbol)
+		order.Dust = dust
+	}
+	b.db.SaveOrder(order)
+	b.db.DeleteOrder(boughtCoin)
+}
+
+// addDust adds the given volume of the given coin to the dust of its last sell order, so that it's sold along with the next buy of the coin (see takeDust).
+func (b *Bot) addDust(symbol string, dust float64) {
+	sellOrder, ok := b.db.GetLastOrder(models.SellOrder, b.market.Name(), symbol)
+	if !ok || sellOrder.IsTestMode != b.config.EnableTestMode {
+		b.sellLog.Warnf("Leaving %g %s of dust behind, because %s has never been sold.", dust, symbol, symbol)
+		return
+	}
+
+	sellOrder.Dust += dust
+	b.db.SaveOrder(sellOrder)
+}
+
+// takeDust returns the dust that was left behind by the last sell order of the given coin and resets it, so that it's only added to a single position.
+func (b *Bot) takeDust(symbol string) float64 {
+	sellOrder, ok := b.db.GetLastOrder(models.SellOrder, b.market.Name(), symbol)
+	if !ok || sellOrder.Dust <= 0 || sellOrder.IsTestMode != b.config.EnableTestMode {
+		return 0
+	}
+
+	dust := sellOrder.Dust
+	sellOrder.Dust = 0
+	b.db.SaveOrder(sellOrder)
+	b.buyLog.Debugf("Adding %g %s of dust that was left behind to the position.", dust, symbol)
+
+	return dust
+}
+
+// sellableVolume returns the volume of the given bought coin that can be sold, i.e. rounded down to the step size of the coin.
+// Returns 0 if the volume doesn't meet the minimum quantity of the coin, in which case it's dust that can't be sold at all.
+func (b *Bot) sellableVolume(ctx context.Context, boughtCoin models.Order) (float64, error) {
+	info, err := b.getSymbolInfo(ctx, boughtCoin.Symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	volume := boughtCoin.Volume
+	if info.StepSize != 0 {
+		volume = utils.FloorStepSize(volume, info.StepSize)
+	}
+
+	if volume <= 0 || volume < info.MinQuantity {
+		return 0, nil
+	}
+
+	return volume, nil
+}
+
+// placeOCO places an OCO order on the market to sell the given bought coin at its take profit or stop loss.
+// Returns an empty OCO if OCO orders are disabled or placing it failed, in which case the price is monitored by the bot instead.
+func (b *Bot) placeOCO(ctx context.Context, boughtCoin models.Order) market.OCO {
+	if !b.config.TradingOptions.OrderOptions.EnableOCO {
+		return market.OCO{}
+	}
+
+	volume, err := b.sellableVolume(ctx, boughtCoin)
+	if err != nil {
+		b.botLog.Errorf("Failed to place an OCO order for %s. Monitoring the price instead: %s.", boughtCoin.Symbol, err)
+		return market.OCO{}
+	}
+	if volume == 0 {
+		b.botLog.Warnf("Volume %g of %s is too small to place an OCO order for. Monitoring the price instead.", boughtCoin.Volume, boughtCoin.Symbol)
+		return market.OCO{}
+	}
+
+	oco, err := b.market.PlaceOCO(ctx, boughtCoin.Symbol, volume, takeProfitPrice(boughtCoin), stopLossPrice(boughtCoin))
+	if err != nil {
+		b.botLog.Errorf("Failed to place an OCO order for %s. Monitoring the price instead: %s.", boughtCoin.Symbol, err)
+		return market.OCO{}
+	}
+
+	return oco
+}
+
+// takeProfitPrice returns the price at which the given bought coin is sold with a profit.
+func takeProfitPrice(boughtCoin models.Order) float64 {
+	return boughtCoin.Price + (boughtCoin.Price*(*boughtCoin.TakeProfit))/100
+}
+
+// stopLossPrice returns the price at which the given bought coin is sold to limit the loss.
+func stopLossPrice(boughtCoin models.Order) float64 {
+	return boughtCoin.Price + (boughtCoin.Price*(-1*math.Abs(*boughtCoin.StopLoss)))/100
+}
+
+// quoteValue returns the amount of the quote currency that was spent or received for the given volume of the given order, excluding commissions.
+// Falls back to the average price if the market didn't report the cumulative quote quantity.
+func quoteValue(order market.Order, volume float64) float64 {
+	if order.CumulativeQuoteQuantity > 0 && order.FilledQuantity > 0 {
+		return order.CumulativeQuoteQuantity * volume / order.FilledQuantity
+	}
+
+	return order.Price * volume
+}
+
+// tradingFee returns the commissions that were paid for the given volume of the given order, converted to the quote currency.
+// Commissions paid in the coin itself are converted at the order price, those paid in other assets (e.g. BNB) at their current price.
+// Falls back to the configured taker fee if the market didn't report any commissions or one of them can't be converted.
+func (b *Bot) tradingFee(order market.Order, volume float64, coins market.Coins) float64 {
+	estimate := quoteValue(order, volume) * b.config.TradingOptions.TradingFeeTaker / 100
+	if len(order.Commissions) == 0 || order.FilledQuantity <= 0 {
+		return estimate
+	}
+
+	pairWith := b.config.TradingOptions.PairWith
+	var fee float64
+	for asset, amount := range order.Commissions {
+		switch asset {
+		case pairWith:
+			fee += amount
+		case strings.TrimSuffix(order.Symbol, pairWith):
+			fee += amount * order.Price
+		default:
+			coin, ok := coins[asset+pairWith]
+			if !ok || coin.Price <= 0 {
+				return estimate
+			}
+			fee += amount * coin.Price
 		}
 	}
+
+	return fee * volume / order.FilledQuantity
 }
 
 func (b *Bot) getProfitOrLossText(priceChangePercentage float64) string {
@@ -395,6 +684,54 @@ func (b *Bot) updateVolumeTraded(ctx context.Context) error {
 	return nil
 }
 
+// availableFunds returns the free balance of the base currency that may be spent on trades, i.e. without the configured reserve.
+func (b *Bot) availableFunds(ctx context.Context) (float64, error) {
+	balances, err := b.market.GetBalances(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return balances[b.config.TradingOptions.PairWith].Free - b.config.TradingOptions.BalanceReserve, nil
+}
+
+// checkFunds warns if the available funds don't cover the configured maximum amount of trades.
+func (b *Bot) checkFunds(ctx context.Context) {
+	funds, err := b.availableFunds(ctx)
+	if err != nil {
+		b.botLog.Errorf("Failed to fetch the available funds: %s.", err)
+		return
+	}
+
+	if required := b.config.TradingOptions.Quantity * float64(b.config.TradingOptions.MaxCoins); funds < required {
+		b.botLog.Warnf("Only %.2f %s is available while buying %d coins requires %.2f %s. Trades will be skipped when the funds run out.",
+			math.Max(funds, 0), b.config.TradingOptions.PairWith, b.config.TradingOptions.MaxCoins, required, b.config.TradingOptions.PairWith)
+	}
+}
+
+// updateSymbolInfo fetches the symbol info of all coins from the market in a single request and caches it.
+func (b *Bot) updateSymbolInfo(ctx context.Context) error {
+	b.botLog.Debug("Fetching symbol info of all coins.")
+
+	symbols, err := b.market.GetSymbolsInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	symbolInfo := make(map[string]market.SymbolInfo, len(symbols))
+	caches := make([]models.Cache, 0, len(symbols))
+	for _, info := range symbols {
+		symbolInfo[info.Symbol] = info
+		caches = append(caches, models.NewCache(b.market.Name(), info))
+	}
+
+	b.symbolInfoMu.Lock()
+	b.symbolInfo = symbolInfo
+	b.symbolInfoMu.Unlock()
+	b.db.SaveCaches(caches)
+
+	return nil
+}
+
 // updateLatestCoins fetches the latest coins from the market and appends them to the volatilityWindow.
 func (b *Bot) updateLatestCoins(ctx context.Context) error {
 	b.botLog.Debug("Fetching latest coins.")
@@ -408,6 +745,10 @@ func (b *Bot) updateLatestCoins(ctx context.Context) error {
 		if quoteVolume, ok := b.tradeVolumes[symbol]; ok {
 			coin.QuoteVolumeTraded = quoteVolume
 		}
+		if info, ok := b.preloadedSymbolInfo(symbol); ok {
+			coin.Halted = info.Halted
+		}
+		coins[symbol] = coin
 	}
 
 	b.volatilityWindow.AddRecord(coins)
@@ -416,32 +757,63 @@ func (b *Bot) updateLatestCoins(ctx context.Context) error {
 }
 
 // convertVolume converts the volume given in the configured quantity from base currency (USDT) to each coin's volume.
+// The volume is adjusted to the step size and maximum quantity of the coin.
+// Returns an error if the volume doesn't meet the minimum quantity or order value of the coin.
 func (b *Bot) convertVolume(ctx context.Context, quantity float64, volatileCoin market.VolatileCoin) (float64, error) {
-	var stepSize float64
-
-	// Get the step size of the coin from the local cache if it exists or from Binance if it doesn't (yet).
-	// The step size never changes, so it's safe to cache it forever.
-	// This approach avoids an additional API request to Binance per trade.
-	cache, ok := b.db.GetCache(volatileCoin.Symbol)
-	if ok {
-		stepSize = cache.StepSize
-	} else {
-		info, err := b.market.GetSymbolInfo(ctx, volatileCoin.Symbol)
-		if err != nil {
-			return 0, err
-		}
-
-		stepSize = info.StepSize
-
-		b.db.SaveCache(models.Cache{Symbol: volatileCoin.Symbol, StepSize: stepSize})
+	info, err := b.getSymbolInfo(ctx, volatileCoin.Symbol)
+	if err != nil {
+		return 0, err
 	}
 
 	volume := quantity / volatileCoin.Price
 
+	// Never buy more than the market allows in a single order.
+	if info.MaxQuantity != 0 && volume > info.MaxQuantity {
+		volume = info.MaxQuantity
+	}
+
 	// Round the volume to the step size of the coin.
-	if stepSize != 0 {
-		volume = utils.RoundStepSize(volume, stepSize)
+	if info.StepSize != 0 {
+		volume = utils.RoundStepSize(volume, info.StepSize)
+	}
+
+	if volume <= 0 || volume < info.MinQuantity {
+		return 0, fmt.Errorf("volume %g is below the minimum quantity of %g", volume, info.MinQuantity)
+	}
+
+	if notional := volume * volatileCoin.Price; notional < info.MinNotional {
+		return 0, fmt.Errorf("order value %g is below the minimum of %g", notional, info.MinNotional)
 	}
 
 	return volume, nil
 }
+
+// getSymbolInfo returns the symbol info of the given coin.
+// The symbol info of all coins is normally preloaded and refreshed periodically (see updateSymbolInfo), which avoids an additional API request to the market per trade.
+// If that failed or the coin is new, it's read from the local cache if it exists or fetched from the market if it doesn't (yet).
+func (b *Bot) getSymbolInfo(ctx context.Context, symbol string) (market.SymbolInfo, error) {
+	if info, ok := b.preloadedSymbolInfo(symbol); ok {
+		return info, nil
+	}
+
+	if cache, ok := b.db.GetCache(b.market.Name(), symbol); ok {
+		return cache.SymbolInfo(), nil
+	}
+
+	info, err := b.market.GetSymbolInfo(ctx, symbol)
+	if err != nil {
+		return market.SymbolInfo{}, err
+	}
+
+	b.db.SaveCache(models.NewCache(b.market.Name(), info))
+
+	return info, nil
+}
+
+// preloadedSymbolInfo returns the preloaded symbol info of the given coin, if any (see updateSymbolInfo).
+func (b *Bot) preloadedSymbolInfo(symbol string) (market.SymbolInfo, bool) {
+	b.symbolInfoMu.RLock()
+	defer b.symbolInfoMu.RUnlock()
+	info, ok := b.symbolInfo[symbol]
+	return info, ok
+}
@@ -3,19 +3,49 @@ package bot
 import (
 	"context"
 	"fmt"
+	"github.com/sleeyax/voltra/internal/clock"
 	"github.com/sleeyax/voltra/internal/config"
 	"github.com/sleeyax/voltra/internal/database"
 	"github.com/sleeyax/voltra/internal/database/models"
 	"github.com/sleeyax/voltra/internal/market"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"sync"
 	"testing"
+	"time"
 )
 
 type mockMarket struct {
-	coinsIndex int
-	coins      []market.Coins
-	cancel     context.CancelFunc
+	coinsIndex        int
+	coins             []market.Coins
+	cancel            context.CancelFunc
+	volumeTradedCalls int
+	symbolInfo        market.SymbolInfo
+	symbolsInfo       []market.SymbolInfo
+	symbolsInfoCalls  int
+	balances          market.Balances
+
+	// The number of times fetching the volume traded fails before it succeeds.
+	volumeTradedErrors int
+
+	// The commissions that are charged for buy orders, if any.
+	buyCommissions market.Commissions
+
+	// The quantity to fill of sell orders, if they should only be filled partially.
+	partialSellQuantity float64
+
+	// Whether orders expire without being filled at all.
+	unfilled bool
+
+	// The quantities of all sell orders placed.
+	soldQuantities []float64
+
+	// The OCO orders that are currently open and the number of OCO orders placed in total.
+	ocos       []market.OCO
+	placedOCOs int
+
+	// The order with which all OCO orders are filled, if any.
+	ocoFill *market.Order
 }
 
 // ensure mockMarket implements the Market interface
@@ -25,6 +55,13 @@ func newMockMarket(cancel context.CancelFunc) *mockMarket {
 	return &mockMarket{
 		coins:  make([]market.Coins, 0),
 		cancel: cancel,
+		symbolInfo: market.SymbolInfo{
+			Symbol:   "BTC",
+			StepSize: 0.0000001,
+		},
+		balances: market.Balances{
+			"USDT": {Asset: "USDT", Free: math.Inf(1)},
+		},
 	}
 }
 
@@ -32,16 +69,79 @@ func (m *mockMarket) Name() string {
 	return "mock market"
 }
 
-func (m *mockMarket) Buy(ctx context.Context, coin string, quantity float64) (market.Order, error) {
-	panic("implement me")
+// Buy fills the order at the price of the most recently served coins and pays for it with the USDT balance.
+func (m *mockMarket) Buy(_ context.Context, coin string, quantity float64) (market.Order, error) {
+	order, err := m.executeOrder(coin, quantity)
+	if err != nil || m.unfilled {
+		return order, err
+	}
+
+	balance := m.balances["USDT"]
+	balance.Free -= quantity * order.Price
+	m.balances["USDT"] = balance
+	order.Commissions = m.buyCommissions
+
+	return order, nil
+}
+
+// Sell fills the order at the price of the most recently served coins.
+func (m *mockMarket) Sell(_ context.Context, coin string, quantity float64) (market.Order, error) {
+	m.soldQuantities = append(m.soldQuantities, quantity)
+	order, err := m.executeOrder(coin, quantity)
+	if err == nil && m.partialSellQuantity != 0 {
+		order.Status = market.PartiallyFilledOrderStatus
+		order.FilledQuantity = m.partialSellQuantity
+	}
+	return order, err
+}
+
+func (m *mockMarket) PlaceOCO(_ context.Context, coin string, _, _, _ float64) (market.OCO, error) {
+	m.placedOCOs++
+	oco := market.OCO{OrderListID: int64(m.placedOCOs), Symbol: coin}
+	m.ocos = append(m.ocos, oco)
+	return oco, nil
 }
 
-func (m *mockMarket) Sell(ctx context.Context, coin string, quantity float64) (market.Order, error) {
-	panic("implement me")
+func (m *mockMarket) CancelOCO(_ context.Context, oco market.OCO) error {
+	for i, o := range m.ocos {
+		if o == oco {
+			m.ocos = append(m.ocos[:i], m.ocos[i+1:]...)
+			return nil
+		}
+	}
+	return market.OCOCanceledError
+}
+
+func (m *mockMarket) GetOCOFill(_ context.Context, _ market.OCO) (market.Order, bool, error) {
+	if m.ocoFill == nil {
+		return market.Order{}, false, nil
+	}
+	return *m.ocoFill, true, nil
+}
+
+func (m *mockMarket) executeOrder(coin string, quantity float64) (market.Order, error) {
+	if m.coinsIndex == 0 {
+		return market.Order{}, fmt.Errorf("no coins served yet")
+	}
+
+	for _, c := range m.coins[m.coinsIndex-1] {
+		if c.Symbol == coin {
+			if m.unfilled {
+				return market.Order{Symbol: coin, TransactionTime: time.Now(), Status: market.CanceledOrderStatus}, nil
+			}
+			return market.Order{Symbol: coin, Price: c.Price, TransactionTime: time.Now(), Status: market.FilledOrderStatus, FilledQuantity: quantity}, nil
+		}
+	}
+
+	return market.Order{}, market.SymbolNotFoundError
 }
 
 func (m *mockMarket) GetCoinsVolume(_ context.Context) (market.TradeVolumes, error) {
-	panic("implement me")
+	m.volumeTradedCalls++
+	if m.volumeTradedCalls <= m.volumeTradedErrors {
+		return nil, fmt.Errorf("market is down")
+	}
+	return market.TradeVolumes{}, nil
 }
 
 func (m *mockMarket) GetCoins(_ context.Context) (market.Coins, error) {
@@ -57,15 +157,21 @@ func (m *mockMarket) GetCoins(_ context.Context) (market.Coins, error) {
 	return coins, nil
 }
 
+func (m *mockMarket) GetBalances(_ context.Context) (market.Balances, error) {
+	return m.balances, nil
+}
+
 func (m *mockMarket) AddCoins(coins market.Coins) {
 	m.coins = append(m.coins, coins)
 }
 
 func (m *mockMarket) GetSymbolInfo(_ context.Context, symbol string) (market.SymbolInfo, error) {
-	return market.SymbolInfo{
-		Symbol:   "BTC",
-		StepSize: 0.0000001,
-	}, nil
+	return m.symbolInfo, nil
+}
+
+func (m *mockMarket) GetSymbolsInfo(_ context.Context) ([]market.SymbolInfo, error) {
+	m.symbolsInfoCalls++
+	return m.symbolsInfo, nil
 }
 
 type mockDatabase struct {
@@ -117,12 +223,17 @@ func (m *mockDatabase) SaveCache(_ models.Cache) {
 	// ignore
 }
 
-func (m *mockDatabase) GetCache(_ string) (models.Cache, bool) {
+func (m *mockDatabase) SaveCaches(_ []models.Cache) {
+	// ignore
+}
+
+func (m *mockDatabase) GetCache(_, _ string) (models.Cache, bool) {
 	return models.Cache{}, false
 }
 
 func (m *mockDatabase) GetLastOrder(orderType models.OrderType, market, symbol string) (models.Order, bool) {
-	panic("implement me")
+	order, ok := m.orders[symbol+string(orderType)]
+	return order, ok && order.Market == market
 }
 
 func TestBot_buy(t *testing.T) {
@@ -192,7 +303,7 @@ func TestBot_buy(t *testing.T) {
 
 	db := newMockDatabase()
 
-	b := New(c, m, db)
+	b := New(c, m, db, clock.Real{})
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -204,6 +315,225 @@ func TestBot_buy(t *testing.T) {
 	assert.Equal(t, 0.0009091, orders[0].Volume)
 }
 
+func TestBot_buy_net_of_fees(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			ChangeInPrice: 10,
+			PairWith:      "USDT",
+			Quantity:      10,
+		},
+	}
+
+	m := newMockMarket(cancel)
+	m.buyCommissions = market.Commissions{"BTC": 0.0000091}
+	m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 10_000}})
+	m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 11_000}})
+
+	db := newMockDatabase()
+	b := New(c, m, db, clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.buy(ctx, &wg)
+
+	// The commission was paid in BTC, so we hold less than what was bought.
+	orders := db.GetOrders(models.BuyOrder, m.Name())
+	assert.Equal(t, 1, len(orders))
+	assert.InDelta(t, 0.0009, orders[0].Volume, 1e-12)
+}
+
+func TestBot_buy_sweeps_dust(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			ChangeInPrice: 10,
+			PairWith:      "USDT",
+			Quantity:      10,
+		},
+	}
+
+	m := newMockMarket(cancel)
+	m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 10_000}})
+	m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 11_000}})
+
+	db := newMockDatabase()
+	// The previous sale of BTC left some dust behind.
+	db.SaveOrder(models.Order{Order: market.Order{Symbol: "BTCUSDT", Price: 9_000}, Market: m.Name(), Type: models.SellOrder, Volume: 0.001, Dust: 0.0000004})
+
+	b := New(c, m, db, clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.buy(ctx, &wg)
+
+	// The dust is sold along with the new position, and only once.
+	orders := db.GetOrders(models.BuyOrder, m.Name())
+	assert.Equal(t, 1, len(orders))
+	assert.InDelta(t, 0.0009091+0.0000004, orders[0].Volume, 1e-12)
+	sellOrder, _ := db.GetLastOrder(models.SellOrder, m.Name(), "BTCUSDT")
+	assert.Equal(t, 0.0, sellOrder.Dust)
+}
+
+func TestBot_buy_unfilled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			ChangeInPrice: 10,
+			PairWith:      "USDT",
+			Quantity:      10,
+		},
+	}
+
+	m := newMockMarket(cancel)
+	m.unfilled = true
+	m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 10_000}})
+	m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 11_000}})
+
+	db := newMockDatabase()
+	b := New(c, m, db, clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.buy(ctx, &wg)
+
+	// Nothing was bought, so there's no position to track.
+	assert.Equal(t, int64(0), db.CountOrders(models.BuyOrder, m.Name()))
+}
+
+func TestBot_buy_with_cool_off_delay(t *testing.T) {
+	for _, tc := range []struct {
+		coolOffDelay int
+		expected     int
+	}{
+		{coolOffDelay: 45, expected: 1},
+		{coolOffDelay: 60, expected: 0},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		c := &config.Configuration{
+			EnableTestMode: true,
+			LoggingOptions: config.LoggingOptions{Enable: false},
+			TradingOptions: config.TradingOptions{
+				ChangeInPrice:   10,
+				PairWith:        "USDT",
+				Quantity:        10,
+				TimeDifference:  60,
+				RecheckInterval: 2, // wait 30 minutes between each check
+				CoolOffDelay:    tc.coolOffDelay,
+			},
+		}
+
+		m := newMockMarket(cancel)
+		m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 10_000}})
+		m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 11_000}})
+
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		db := newMockDatabase()
+		lastSellOrder := models.Order{Market: m.Name(), Type: models.SellOrder}
+		lastSellOrder.Symbol = "BTCUSDT"
+		lastSellOrder.CreatedAt = start.Add(-20 * time.Minute)
+		db.SaveOrder(lastSellOrder)
+
+		b := New(c, m, db, clock.NewSimulated(start))
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		b.buy(ctx, &wg)
+
+		// The coin becomes volatile 50 minutes after it was last sold.
+		assert.Equal(t, int64(tc.expected), db.CountOrders(models.BuyOrder, m.Name()), "cool-off delay of %d minutes", tc.coolOffDelay)
+	}
+}
+
+func TestBot_buy_updates_volume_traded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		EnableTestMode: true,
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			ChangeInPrice:   10,
+			PairWith:        "USDT",
+			Quantity:        10,
+			TimeDifference:  60,
+			RecheckInterval: 2, // wait 30 minutes between each check
+		},
+	}
+
+	m := newMockMarket(cancel)
+	for i := 0; i < 5; i++ {
+		m.AddCoins(market.Coins{"BTCUSDT": market.Coin{Symbol: "BTCUSDT", Price: 10_000}})
+	}
+
+	b := New(c, m, newMockDatabase(), clock.NewSimulated(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.buy(ctx, &wg)
+
+	// Two hours have passed in between the 5 checks.
+	assert.Equal(t, 2, m.volumeTradedCalls)
+}
+
+func TestBot_retryUntilDone(t *testing.T) {
+	c := &config.Configuration{LoggingOptions: config.LoggingOptions{Enable: false}}
+
+	m := newMockMarket(func() {})
+	m.volumeTradedErrors = 2
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewSimulated(start)
+	b := New(c, m, newMockDatabase(), clk)
+
+	// Keeps retrying during an outage of the market.
+	assert.NoError(t, b.retryUntilDone(context.Background(), "volume traded", b.updateVolumeTraded))
+	assert.Equal(t, 3, m.volumeTradedCalls)
+	assert.Equal(t, start.Add(2*startupRetryInterval), clk.Now())
+
+	// Gives up once the bot is stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.volumeTradedErrors = math.MaxInt
+	assert.Error(t, b.retryUntilDone(ctx, "volume traded", b.updateVolumeTraded))
+}
+
+func TestBot_retryUntilDone_stops_waiting(t *testing.T) {
+	c := &config.Configuration{LoggingOptions: config.LoggingOptions{Enable: false}}
+
+	m := newMockMarket(func() {})
+	m.volumeTradedErrors = math.MaxInt
+	b := New(c, m, newMockDatabase(), clock.Real{})
+
+	// The bot is stopped while it waits between attempts.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, b.retryUntilDone(ctx, "volume traded", b.updateVolumeTraded))
+	assert.Less(t, time.Since(start), startupRetryInterval)
+	assert.Equal(t, 1, m.volumeTradedCalls)
+}
+
+func TestBot_buy_stops_without_initial_coins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The market has no coins, so fetching them fails and stops the bot.
+	m := newMockMarket(cancel)
+	b := New(&config.Configuration{LoggingOptions: config.LoggingOptions{Enable: false}}, m, newMockDatabase(), clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.NotPanics(t, func() {
+		b.buy(ctx, &wg)
+	})
+}
+
 func TestBot_sell(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -242,7 +572,7 @@ func TestBot_sell(t *testing.T) {
 		IsTestMode: true,
 	})
 
-	b := New(c, m, db)
+	b := New(c, m, db, clock.Real{})
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -252,6 +582,193 @@ func TestBot_sell(t *testing.T) {
 	assert.Equal(t, int64(0), db.CountOrders(models.BuyOrder, m.Name()))
 }
 
+func TestBot_sell_unfilled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			PairWith:              "USDT",
+			Quantity:              15,
+			MaxCoins:              1,
+			TakeProfit:            0.1,
+			StopLoss:              5,
+			EnableDynamicQuantity: true,
+		},
+	}
+
+	m := newMockMarket(cancel)
+	m.unfilled = true
+	m.AddCoins(market.Coins{"XTZUSDT": market.Coin{Symbol: "XTZUSDT", Price: 1.295}})
+
+	db := newMockDatabase()
+	db.SaveOrder(models.Order{
+		Order:      market.Order{Symbol: "XTZUSDT", Price: 1.292},
+		Market:     m.Name(),
+		Type:       models.BuyOrder,
+		Volume:     11.6,
+		TakeProfit: &c.TradingOptions.TakeProfit,
+		StopLoss:   &c.TradingOptions.StopLoss,
+	})
+
+	b := New(c, m, db, clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.sell(ctx, &wg)
+
+	// The sell order expired, so the coins are still held and no loss was realized.
+	assert.Equal(t, []float64{11.6}, m.soldQuantities)
+	assert.Equal(t, int64(0), db.CountOrders(models.SellOrder, m.Name()))
+	buyOrders := db.GetOrders(models.BuyOrder, m.Name())
+	assert.Equal(t, 1, len(buyOrders))
+	assert.Equal(t, 11.6, buyOrders[0].Volume)
+	assert.Equal(t, 15.0, c.TradingOptions.Quantity)
+}
+
+func TestBot_sell_rounds_down_to_step_size(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		volume     float64
+		soldVolume float64
+		// The dust of the previous sale, if the coin has been sold before.
+		previousDust *float64
+	}{
+		{name: "sellable", volume: 9.99, soldVolume: 9.9},
+		{name: "dust", volume: 0.05},
+		{name: "dust of a coin that was sold before", volume: 0.05, previousDust: new(float64)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			c := &config.Configuration{
+				LoggingOptions: config.LoggingOptions{Enable: false},
+				TradingOptions: config.TradingOptions{
+					PairWith:   "USDT",
+					TakeProfit: 1,
+					StopLoss:   5,
+				},
+			}
+
+			m := newMockMarket(cancel)
+			m.symbolInfo = market.SymbolInfo{Symbol: "XTZUSDT", StepSize: 0.1, MinQuantity: 0.1}
+			m.AddCoins(market.Coins{"XTZUSDT": market.Coin{Symbol: "XTZUSDT", Price: 1.5}})
+
+			db := newMockDatabase()
+			db.SaveOrder(models.Order{
+				Order:      market.Order{Symbol: "XTZUSDT", Price: 1},
+				Market:     m.Name(),
+				Type:       models.BuyOrder,
+				Volume:     tc.volume,
+				TakeProfit: &c.TradingOptions.TakeProfit,
+				StopLoss:   &c.TradingOptions.StopLoss,
+			})
+			if tc.previousDust != nil {
+				db.SaveOrder(models.Order{Order: market.Order{Symbol: "XTZUSDT", Price: 2}, Market: m.Name(), Type: models.SellOrder, Volume: 5, Dust: *tc.previousDust})
+			}
+
+			b := New(c, m, db, clock.Real{})
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			b.sell(ctx, &wg)
+
+			// The position is closed either way, leaving the dust behind.
+			assert.Equal(t, int64(0), db.CountOrders(models.BuyOrder, m.Name()))
+
+			sellOrders := db.GetOrders(models.SellOrder, m.Name())
+			if tc.soldVolume == 0 {
+				assert.Empty(t, m.soldQuantities)
+				if tc.previousDust == nil {
+					assert.Empty(t, sellOrders)
+					return
+				}
+				// The dust is added to the previous sale, so that it's sold along with the next buy.
+				assert.Equal(t, 1, len(sellOrders))
+				assert.Equal(t, 0.05, sellOrders[0].Dust)
+				return
+			}
+			assert.Equal(t, []float64{tc.soldVolume}, m.soldQuantities)
+			assert.Equal(t, 1, len(sellOrders))
+			assert.Equal(t, tc.soldVolume, sellOrders[0].Volume)
+			assert.InDelta(t, 0.09, sellOrders[0].Dust, 1e-9)
+		})
+	}
+}
+
+func TestBot_sell_partially_filled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			PairWith:   "USDT",
+			TakeProfit: 1,
+			StopLoss:   5,
+		},
+	}
+
+	m := newMockMarket(cancel)
+	m.partialSellQuantity = 4
+	m.AddCoins(market.Coins{
+		"XTZUSDT": market.Coin{Symbol: "XTZUSDT", Price: 1.5},
+	})
+
+	db := newMockDatabase()
+	db.SaveOrder(models.Order{
+		Order: market.Order{
+			Symbol:         "XTZUSDT",
+			Price:          1,
+			FilledQuantity: 10,
+			Commissions:    market.Commissions{"XTZ": 0.01},
+		},
+		Market:     m.Name(),
+		Type:       models.BuyOrder,
+		Volume:     10,
+		TakeProfit: &c.TradingOptions.TakeProfit,
+		StopLoss:   &c.TradingOptions.StopLoss,
+	})
+
+	b := New(c, m, db, clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.sell(ctx, &wg)
+
+	// The sold part is recorded and the rest is still tracked.
+	sellOrders := db.GetOrders(models.SellOrder, m.Name())
+	assert.Equal(t, 1, len(sellOrders))
+	assert.Equal(t, 4.0, sellOrders[0].Volume)
+	// The commission of the buy order was paid in the coin itself, i.e. 0.1%.
+	assert.InDelta(t, 0.5*4-0.001*4, *sellOrders[0].RealizedProfitLoss, 1e-9)
+
+	buyOrders := db.GetOrders(models.BuyOrder, m.Name())
+	assert.Equal(t, 1, len(buyOrders))
+	assert.Equal(t, 6.0, buyOrders[0].Volume)
+}
+
+func TestBot_sell_waits_after_error(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{SellTimeout: 10},
+	}
+
+	// The market has no coins, so fetching them fails.
+	m := newMockMarket(cancel)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewSimulated(start)
+	b := New(c, m, newMockDatabase(), clk)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.sell(ctx, &wg)
+
+	assert.Equal(t, start.Add(10*time.Second), clk.Now())
+}
+
 func TestBot_sell_with_trailing_stop_loss(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -306,7 +823,7 @@ func TestBot_sell_with_trailing_stop_loss(t *testing.T) {
 		IsTestMode: true,
 	})
 
-	b := New(c, m, db)
+	b := New(c, m, db, clock.Real{})
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -320,12 +837,62 @@ func TestBot_sell_with_trailing_stop_loss(t *testing.T) {
 	assert.Equal(t, float64(9000), orders[0].Price)
 }
 
+func TestBot_sell_with_oco(t *testing.T) {
+	ctx := context.Background()
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			PairWith:   "USDT",
+			TakeProfit: 10,
+			StopLoss:   5,
+			TrailingStopOptions: config.TrailingStopOptions{
+				Enable:             true,
+				TrailingStopLoss:   1,
+				TrailingTakeProfit: 1,
+			},
+			OrderOptions: config.OrderOptions{EnableOCO: true},
+		},
+	}
+
+	m := newMockMarket(nil)
+	db := newMockDatabase()
+	b := New(c, m, db, clock.Real{})
+
+	boughtCoin := models.Order{
+		Order:      market.Order{Symbol: "BTCUSDT", Price: 10_000},
+		Market:     m.Name(),
+		Type:       models.BuyOrder,
+		Volume:     0.001,
+		TakeProfit: &c.TradingOptions.TakeProfit,
+		StopLoss:   &c.TradingOptions.StopLoss,
+	}
+	boughtCoin.OCO = b.placeOCO(ctx, boughtCoin)
+	db.SaveOrder(boughtCoin)
+
+	// The OCO order is replaced when the trailing stop loss is adjusted.
+	b.sellBoughtCoins(ctx, market.Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: 11_000}})
+	orders := db.GetOrders(models.BuyOrder, m.Name())
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, []market.OCO{{OrderListID: 2, Symbol: "BTCUSDT"}}, m.ocos)
+	assert.Equal(t, m.ocos[0], orders[0].OCO)
+
+	// The market sells the coin, which is only recorded.
+	m.ocoFill = &market.Order{Symbol: "BTCUSDT", Price: 11_500, Status: market.FilledOrderStatus, FilledQuantity: 0.001}
+	b.sellBoughtCoins(ctx, market.Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: 11_600}})
+	assert.Equal(t, int64(0), db.CountOrders(models.BuyOrder, m.Name()))
+	orders = db.GetOrders(models.SellOrder, m.Name())
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, 11_500.0, orders[0].Price)
+	assert.InDelta(t, 1.5, *orders[0].RealizedProfitLoss, 1e-9)
+}
+
 func TestBot_convertVolume(t *testing.T) {
 	c := config.Configuration{
 		EnableTestMode: true,
 		LoggingOptions: config.LoggingOptions{Enable: false},
 	}
-	b := New(&c, newMockMarket(nil), newMockDatabase())
+	b := New(&c, newMockMarket(nil), newMockDatabase(), clock.Real{})
 	v, err := b.convertVolume(context.Background(), 50, market.VolatileCoin{
 		Coin: market.Coin{
 			Symbol: "BTC",
@@ -353,3 +920,147 @@ func TestBot_convertVolume(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, 0.0009091, v)
 }
+
+func TestBot_convertVolume_with_filters(t *testing.T) {
+	c := config.Configuration{
+		EnableTestMode: true,
+		LoggingOptions: config.LoggingOptions{Enable: false},
+	}
+	m := newMockMarket(nil)
+	m.symbolInfo = market.SymbolInfo{
+		Symbol:      "BTC",
+		StepSize:    0.001,
+		MinQuantity: 0.01,
+		MaxQuantity: 2,
+		MinNotional: 5,
+	}
+	b := New(&c, m, newMockDatabase(), clock.Real{})
+
+	// The volume is limited to the maximum quantity.
+	v, err := b.convertVolume(context.Background(), 1000, market.VolatileCoin{Coin: market.Coin{Symbol: "BTC", Price: 100}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, v)
+
+	// The volume is below the minimum quantity.
+	_, err = b.convertVolume(context.Background(), 0.5, market.VolatileCoin{Coin: market.Coin{Symbol: "BTC", Price: 100}})
+	assert.ErrorContains(t, err, "below the minimum quantity")
+
+	// The order value is below the minimum notional.
+	_, err = b.convertVolume(context.Background(), 4, market.VolatileCoin{Coin: market.Coin{Symbol: "BTC", Price: 100}})
+	assert.ErrorContains(t, err, "below the minimum of 5")
+
+	v, err = b.convertVolume(context.Background(), 6, market.VolatileCoin{Coin: market.Coin{Symbol: "BTC", Price: 100}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.06, v)
+}
+
+func TestBot_updateSymbolInfo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+	}
+	m := newMockMarket(cancel)
+	m.symbolsInfo = []market.SymbolInfo{
+		{Symbol: "BTCUSDT", StepSize: 1},
+		{Symbol: "LUNAUSDT", Halted: true},
+	}
+	m.AddCoins(market.Coins{
+		"BTCUSDT":  market.Coin{Symbol: "BTCUSDT", Price: 100},
+		"LUNAUSDT": market.Coin{Symbol: "LUNAUSDT", Price: 1},
+	})
+	b := New(&c, m, newMockDatabase(), clock.Real{})
+
+	assert.NoError(t, b.updateSymbolInfo(ctx))
+	assert.NoError(t, b.updateLatestCoins(ctx))
+
+	coins := b.volatilityWindow.GetLatestRecord().coins
+	assert.False(t, coins["BTCUSDT"].Halted)
+	assert.True(t, coins["LUNAUSDT"].Halted)
+
+	// The preloaded symbol info is used instead of fetching it per trade.
+	v, err := b.convertVolume(ctx, 160, market.VolatileCoin{Coin: coins["BTCUSDT"]})
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, v)
+	assert.Equal(t, 1, m.symbolsInfoCalls)
+}
+
+func TestBot_tradingFee(t *testing.T) {
+	c := config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{PairWith: "USDT", TradingFeeTaker: 0.1},
+	}
+	b := New(&c, newMockMarket(nil), newMockDatabase(), clock.Real{})
+	coins := market.Coins{"BNBUSDT": {Symbol: "BNBUSDT", Price: 300}}
+	order := market.Order{
+		Symbol:                  "XTZUSDT",
+		Price:                   2,
+		FilledQuantity:          10,
+		CumulativeQuoteQuantity: 20,
+	}
+
+	// Commissions paid in BNB are converted at the current price of BNB, those paid in the coin itself at the order price.
+	order.Commissions = market.Commissions{"BNB": 0.0001, "XTZ": 0.01, "USDT": 0.005}
+	assert.InDelta(t, 0.03+0.02+0.005, b.tradingFee(order, 10, coins), 1e-9)
+
+	// The commissions are pro-rated for the given volume.
+	assert.InDelta(t, (0.03+0.02+0.005)/2, b.tradingFee(order, 5, coins), 1e-9)
+
+	// The taker fee is used if a commission can't be converted or none was reported.
+	order.Commissions = market.Commissions{"ETH": 0.001}
+	assert.InDelta(t, 0.02, b.tradingFee(order, 10, coins), 1e-9)
+	order.Commissions = nil
+	assert.InDelta(t, 0.02, b.tradingFee(order, 10, coins), 1e-9)
+}
+
+func TestBot_availableFunds(t *testing.T) {
+	c := config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{PairWith: "USDT", BalanceReserve: 5},
+	}
+	m := newMockMarket(nil)
+	m.balances = market.Balances{"USDT": {Asset: "USDT", Free: 20, Locked: 100}}
+	b := New(&c, m, newMockDatabase(), clock.Real{})
+
+	funds, err := b.availableFunds(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 15.0, funds)
+}
+
+func TestBot_buy_with_insufficient_funds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &config.Configuration{
+		LoggingOptions: config.LoggingOptions{Enable: false},
+		TradingOptions: config.TradingOptions{
+			ChangeInPrice: 10, // 10%
+			PairWith:      "USDT",
+			Quantity:      10, // trade 10 USDT
+			MaxCoins:      5,
+		},
+	}
+
+	m := newMockMarket(cancel)
+	m.balances = market.Balances{"USDT": {Asset: "USDT", Free: 15}}
+	m.AddCoins(market.Coins{
+		"BTC": market.Coin{Symbol: "BTCUSDT", Price: 100},
+		"ETH": market.Coin{Symbol: "ETHUSDT", Price: 100},
+	})
+	m.AddCoins(market.Coins{
+		"BTC": market.Coin{Symbol: "BTCUSDT", Price: 120},
+		"ETH": market.Coin{Symbol: "ETHUSDT", Price: 110},
+	})
+
+	db := newMockDatabase()
+	b := New(c, m, db, clock.Real{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.buy(ctx, &wg)
+
+	// Only the first coin could be paid for.
+	orders := db.GetOrders(models.BuyOrder, m.Name())
+	assert.Equal(t, 1, len(orders))
+	assert.InDelta(t, 5, m.balances["USDT"].Free, 0.001)
+}
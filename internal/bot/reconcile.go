@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/sleeyax/voltra/internal/market"
+	"math"
+	"slices"
+	"strings"
+)
+
+// reconcileTolerance is the relative difference between the volume of an open position and the balance that is ignored during reconciliation.
+// Small differences are expected, e.g. due to trading fees that are paid in the bought coin.
+const reconcileTolerance = 0.01
+
+// Discrepancy is a difference between an open position in the database and the balance held on the market.
+type Discrepancy struct {
+	Symbol string
+
+	// The volume of the open position, or 0 if there is none.
+	Expected float64
+
+	// The volume held on the market.
+	Actual float64
+}
+
+func (d Discrepancy) String() string {
+	switch {
+	case d.Expected == 0:
+		return fmt.Sprintf("%s is held (%g) but not tracked as an open position", d.Symbol, d.Actual)
+	case d.Actual == 0:
+		return fmt.Sprintf("%s is tracked as an open position (%g) but no longer held", d.Symbol, d.Expected)
+	default:
+		return fmt.Sprintf("%s is tracked as an open position of %g but %g is held", d.Symbol, d.Expected, d.Actual)
+	}
+}
+
+// Reconcile compares the open positions in the database with the balances held on the market.
+// This is necessary when coins are sold manually or the bot crashed in between buying a coin and saving the order, for example.
+// All differences are logged and returned. If fix is true, the open positions are updated to match the balances as follows:
+//   - Positions of coins that are no longer held are removed.
+//   - Positions of coins of which less is held than expected are reduced to the held volume.
+//   - Coins that are held but not tracked are tracked from now on at the current price, if they are available for trading and either the bot has traded them before or they're in the configured adopt list.
+//     No more coins are tracked than the configured maximum.
+//
+// Holding more of a tracked coin than expected is only reported, because the surplus may have been bought manually.
+// Positions opened in test mode are ignored.
+func (b *Bot) Reconcile(ctx context.Context, fix bool) ([]Discrepancy, error) {
+	balances, err := b.market.GetBalances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch balances: %w", err)
+	}
+
+	pairWith := b.config.TradingOptions.PairWith
+
+	var discrepancies []Discrepancy
+	tracked := make(map[string]bool)
+
+	for _, order := range b.db.GetOrders(models.BuyOrder, b.market.Name()) {
+		if order.IsTestMode {
+			continue
+		}
+
+		asset := strings.TrimSuffix(order.Symbol, pairWith)
+		tracked[asset] = true
+
+		held := balances[asset].Total()
+		if math.Abs(held-order.Volume) <= order.Volume*reconcileTolerance {
+			continue
+		}
+
+		discrepancy := Discrepancy{Symbol: order.Symbol, Expected: order.Volume, Actual: held}
+		discrepancies = append(discrepancies, discrepancy)
+		b.botLog.Warnf("Reconciliation: %s.", discrepancy)
+
+		if !fix || held > order.Volume {
+			continue
+		}
+
+		info, err := b.getSymbolInfo(ctx, order.Symbol)
+		if err != nil {
+			return discrepancies, err
+		}
+
+		// Remove the position entirely if what's left is too little to be sold.
+		if held == 0 || held < info.MinQuantity {
+			b.botLog.Infof("Reconciliation: removing the position of %s.", order.Symbol)
+			b.db.DeleteOrder(order)
+		} else {
+			b.botLog.Infof("Reconciliation: reducing the position of %s to %g.", order.Symbol, held)
+			order.Volume = held
+			b.db.SaveOrder(order)
+		}
+	}
+
+	var untracked []market.Balance
+	for asset, balance := range balances {
+		if asset != pairWith && !tracked[asset] && balance.Total() > 0 {
+			untracked = append(untracked, balance)
+		}
+	}
+	if len(untracked) == 0 {
+		return discrepancies, nil
+	}
+	slices.SortFunc(untracked, func(a, b market.Balance) int {
+		return strings.Compare(a.Asset, b.Asset)
+	})
+
+	coins, err := b.market.GetCoins(ctx)
+	if err != nil {
+		return discrepancies, fmt.Errorf("failed to fetch coins: %w", err)
+	}
+
+	for _, balance := range untracked {
+		coin, ok := coins[balance.Asset+pairWith]
+		if !ok {
+			continue
+		}
+		if info, ok := b.preloadedSymbolInfo(coin.Symbol); ok {
+			coin.Halted = info.Halted
+		}
+
+		// Ignore dust that is worth too little to be sold anyway.
+		info, err := b.getSymbolInfo(ctx, coin.Symbol)
+		if err != nil {
+			return discrepancies, err
+		}
+		if balance.Total() < info.MinQuantity || balance.Total()*coin.Price < info.MinNotional {
+			continue
+		}
+
+		discrepancy := Discrepancy{Symbol: coin.Symbol, Actual: balance.Total()}
+		discrepancies = append(discrepancies, discrepancy)
+		b.botLog.Warnf("Reconciliation: %s.", discrepancy)
+
+		// Coins that are excluded from trading or that the bot doesn't know about are probably held on purpose.
+		if !fix || !coin.IsAvailableForTrading(b.config.TradingOptions.AllowList, b.config.TradingOptions.DenyList, pairWith, 0) || !b.mayAdopt(balance.Asset, coin.Symbol) {
+			continue
+		}
+
+		if maxCoins := int64(b.config.TradingOptions.MaxCoins); maxCoins != 0 && b.db.CountOrders(models.BuyOrder, b.market.Name()) >= maxCoins {
+			b.botLog.Warnf("Reconciliation: not tracking %s because the max amount of buy orders has been reached.", coin.Symbol)
+			continue
+		}
+
+		b.botLog.Infof("Reconciliation: tracking %g %s at the current price of %g.", balance.Total(), coin.Symbol, coin.Price)
+		b.db.SaveOrder(models.Order{
+			Order: market.Order{
+				Symbol:          coin.Symbol,
+				Price:           coin.Price,
+				TransactionTime: b.clock.Now(),
+			},
+			Market:     b.market.Name(),
+			Type:       models.BuyOrder,
+			Volume:     balance.Total(),
+			TakeProfit: &b.config.TradingOptions.TakeProfit,
+			StopLoss:   &b.config.TradingOptions.StopLoss,
+		})
+	}
+
+	return discrepancies, nil
+}
+
+// mayAdopt returns whether the given untracked coin may be tracked as an open position, i.e. whether the bot has traded it before or it's in the configured adopt list.
+func (b *Bot) mayAdopt(asset, symbol string) bool {
+	if slices.Contains(b.config.ReconciliationAdoptList, asset) {
+		return true
+	}
+
+	order, ok := b.db.GetLastOrder(models.SellOrder, b.market.Name(), symbol)
+	return ok && !order.IsTestMode
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBot_Reconcile(t *testing.T) {
+	for _, fix := range []bool{false, true} {
+		c := config.Configuration{
+			ReconciliationAdoptList: []string{"SOL", "XLM", "BNB"},
+			LoggingOptions:          config.LoggingOptions{Enable: false},
+			TradingOptions: config.TradingOptions{
+				PairWith:   "USDT",
+				TakeProfit: 5,
+				StopLoss:   5,
+				MaxCoins:   6,
+				DenyList:   []string{"BNBUSDT"},
+			},
+		}
+
+		m := newMockMarket(func() {})
+		m.symbolInfo = market.SymbolInfo{StepSize: 0.001, MinQuantity: 0.001, MinNotional: 5}
+		m.balances = market.Balances{
+			"USDT": {Asset: "USDT", Free: 1000},
+			"BTC":  {Asset: "BTC", Free: 0.995},
+			"XRP":  {Asset: "XRP", Free: 40, Locked: 10},
+			"ADA":  {Asset: "ADA", Free: 20},
+			"SOL":  {Asset: "SOL", Free: 2, Locked: 1},
+			"BNB":  {Asset: "BNB", Free: 1},
+			"DOGE": {Asset: "DOGE", Free: 0.01},
+			"AVAX": {Asset: "AVAX", Free: 1},
+			"DOT":  {Asset: "DOT", Free: 10},
+			"XLM":  {Asset: "XLM", Free: 100},
+		}
+		m.AddCoins(market.Coins{
+			"SOLUSDT":  {Symbol: "SOLUSDT", Price: 100},
+			"BNBUSDT":  {Symbol: "BNBUSDT", Price: 500},
+			"DOGEUSDT": {Symbol: "DOGEUSDT", Price: 0.1},
+			"AVAXUSDT": {Symbol: "AVAXUSDT", Price: 30},
+			"DOTUSDT":  {Symbol: "DOTUSDT", Price: 7},
+			"XLMUSDT":  {Symbol: "XLMUSDT", Price: 0.1},
+		})
+
+		db := database.NewMemoryDatabase(clock.Real{})
+		for _, order := range []models.Order{
+			{Order: market.Order{Symbol: "BTCUSDT", Price: 60_000}, Volume: 1},
+			{Order: market.Order{Symbol: "ETHUSDT", Price: 3_000}, Volume: 2},
+			{Order: market.Order{Symbol: "XRPUSDT", Price: 0.5}, Volume: 100},
+			{Order: market.Order{Symbol: "ADAUSDT", Price: 0.4}, Volume: 10},
+			{Order: market.Order{Symbol: "LTCUSDT", Price: 80}, Volume: 1, IsTestMode: true},
+		} {
+			order.Market = m.Name()
+			order.Type = models.BuyOrder
+			db.SaveOrder(order)
+		}
+		// The bot has traded DOT before, e.g. until it crashed right after buying it again.
+		db.SaveOrder(models.Order{Order: market.Order{Symbol: "DOTUSDT", Price: 6}, Market: m.Name(), Type: models.SellOrder, Volume: 10})
+
+		b := New(&c, m, db, clock.NewSimulated(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+		discrepancies, err := b.Reconcile(context.Background(), fix)
+		assert.NoError(t, err)
+		assert.Equal(t, []Discrepancy{
+			{Symbol: "ETHUSDT", Expected: 2, Actual: 0},
+			{Symbol: "XRPUSDT", Expected: 100, Actual: 50},
+			{Symbol: "ADAUSDT", Expected: 10, Actual: 20},
+			{Symbol: "AVAXUSDT", Expected: 0, Actual: 1},
+			{Symbol: "BNBUSDT", Expected: 0, Actual: 1},
+			{Symbol: "DOTUSDT", Expected: 0, Actual: 10},
+			{Symbol: "SOLUSDT", Expected: 0, Actual: 3},
+			{Symbol: "XLMUSDT", Expected: 0, Actual: 100},
+		}, discrepancies)
+
+		volumes := make(map[string]float64)
+		for _, order := range db.GetOrders(models.BuyOrder, m.Name()) {
+			volumes[order.Symbol] = order.Volume
+		}
+
+		if !fix {
+			assert.Equal(t, map[string]float64{"BTCUSDT": 1, "ETHUSDT": 2, "XRPUSDT": 100, "ADAUSDT": 10, "LTCUSDT": 1}, volumes)
+			continue
+		}
+
+		// AVAX isn't known to the bot, BNB is excluded from trading and XLM exceeds the max amount of coins.
+		assert.Equal(t, map[string]float64{"BTCUSDT": 1, "XRPUSDT": 50, "ADAUSDT": 10, "LTCUSDT": 1, "DOTUSDT": 10, "SOLUSDT": 3}, volumes)
+		order, ok := db.GetLastOrder(models.BuyOrder, m.Name(), "SOLUSDT")
+		assert.True(t, ok)
+		assert.Equal(t, 100.0, order.Price)
+		assert.Equal(t, 5.0, *order.TakeProfit)
+	}
+}
@@ -2,6 +2,7 @@ package bot
 
 import (
 	"cmp"
+	"github.com/sleeyax/voltra/internal/clock"
 	"github.com/sleeyax/voltra/internal/market"
 	"math"
 	"slices"
@@ -21,13 +22,15 @@ type VolatilityWindow struct {
 	records       []VolatilityWindowRecord
 	volatileCoins market.VolatileCoins
 	maxLength     int
+	clock         clock.Clock
 }
 
 // NewVolatilityWindow creates a new volatilityWindow of records.
 // The volatilityWindow can be used to monitor the price changes of coins over time.
 // It's a rolling window of records, so the volatilityWindow will never exceed the given max length.
-func NewVolatilityWindow(maxLength int) *VolatilityWindow {
-	return &VolatilityWindow{records: make([]VolatilityWindowRecord, 0), volatileCoins: make(market.VolatileCoins), maxLength: maxLength}
+// Records are timestamped using the given clock.
+func NewVolatilityWindow(maxLength int, clock clock.Clock) *VolatilityWindow {
+	return &VolatilityWindow{records: make([]VolatilityWindowRecord, 0), volatileCoins: make(market.VolatileCoins), maxLength: maxLength, clock: clock}
 }
 
 // Size returns the number of records in the volatilityWindow.
@@ -43,7 +46,7 @@ func (h *VolatilityWindow) AddRecord(coins market.Coins) {
 		h.records = make([]VolatilityWindowRecord, 0)
 		h.volatileCoins = make(market.VolatileCoins)
 	}
-	h.records = append(h.records, VolatilityWindowRecord{time: time.Now(), coins: coins})
+	h.records = append(h.records, VolatilityWindowRecord{time: h.clock.Now(), coins: coins})
 }
 
 // GetLatestRecord returns the latest record in the volatilityWindow.
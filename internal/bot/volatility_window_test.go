@@ -1,20 +1,21 @@
 package bot
 
 import (
+	"github.com/sleeyax/voltra/internal/clock"
 	"github.com/sleeyax/voltra/internal/market"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestVolatilityWindow_Size(t *testing.T) {
-	window := NewVolatilityWindow(3)
+	window := NewVolatilityWindow(3, clock.Real{})
 	assert.Equal(t, 0, window.Size())
 	window.AddRecord(nil)
 	assert.Equal(t, 1, window.Size())
 }
 
 func TestVolatilityWindow_Min(t *testing.T) {
-	window := NewVolatilityWindow(3)
+	window := NewVolatilityWindow(3, clock.Real{})
 	window.AddRecord(market.Coins{
 		"BTCUSDT": {Price: 10000.0},
 	})
@@ -29,7 +30,7 @@ func TestVolatilityWindow_Min(t *testing.T) {
 }
 
 func TestVolatilityWindow_Max(t *testing.T) {
-	window := NewVolatilityWindow(3)
+	window := NewVolatilityWindow(3, clock.Real{})
 	window.AddRecord(market.Coins{
 		"BTCUSDT": {Price: 10000.0},
 	})
@@ -45,7 +46,7 @@ func TestVolatilityWindow_Max(t *testing.T) {
 
 func TestVolatilityWindow_IdentifyVolatileCoins(t *testing.T) {
 	// Basic percentage increase check.
-	window := NewVolatilityWindow(UnlimitedVolatilityWindowLength)
+	window := NewVolatilityWindow(UnlimitedVolatilityWindowLength, clock.Real{})
 	percentage := 15.0
 	window.AddRecord(market.Coins{
 		"BTCUSDT": {Price: 20_000.0},
@@ -0,0 +1,81 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the current time and allows to wait for time to pass.
+// Components that depend on time should use a Clock instead of the time package directly, so that time can be controlled in tests and simulations (e.g. during a backtest).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+
+	// Sleep pauses the current goroutine for at least the given duration.
+	Sleep(d time.Duration)
+
+	// SleepContext pauses the current goroutine for at least the given duration, or until ctx is done.
+	// Returns ctx.Err() if ctx is done first.
+	SleepContext(ctx context.Context, d time.Duration) error
+
+	// NewTicker returns a new Ticker that ticks every given duration.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a clock at intervals.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks will be sent after Stop returns.
+	Stop()
+}
+
+// Ensures Real implements the Clock interface.
+var _ Clock = Real{}
+
+// Real is a Clock backed by the system time.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (Real) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (Real) SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
@@ -0,0 +1,21 @@
+package clock
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReal_SleepContext(t *testing.T) {
+	var c Real
+
+	assert.NoError(t, c.SleepContext(context.Background(), time.Millisecond))
+
+	// Returns as soon as the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, c.SleepContext(ctx, time.Hour), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Minute)
+}
@@ -0,0 +1,117 @@
+package clock
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Ensures Simulated implements the Clock interface.
+var _ Clock = (*Simulated)(nil)
+
+// Simulated is a Clock of which the time only changes when it's explicitly moved forward.
+// Sleeping on a simulated clock returns immediately and moves the clock forward instead, which allows to run hours of time-dependent logic in milliseconds.
+type Simulated struct {
+	mu      sync.RWMutex
+	now     time.Time
+	tickers []*simulatedTicker
+}
+
+// NewSimulated creates a new simulated clock that starts at the given time.
+func NewSimulated(now time.Time) *Simulated {
+	return &Simulated{now: now}
+}
+
+func (s *Simulated) Now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.now
+}
+
+func (s *Simulated) Since(t time.Time) time.Duration {
+	return s.Now().Sub(t)
+}
+
+// Sleep moves the clock forward by the given duration.
+func (s *Simulated) Sleep(d time.Duration) {
+	s.Advance(d)
+}
+
+// SleepContext moves the clock forward by the given duration, unless ctx is already done.
+func (s *Simulated) SleepContext(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.Advance(d)
+	return nil
+}
+
+func (s *Simulated) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &simulatedTicker{
+		clock:  s,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   s.now.Add(d),
+	}
+	s.tickers = append(s.tickers, t)
+
+	return t
+}
+
+// Set moves the clock to the given time.
+// Tickers that are due by then will tick.
+func (s *Simulated) Set(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.now = now
+	for _, t := range s.tickers {
+		t.tick(now)
+	}
+}
+
+// Advance moves the clock forward by the given duration.
+// Tickers that are due by then will tick.
+func (s *Simulated) Advance(d time.Duration) {
+	s.Set(s.Now().Add(d))
+}
+
+type simulatedTicker struct {
+	clock  *Simulated
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+func (t *simulatedTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *simulatedTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.clock.tickers = slices.DeleteFunc(t.clock.tickers, func(other *simulatedTicker) bool {
+		return other == t
+	})
+}
+
+// tick sends all ticks that are due at the given time.
+// Like time.Ticker, ticks are dropped for slow receivers.
+func (t *simulatedTicker) tick(now time.Time) {
+	for !t.next.After(now) {
+		select {
+		case t.c <- t.next:
+		default:
+		}
+		t.next = t.next.Add(t.period)
+	}
+}
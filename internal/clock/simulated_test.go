@@ -0,0 +1,53 @@
+package clock
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSimulated_Sleep(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimulated(start)
+
+	c.Sleep(2 * time.Hour)
+
+	assert.Equal(t, start.Add(2*time.Hour), c.Now())
+	assert.Equal(t, 2*time.Hour, c.Since(start))
+}
+
+func TestSimulated_NewTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimulated(start)
+	ticker := c.NewTicker(time.Hour)
+
+	c.Advance(59 * time.Minute)
+	assert.Equal(t, 0, len(ticker.C()))
+
+	c.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Hour), <-ticker.C())
+
+	// Ticks are dropped when they aren't received in time.
+	c.Advance(3 * time.Hour)
+	assert.Equal(t, start.Add(2*time.Hour), <-ticker.C())
+	assert.Equal(t, 0, len(ticker.C()))
+
+	ticker.Stop()
+	c.Advance(time.Hour)
+	assert.Equal(t, 0, len(ticker.C()))
+}
+
+func TestSimulated_SleepContext(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimulated(start)
+
+	assert.NoError(t, c.SleepContext(context.Background(), time.Hour))
+	assert.Equal(t, start.Add(time.Hour), c.Now())
+
+	// The clock doesn't move once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.SleepContext(ctx, time.Hour), context.Canceled)
+	assert.Equal(t, start.Add(time.Hour), c.Now())
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -11,17 +13,44 @@ func TestLoad(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Equal(t, true, config.EnableTestMode)
+	assert.Equal(t, float64(1000), config.TestModeBalance)
+	assert.Equal(t, float64(5), config.SimulatorOptions.SlippageBps)
+	assert.Equal(t, 200, config.SimulatorOptions.FillLatency)
+	assert.Equal(t, false, config.EnableRecording)
+
+	assert.Equal(t, ReportPolicy, config.ReconciliationPolicy)
+	assert.Empty(t, config.ReconciliationAdoptList)
 
 	assert.Equal(t, true, config.LoggingOptions.Enable)
 	assert.Equal(t, false, config.LoggingOptions.EnableStructuredLogging)
 	assert.Equal(t, InfoLevel, config.LoggingOptions.LogLevel)
 	assert.Equal(t, SilentLevel, config.LoggingOptions.DatabaseLogLevel)
 
+	assert.Equal(t, true, config.Markets.Binance.Enable)
 	assert.Equal(t, "PASTE_YOUR_ACCESS_KEY_HERE", config.Markets.Binance.AccessKey)
 	assert.Equal(t, "PASTE_YOUR_SECRET_KEY_HERE", config.Markets.Binance.SecretKey)
+	assert.Equal(t, true, config.Markets.Binance.EnableStreaming)
+	assert.Equal(t, false, config.Markets.Kraken.Enable)
+	assert.Equal(t, "PASTE_YOUR_API_KEY_HERE", config.Markets.Kraken.APIKey)
+	assert.Equal(t, "PASTE_YOUR_PRIVATE_KEY_HERE", config.Markets.Kraken.PrivateKey)
+	assert.Equal(t, false, config.Markets.Coinbase.Enable)
+	assert.Equal(t, "PASTE_YOUR_KEY_NAME_HERE", config.Markets.Coinbase.KeyName)
+	assert.Equal(t, "PASTE_YOUR_PRIVATE_KEY_HERE", config.Markets.Coinbase.PrivateKey)
+	assert.Equal(t, false, config.Markets.KuCoin.Enable)
+	assert.Equal(t, "PASTE_YOUR_API_KEY_HERE", config.Markets.KuCoin.APIKey)
+	assert.Equal(t, "PASTE_YOUR_SECRET_KEY_HERE", config.Markets.KuCoin.SecretKey)
+	assert.Equal(t, "PASTE_YOUR_PASSPHRASE_HERE", config.Markets.KuCoin.Passphrase)
+	assert.Equal(t, false, config.Markets.Bybit.Enable)
+	assert.Equal(t, "PASTE_YOUR_API_KEY_HERE", config.Markets.Bybit.APIKey)
+	assert.Equal(t, "PASTE_YOUR_SECRET_KEY_HERE", config.Markets.Bybit.SecretKey)
 
 	assert.Equal(t, "USDT", config.TradingOptions.PairWith)
 	assert.Equal(t, float64(15), config.TradingOptions.Quantity)
+	assert.Equal(t, float64(0), config.TradingOptions.BalanceReserve)
+	assert.Equal(t, MarketOrderType, config.TradingOptions.OrderOptions.Type)
+	assert.Equal(t, 0.01, config.TradingOptions.OrderOptions.LimitOffset)
+	assert.Equal(t, 30, config.TradingOptions.OrderOptions.LimitTimeout)
+	assert.Equal(t, false, config.TradingOptions.OrderOptions.EnableOCO)
 	assert.Equal(t, false, config.TradingOptions.EnableDynamicQuantity)
 	assert.Equal(t, 3, config.TradingOptions.MaxCoins)
 	assert.Equal(t, 2, config.TradingOptions.TimeDifference)
@@ -43,4 +72,83 @@ func TestLoad(t *testing.T) {
 
 	assert.Equal(t, true, len(config.TradingOptions.DenyList) > 0)
 	assert.Contains(t, config.TradingOptions.DenyList, "GBPUSDT")
+
+	assert.Equal(t, false, config.ArbitrageOptions.Enable)
+	assert.Equal(t, 10, config.ArbitrageOptions.Interval)
+	assert.Equal(t, 0.5, config.ArbitrageOptions.MinSpread)
+	assert.Equal(t, float64(1), config.ArbitrageOptions.WithdrawalCost)
+	assert.Equal(t, 0.1, config.ArbitrageOptions.SpreadTolerance)
+	assert.Equal(t, false, config.ArbitrageOptions.EnableTrading)
+}
+
+func TestTradingOptions_Set(t *testing.T) {
+	var options TradingOptions
+
+	assert.NoError(t, options.Set("change_in_price", 4.5))
+	assert.Equal(t, 4.5, options.ChangeInPrice)
+
+	assert.NoError(t, options.Set("max_coins", 3))
+	assert.Equal(t, 3, options.MaxCoins)
+	assert.Error(t, options.Set("max_coins", 3.5))
+
+	assert.NoError(t, options.Set("trailing_stop_options.trailing_stop_loss", 0.2))
+	assert.Equal(t, 0.2, options.TrailingStopOptions.TrailingStopLoss)
+	assert.NoError(t, options.Set("trailing_take_profit", 0.1))
+	assert.Equal(t, 0.1, options.TrailingStopOptions.TrailingTakeProfit)
+	assert.NoError(t, options.Set("trailing_stop_options.enable", 1))
+	assert.Equal(t, true, options.TrailingStopOptions.Enable)
+
+	assert.Error(t, options.Set("pair_with", 1))
+	assert.Error(t, options.Set("unknown", 1))
+	assert.Error(t, options.Set("change_in_price.unknown", 1))
+}
+
+func TestSave(t *testing.T) {
+	expected, err := Load("../../config.example.yml")
+	assert.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "saved.yml")
+	assert.Nil(t, Save(path, expected))
+
+	actual, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestConfiguration_ForMarket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+markets:
+  binance:
+    enable: true
+  kraken:
+    enable: true
+    max_coins: 1
+    pair_with: USDC
+    quantity: 20
+trading_options:
+  max_coins: 3
+  pair_with: USDT
+  quantity: 15
+`), 0o600))
+
+	c, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, MarketOptions{Enable: true}, c.Markets.Binance.MarketOptions)
+	assert.Equal(t, MarketOptions{Enable: true, MaxCoins: 1, PairWith: "USDC", Quantity: 20}, c.Markets.Kraken.MarketOptions)
+
+	// Markets without their own options use the global ones.
+	binance := c.ForMarket(c.Markets.Binance.MarketOptions).TradingOptions
+	assert.Equal(t, 3, binance.MaxCoins)
+	assert.Equal(t, "USDT", binance.PairWith)
+	assert.Equal(t, 15.0, binance.Quantity)
+	kraken := c.ForMarket(c.Markets.Kraken.MarketOptions).TradingOptions
+	assert.Equal(t, 1, kraken.MaxCoins)
+	assert.Equal(t, "USDC", kraken.PairWith)
+	assert.Equal(t, 20.0, kraken.Quantity)
+
+	// The configuration itself isn't changed.
+	assert.Equal(t, 3, c.TradingOptions.MaxCoins)
+	assert.Equal(t, "USDT", c.TradingOptions.PairWith)
+	assert.Equal(t, 15.0, c.TradingOptions.Quantity)
 }
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+)
+
+// Save writes the given configuration to a YAML file at the given path, in the same format that Load expects.
+func Save(path string, c Configuration) error {
+	node, err := toYAMLNode(reflect.ValueOf(c))
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// toYAMLNode converts the given value to a YAML node.
+// Structs are converted to mappings keyed by their mapstructure tags, in the order the fields are declared.
+// Squashed structs are inlined, like mapstructure decodes them.
+func toYAMLNode(v reflect.Value) (*yaml.Node, error) {
+	if v.Kind() != reflect.Struct {
+		node := &yaml.Node{}
+		if err := node.Encode(v.Interface()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		value, err := toYAMLNode(v.Field(i))
+		if err != nil {
+			return nil, err
+		}
+
+		// The fields of squashed structs are part of the parent mapping.
+		if key == ",squash" {
+			node.Content = append(node.Content, value.Content...)
+			continue
+		}
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+
+	return node, nil
+}
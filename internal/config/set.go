@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Set sets the numeric or boolean trading option with the given name to the given value.
+// The name is the key of the option in the config file, e.g. `change_in_price`.
+// Options of nested sections can be addressed by their full path, e.g. `trailing_stop_options.trailing_stop_loss`, or just by their key if it's unique.
+// Boolean options are set to true for any value other than 0.
+func (o *TradingOptions) Set(name string, value float64) error {
+	field, ok := findField(reflect.ValueOf(o).Elem(), strings.Split(name, "."))
+	if !ok {
+		return fmt.Errorf("unknown trading option %q", name)
+	}
+
+	switch field.Kind() {
+	case reflect.Float64:
+		field.SetFloat(value)
+	case reflect.Int:
+		if value != math.Trunc(value) {
+			return fmt.Errorf("trading option %q must be a whole number", name)
+		}
+		field.SetInt(int64(value))
+	case reflect.Bool:
+		field.SetBool(value != 0)
+	default:
+		return fmt.Errorf("trading option %q is not a number", name)
+	}
+
+	return nil
+}
+
+// findField finds the field of the given struct that matches the given path of mapstructure keys.
+// If the path consists of a single key, nested structs are searched as well.
+func findField(v reflect.Value, path []string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if t.Field(i).Tag.Get("mapstructure") == path[0] {
+			if len(path) == 1 {
+				return field, true
+			}
+			if field.Kind() == reflect.Struct {
+				return findField(field, path[1:])
+			}
+			return reflect.Value{}, false
+		}
+	}
+
+	if len(path) == 1 {
+		for i := 0; i < t.NumField(); i++ {
+			if field := v.Field(i); field.Kind() == reflect.Struct {
+				if f, ok := findField(field, path); ok {
+					return f, true
+				}
+			}
+		}
+	}
+
+	return reflect.Value{}, false
+}
@@ -15,6 +15,29 @@ type Configuration struct {
 	// Setting this to false will use REAL funds, use at your own risk!
 	EnableTestMode bool `mapstructure:"enable_test_mode"`
 
+	// The virtual balance of the base currency (`pair_with`) to start with in test mode.
+	// Trades are skipped when it runs out, just like they would with real funds.
+	// Set this to 0 for an unlimited balance.
+	TestModeBalance float64 `mapstructure:"test_mode_balance"`
+
+	// Configuration for the simulated order execution in test mode and backtests.
+	SimulatorOptions SimulatorOptions `mapstructure:"simulator_options"`
+
+	// Whether to record the price snapshots fetched from the market to the `data/recordings` directory.
+	// Snapshots are recorded at most as often as the bot checks the prices, i.e. every `sell_timeout` or recheck interval, whichever is shorter.
+	// Recordings can be replayed later on, e.g. to backtest your trading options.
+	EnableRecording bool `mapstructure:"enable_recording"`
+
+	// What to do at startup when the open positions in the database don't match the balances on the market.
+	// This happens when coins are sold manually or the bot crashed in the middle of a trade.
+	// Valid options are: off, report, fix.
+	// Ignored in test mode.
+	ReconciliationPolicy ReconciliationPolicy `mapstructure:"reconciliation_policy"`
+
+	// List of tickers (e.g. BTC) that the fix reconciliation policy may start tracking when they're held but not tracked by the bot.
+	// Coins the bot has traded before may always be tracked again.
+	ReconciliationAdoptList []string `mapstructure:"reconciliation_adopt_list"`
+
 	// Configuration for bot logs.
 	LoggingOptions LoggingOptions `mapstructure:"logging_options"`
 
@@ -23,6 +46,33 @@ type Configuration struct {
 
 	// Main configuration for the trading strategy.
 	TradingOptions TradingOptions `mapstructure:"trading_options"`
+
+	// Configuration for finding price differences of the same coin between the enabled markets.
+	ArbitrageOptions ArbitrageOptions `mapstructure:"arbitrage_options"`
+}
+
+type ReconciliationPolicy string
+
+const (
+	// Don't reconcile the open positions at startup.
+	OffPolicy ReconciliationPolicy = "off"
+
+	// Only log the differences between the open positions and the balances on the market.
+	ReportPolicy ReconciliationPolicy = "report"
+
+	// Log the differences and update the open positions to match the balances on the market.
+	FixPolicy ReconciliationPolicy = "fix"
+)
+
+type SimulatorOptions struct {
+	// The difference in BASIS POINTS (1/100th of a percent) between the price of a coin and the price at which simulated orders are filled.
+	// Buy orders are filled above the price and sell orders below it, like market orders eating into the order book.
+	// Recommended to set this to at least 5 to avoid overly optimistic results.
+	SlippageBps float64 `mapstructure:"slippage_bps"`
+
+	// The amount of time in MILLISECONDS between placing a simulated order and filling it.
+	// The order is filled at the price of the coin after this delay.
+	FillLatency int `mapstructure:"fill_latency"`
 }
 
 type LoggingOptions struct {
@@ -46,12 +96,93 @@ type LoggingOptions struct {
 }
 
 type Markets struct {
-	Binance Binance `mapstructure:"binance"`
+	Binance  Binance  `mapstructure:"binance"`
+	Kraken   Kraken   `mapstructure:"kraken"`
+	Coinbase Coinbase `mapstructure:"coinbase"`
+	KuCoin   KuCoin   `mapstructure:"kucoin"`
+	Bybit    Bybit    `mapstructure:"bybit"`
+}
+
+// MarketOptions are the options that every market supports.
+type MarketOptions struct {
+	// Whether to trade on the market.
+	// Multiple markets can be enabled at the same time, in which case the bot trades on all of them concurrently. Binance is used if none is enabled.
+	Enable bool `mapstructure:"enable"`
+
+	// The maximum number of coins to buy at a time on the market.
+	// Defaults to `trading_options.max_coins` if not set.
+	MaxCoins int `mapstructure:"max_coins"`
+
+	// The base currency to trade with on the market.
+	// Defaults to `trading_options.pair_with` if not set.
+	PairWith string `mapstructure:"pair_with"`
+
+	// The total amount per trade on the market.
+	// Defaults to `trading_options.quantity` if not set.
+	Quantity float64 `mapstructure:"quantity"`
+}
+
+// ForMarket returns a copy of the configuration with the trading options overridden by the given options of a market.
+// The arbitrage detector compares and trades all markets with the trading options that aren't overridden.
+func (c Configuration) ForMarket(options MarketOptions) Configuration {
+	if options.MaxCoins != 0 {
+		c.TradingOptions.MaxCoins = options.MaxCoins
+	}
+	if options.PairWith != "" {
+		c.TradingOptions.PairWith = options.PairWith
+	}
+	if options.Quantity != 0 {
+		c.TradingOptions.Quantity = options.Quantity
+	}
+	return c
 }
 
 type Binance struct {
+	MarketOptions `mapstructure:",squash"`
+
 	AccessKey string `mapstructure:"access_key"`
 	SecretKey string `mapstructure:"secret_key"`
+
+	// Whether to stream the prices of all coins over a websocket connection instead of polling the REST API for them.
+	// Recommended to set this to true, because it uses less of the API rate limit and the prices are more up to date.
+	EnableStreaming bool `mapstructure:"enable_streaming"`
+}
+
+type Kraken struct {
+	MarketOptions `mapstructure:",squash"`
+
+	APIKey string `mapstructure:"api_key"`
+
+	// The base64 encoded private key of the API key.
+	PrivateKey string `mapstructure:"private_key"`
+}
+
+type Coinbase struct {
+	MarketOptions `mapstructure:",squash"`
+
+	// The name of the API key, e.g. organizations/{org_id}/apiKeys/{key_id}.
+	KeyName string `mapstructure:"key_name"`
+
+	// The PEM encoded EC private key of the API key.
+	PrivateKey string `mapstructure:"private_key"`
+}
+
+type KuCoin struct {
+	MarketOptions `mapstructure:",squash"`
+
+	APIKey    string `mapstructure:"api_key"`
+	SecretKey string `mapstructure:"secret_key"`
+
+	// The passphrase that was chosen when the API key was created.
+	Passphrase string `mapstructure:"passphrase"`
+}
+
+// Bybit places spot orders from the unified trading account.
+type Bybit struct {
+	MarketOptions `mapstructure:",squash"`
+
+	APIKey    string `mapstructure:"api_key"`
+	SecretKey string `mapstructure:"secret_key"`
 }
 
 type TradingOptions struct {
@@ -65,6 +196,10 @@ type TradingOptions struct {
 	// Recommended to specify no less than 12 USDT.
 	Quantity float64 `mapstructure:"quantity"`
 
+	// The amount of the base currency to keep in your account at all times.
+	// Trades are skipped when your free balance minus this reserve doesn't cover the `quantity`.
+	BalanceReserve float64 `mapstructure:"balance_reserve"`
+
 	// Allows the bot to dynamically adjust the trade Quantity based on the profit/loss of all trades during the current session.
 	EnableDynamicQuantity bool `mapstructure:"enable_dynamic_quantity"`
 
@@ -126,6 +261,9 @@ type TradingOptions struct {
 	// Configuration for trailing stop loss.
 	TrailingStopOptions TrailingStopOptions `mapstructure:"trailing_stop_options"`
 
+	// Configuration for the type of orders to place.
+	OrderOptions OrderOptions `mapstructure:"order_options"`
+
 	// List of tickers to include.
 	AllowList []string `mapstructure:"allow_list"`
 
@@ -133,6 +271,38 @@ type TradingOptions struct {
 	DenyList []string `mapstructure:"deny_list"`
 }
 
+type OrderType string
+
+const (
+	// Market orders are filled immediately at the best available price, paying the taker fee.
+	MarketOrderType OrderType = "market"
+
+	// Limit orders are placed on the order book and filled at the given price or better, paying the maker fee.
+	LimitOrderType OrderType = "limit"
+)
+
+type OrderOptions struct {
+	// The type of orders to place.
+	// Valid options are: market, limit.
+	// Defaults to market. Test mode and backtests always simulate market orders.
+	Type OrderType `mapstructure:"type"`
+
+	// Specify in PERCENTAGE how far limit orders are placed from the best price on the order book, towards the other side.
+	// Buy orders are placed this much above the best bid and sell orders this much below the best ask.
+	// For example, 0 places the order at the best bid or ask, which avoids the taker fee but may take a while to fill.
+	LimitOffset float64 `mapstructure:"limit_offset"`
+
+	// The amount of time in SECONDS to wait for a limit order to fill.
+	// Afterward, the order is cancelled and the unfilled quantity is bought or sold with a market order instead.
+	LimitTimeout int `mapstructure:"limit_timeout"`
+
+	// Whether to place an OCO order on the market right after buying a coin, which sells it at the take profit or stop loss.
+	// This protects your positions even when the bot is stopped or slow, because the market executes the order itself.
+	// The OCO order is cancelled and replaced whenever the trailing stop loss is adjusted.
+	// Only supported by some markets, the bot falls back to monitoring the price on others.
+	EnableOCO bool `mapstructure:"enable_oco"`
+}
+
 type TrailingStopOptions struct {
 	// Whether to enable trailing stop loss.
 	// If true, the bot will automatically move the stop loss up as the price of the coin increases to 'lock-in' a profit.
@@ -145,3 +315,29 @@ type TrailingStopOptions struct {
 	// When `take_profit` is reached, the `take_profit` is changed to `trailing_take_profit` PERCENTAGE above the current price.
 	TrailingTakeProfit float64 `mapstructure:"trailing_take_profit"`
 }
+
+type ArbitrageOptions struct {
+	// Whether to compare the prices of the same coins between the enabled markets.
+	// Requires at least two enabled markets. Opportunities are logged and stored in the database.
+	Enable bool `mapstructure:"enable"`
+
+	// The amount of time in SECONDS to wait between each comparison.
+	Interval int `mapstructure:"interval"`
+
+	// The minimum expected profit in PERCENTAGE of the `quantity` per trade to flag a price difference as an opportunity.
+	// The profit is calculated after paying `trading_fee_taker` on both markets and the `withdrawal_cost`.
+	MinSpread float64 `mapstructure:"min_spread"`
+
+	// The estimated cost in the base currency (`pair_with`) to move the coins bought on one market to the other market, per trade.
+	WithdrawalCost float64 `mapstructure:"withdrawal_cost"`
+
+	// The minimum change in PERCENTAGE points of the spread of an opportunity before it's stored again.
+	// Opportunities are stored when they're first found, and whenever they're traded.
+	SpreadTolerance float64 `mapstructure:"spread_tolerance"`
+
+	// Whether to buy the coin on the cheaper market and sell it on the more expensive market at the same time when an opportunity is found.
+	// This only happens when there is enough of the base currency on the cheaper market and enough of the coin on the more expensive market,
+	// not counting the coins the bot holds as open positions there.
+	// Coins are never moved between markets automatically.
+	EnableTrading bool `mapstructure:"enable_trading"`
+}
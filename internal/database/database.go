@@ -2,6 +2,7 @@ package database
 
 import (
 	"github.com/sleeyax/voltra/internal/database/models"
+	"time"
 )
 
 type Database interface {
@@ -12,5 +13,24 @@ type Database interface {
 	GetLastOrder(orderType models.OrderType, market, symbol string) (models.Order, bool)
 	DeleteOrder(order models.Order)
 	SaveCache(cache models.Cache)
-	GetCache(symbol string) (models.Cache, bool)
+	SaveCaches(caches []models.Cache)
+	GetCache(market, symbol string) (models.Cache, bool)
+}
+
+// ArbitrageDatabase stores the arbitrage opportunities that were found between markets.
+type ArbitrageDatabase interface {
+	SaveArbitrageOpportunity(opportunity models.ArbitrageOpportunity)
+
+	// GetArbitrageOpportunities returns all arbitrage opportunities, oldest first.
+	GetArbitrageOpportunities() []models.ArbitrageOpportunity
+}
+
+// KlineDatabase stores historical market data.
+type KlineDatabase interface {
+	// SaveKlines saves the given klines, overwriting existing klines of the same symbol, interval and open time.
+	SaveKlines(klines []models.Kline) error
+
+	// GetKlines returns all klines of the given interval that close within the given time range, ordered by open time.
+	// A zero time disables the respective bound.
+	GetKlines(interval string, from, to time.Time) ([]models.Kline, error)
 }
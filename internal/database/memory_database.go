@@ -0,0 +1,172 @@
+package database
+
+import (
+	"cmp"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"slices"
+	"sync"
+)
+
+// MemoryDatabase is a database that only keeps its data in memory.
+// It's useful for short-lived sessions such as backtests, where nothing should be persisted to disk.
+type MemoryDatabase struct {
+	mu     sync.RWMutex
+	clock  clock.Clock
+	lastID uint
+	orders map[uint]models.Order
+	cache  map[cacheKey]models.Cache
+
+	opportunities []models.ArbitrageOpportunity
+}
+
+var _ Database = (*MemoryDatabase)(nil)
+var _ ArbitrageDatabase = (*MemoryDatabase)(nil)
+
+// cacheKey identifies the cached symbol info of a symbol on a market.
+type cacheKey struct {
+	market string
+	symbol string
+}
+
+// NewMemoryDatabase creates a new, empty in-memory database.
+// The given clock is used to set the creation and update timestamps of saved records.
+func NewMemoryDatabase(clock clock.Clock) *MemoryDatabase {
+	return &MemoryDatabase{
+		clock:  clock,
+		orders: make(map[uint]models.Order),
+		cache:  make(map[cacheKey]models.Cache),
+	}
+}
+
+// sortedOrders returns all orders that match the given predicate, sorted by ID.
+func (d *MemoryDatabase) sortedOrders(predicate func(order models.Order) bool) []models.Order {
+	var orders []models.Order
+	for _, order := range d.orders {
+		if predicate(order) {
+			orders = append(orders, order)
+		}
+	}
+	slices.SortFunc(orders, func(a, b models.Order) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return orders
+}
+
+func (d *MemoryDatabase) SaveOrder(order models.Order) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	if order.ID == 0 {
+		d.lastID++
+		order.ID = d.lastID
+	}
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	order.UpdatedAt = now
+
+	d.orders[order.ID] = order
+}
+
+func (d *MemoryDatabase) HasOrder(orderType models.OrderType, market, symbol string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, order := range d.orders {
+		if order.Type == orderType && order.Market == market && order.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *MemoryDatabase) CountOrders(orderType models.OrderType, market string) int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var count int64
+	for _, order := range d.orders {
+		if order.Type == orderType && order.Market == market {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *MemoryDatabase) GetOrders(orderType models.OrderType, market string) []models.Order {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.sortedOrders(func(order models.Order) bool {
+		return order.Type == orderType && order.Market == market
+	})
+}
+
+func (d *MemoryDatabase) GetLastOrder(orderType models.OrderType, market, symbol string) (models.Order, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	orders := d.sortedOrders(func(order models.Order) bool {
+		return order.Type == orderType && order.Market == market && order.Symbol == symbol
+	})
+	if len(orders) == 0 {
+		return models.Order{}, false
+	}
+	return orders[len(orders)-1], true
+}
+
+func (d *MemoryDatabase) DeleteOrder(order models.Order) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.orders, order.ID)
+}
+
+func (d *MemoryDatabase) SaveCache(cache models.Cache) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cache.CreatedAt.IsZero() {
+		cache.CreatedAt = d.clock.Now()
+	}
+	d.cache[cacheKey{cache.Market, cache.Symbol}] = cache
+}
+
+func (d *MemoryDatabase) SaveCaches(caches []models.Cache) {
+	for _, cache := range caches {
+		d.SaveCache(cache)
+	}
+}
+
+func (d *MemoryDatabase) GetCache(market, symbol string) (models.Cache, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	cache, ok := d.cache[cacheKey{market, symbol}]
+	return cache, ok
+}
+
+func (d *MemoryDatabase) SaveArbitrageOpportunity(opportunity models.ArbitrageOpportunity) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	if opportunity.ID == 0 {
+		opportunity.ID = uint(len(d.opportunities) + 1)
+		opportunity.CreatedAt = now
+		opportunity.UpdatedAt = now
+		d.opportunities = append(d.opportunities, opportunity)
+		return
+	}
+
+	opportunity.UpdatedAt = now
+	d.opportunities[opportunity.ID-1] = opportunity
+}
+
+func (d *MemoryDatabase) GetArbitrageOpportunities() []models.ArbitrageOpportunity {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return slices.Clone(d.opportunities)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"github.com/sleeyax/voltra/internal/market"
+	"gorm.io/gorm"
+)
+
+// ArbitrageOpportunity is a difference between the prices of a symbol on two markets that is large enough to profit from after all costs.
+type ArbitrageOpportunity struct {
+	gorm.Model
+
+	Symbol string `gorm:"index"`
+
+	// The market with the lowest price, on which to buy the symbol.
+	BuyMarket string
+	BuyPrice  float64
+
+	// The market with the highest price, on which to sell the symbol.
+	SellMarket string
+	SellPrice  float64
+
+	// The difference in PERCENTAGE between the sell price and the buy price.
+	Spread float64
+
+	// The expected profit in PERCENTAGE of the trade quantity, after the trading fees on both markets and the withdrawal cost.
+	NetSpread float64
+
+	// Whether the symbol was bought and sold on both markets.
+	Traded bool
+
+	// The orders placed on the buy and sell market, if any.
+	// They're only stored here, and not with the orders of the bots, so that the bots don't sell the bought coins again.
+	BuyOrder  market.Order `gorm:"embedded;embeddedPrefix:buy_order_"`
+	SellOrder market.Order `gorm:"embedded;embeddedPrefix:sell_order_"`
+
+	// Whether the opportunity was found in test mode.
+	IsTestMode bool
+}
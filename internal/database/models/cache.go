@@ -1,11 +1,52 @@
 package models
 
 import (
+	"github.com/sleeyax/voltra/internal/market"
 	"time"
 )
 
+// Cache is the symbol info of a symbol on a market.
 type Cache struct {
-	Symbol    string `gorm:"primarykey"`
-	StepSize  float64
-	CreatedAt time.Time
+	Market              string `gorm:"primarykey"`
+	Symbol              string `gorm:"primarykey"`
+	StepSize            float64
+	MinQuantity         float64
+	MaxQuantity         float64
+	TickSize            float64
+	MinNotional         float64
+	BaseAssetPrecision  int
+	QuoteAssetPrecision int
+	Halted              bool `gorm:"not null;default:false"`
+	CreatedAt           time.Time
+}
+
+// NewCache creates a cache entry for the given symbol info on the market with the given name.
+func NewCache(marketName string, info market.SymbolInfo) Cache {
+	return Cache{
+		Market:              marketName,
+		Symbol:              info.Symbol,
+		StepSize:            info.StepSize,
+		MinQuantity:         info.MinQuantity,
+		MaxQuantity:         info.MaxQuantity,
+		TickSize:            info.TickSize,
+		MinNotional:         info.MinNotional,
+		BaseAssetPrecision:  info.BaseAssetPrecision,
+		QuoteAssetPrecision: info.QuoteAssetPrecision,
+		Halted:              info.Halted,
+	}
+}
+
+// SymbolInfo returns the cached symbol info.
+func (c Cache) SymbolInfo() market.SymbolInfo {
+	return market.SymbolInfo{
+		Symbol:              c.Symbol,
+		StepSize:            c.StepSize,
+		MinQuantity:         c.MinQuantity,
+		MaxQuantity:         c.MaxQuantity,
+		TickSize:            c.TickSize,
+		MinNotional:         c.MinNotional,
+		BaseAssetPrecision:  c.BaseAssetPrecision,
+		QuoteAssetPrecision: c.QuoteAssetPrecision,
+		Halted:              c.Halted,
+	}
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Kline is a candlestick of a symbol over a fixed interval of time.
+type Kline struct {
+	Symbol   string    `gorm:"primarykey"`
+	Interval string    `gorm:"primarykey"` // In the Binance notation, e.g. 1m or 5m.
+	OpenTime time.Time `gorm:"primarykey"`
+
+	CloseTime time.Time `gorm:"index"`
+
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
+
+	// Volume traded in the base asset.
+	Volume float64
+
+	// Volume traded in the quote asset.
+	QuoteVolume float64
+}
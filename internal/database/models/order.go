@@ -35,6 +35,11 @@ type Order struct {
 	// This field is only set when the type is a buy order.
 	StopLoss *float64
 
+	// Optional field to store the OCO order that sells the symbol on the market itself.
+	// This field may be updated when trailing stop loss is used.
+	// This field is only set when the type is a buy order and OCO orders are enabled.
+	OCO market.OCO `gorm:"embedded;embeddedPrefix:oco_"`
+
 	// Optional field for the estimated profit.
 	// This field is only set when the type is a sell order.
 	PriceChangePercentage *float64
@@ -47,6 +52,11 @@ type Order struct {
 	// This field is only set when the type is a sell order.
 	RealizedProfitLoss *float64
 
+	// Optional field to store the volume of the symbol that was left behind because it was too little to be sold on its own.
+	// It's added to the next buy of the symbol, so that it's sold along with it, after which it's reset to 0.
+	// This field is only set when the type is a sell order.
+	Dust float64
+
 	// Whether the order is a dummy/fake order, created in test mode.
 	IsTestMode bool
 }
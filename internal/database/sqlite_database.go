@@ -6,6 +6,7 @@ import (
 	"github.com/sleeyax/voltra/internal/database/models"
 	"github.com/sleeyax/voltra/internal/storage"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"log"
 	"os"
@@ -18,6 +19,8 @@ type SqliteDatabase struct {
 }
 
 var _ Database = (*SqliteDatabase)(nil)
+var _ KlineDatabase = (*SqliteDatabase)(nil)
+var _ ArbitrageDatabase = (*SqliteDatabase)(nil)
 
 func NewSqliteDatabase(fileName string, options config.LoggingOptions) *SqliteDatabase {
 	var logLevel config.LogLevel
@@ -49,8 +52,21 @@ func NewSqliteDatabase(fileName string, options config.LoggingOptions) *SqliteDa
 		panic("failed to connect to the local database: " + err.Error())
 	}
 
+	// SQLite only allows one writer at a time, and the bots of all enabled markets share the database.
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+
 	_ = db.AutoMigrate(&models.Order{})
+	// Cache entries from before multiple markets were supported aren't keyed by market, so make sure they are fetched again.
+	if db.Migrator().HasTable(&models.Cache{}) && !db.Migrator().HasColumn(&models.Cache{}, "Market") {
+		_ = db.Migrator().DropTable(&models.Cache{})
+	}
 	_ = db.AutoMigrate(&models.Cache{})
+	// Cache entries from before the symbol filters were added don't have them, so make sure they are fetched again.
+	db.Where("tick_size IS NULL").Delete(&models.Cache{})
+	_ = db.AutoMigrate(&models.Kline{})
+	_ = db.AutoMigrate(&models.ArbitrageOpportunity{})
 
 	return &SqliteDatabase{db: db}
 }
@@ -103,9 +119,16 @@ func (d *SqliteDatabase) SaveCache(cache models.Cache) {
 	d.db.Save(&cache)
 }
 
-func (d *SqliteDatabase) GetCache(symbol string) (models.Cache, bool) {
+func (d *SqliteDatabase) SaveCaches(caches []models.Cache) {
+	if len(caches) == 0 {
+		return
+	}
+	d.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(caches, 500)
+}
+
+func (d *SqliteDatabase) GetCache(market, symbol string) (models.Cache, bool) {
 	var cache models.Cache
-	if err := d.db.Where("symbol = ?", symbol).First(&cache).Error; err != nil {
+	if err := d.db.Where("market = ? AND symbol = ?", market, symbol).First(&cache).Error; err != nil {
 		return cache, false
 	}
 	return cache, true
@@ -118,3 +141,34 @@ func (d *SqliteDatabase) GetLastOrder(orderType models.OrderType, market, symbol
 	}
 	return order, true
 }
+
+func (d *SqliteDatabase) SaveArbitrageOpportunity(opportunity models.ArbitrageOpportunity) {
+	d.db.Save(&opportunity)
+}
+
+func (d *SqliteDatabase) GetArbitrageOpportunities() []models.ArbitrageOpportunity {
+	var opportunities []models.ArbitrageOpportunity
+	d.db.Order("id").Find(&opportunities)
+	return opportunities
+}
+
+func (d *SqliteDatabase) SaveKlines(klines []models.Kline) error {
+	if len(klines) == 0 {
+		return nil
+	}
+	return d.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(klines, 500).Error
+}
+
+func (d *SqliteDatabase) GetKlines(interval string, from, to time.Time) ([]models.Kline, error) {
+	query := d.db.Where("interval = ?", interval).Order("open_time")
+	if !from.IsZero() {
+		query = query.Where("close_time >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("close_time <= ?", to)
+	}
+
+	var klines []models.Kline
+	err := query.Find(&klines).Error
+	return klines, err
+}
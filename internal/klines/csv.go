@@ -0,0 +1,171 @@
+package klines
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binance kline CSV columns.
+// See: https://github.com/binance/binance-public-data#klines
+const (
+	columnOpenTime = iota
+	columnOpen
+	columnHigh
+	columnLow
+	columnClose
+	columnVolume
+	columnCloseTime
+	columnQuoteVolume
+	minColumns
+)
+
+// Timestamps above this value are in microseconds instead of milliseconds.
+// Binance switched to microseconds for spot data from 2025 onwards.
+const microsecondsThreshold = 1e14
+
+// ParseCSV parses klines in the Binance CSV format for the given symbol and interval.
+// An optional header row is skipped.
+func ParseCSV(r io.Reader, symbol, interval string) ([]models.Kline, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var klines []models.Kline
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return klines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(row) < minColumns {
+			return nil, fmt.Errorf("line %d: expected at least %d columns but got %d", line, minColumns, len(row))
+		}
+
+		if line == 1 {
+			if _, err = strconv.ParseInt(row[columnOpenTime], 10, 64); err != nil {
+				// Skip the header.
+				continue
+			}
+		}
+
+		kline, err := parseRow(row, symbol, interval)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		klines = append(klines, kline)
+	}
+}
+
+func parseRow(row []string, symbol, interval string) (models.Kline, error) {
+	var floats [minColumns]float64
+	for _, column := range []int{columnOpen, columnHigh, columnLow, columnClose, columnVolume, columnQuoteVolume} {
+		f, err := strconv.ParseFloat(row[column], 64)
+		if err != nil {
+			return models.Kline{}, err
+		}
+		floats[column] = f
+	}
+
+	openTime, err := parseTimestamp(row[columnOpenTime])
+	if err != nil {
+		return models.Kline{}, err
+	}
+	closeTime, err := parseTimestamp(row[columnCloseTime])
+	if err != nil {
+		return models.Kline{}, err
+	}
+
+	return models.Kline{
+		Symbol:      symbol,
+		Interval:    interval,
+		OpenTime:    openTime,
+		CloseTime:   closeTime,
+		Open:        floats[columnOpen],
+		High:        floats[columnHigh],
+		Low:         floats[columnLow],
+		Close:       floats[columnClose],
+		Volume:      floats[columnVolume],
+		QuoteVolume: floats[columnQuoteVolume],
+	}, nil
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ts > microsecondsThreshold {
+		return time.UnixMicro(ts).UTC(), nil
+	}
+	return time.UnixMilli(ts).UTC(), nil
+}
+
+// SymbolFromFileName derives the symbol from the name of a Binance kline dump.
+// For example, the symbol of `BTCUSDT-1m-2024-01.zip` is `BTCUSDT`.
+func SymbolFromFileName(path string) string {
+	name := filepath.Base(path)
+	if i := strings.IndexByte(name, '-'); i != -1 {
+		return name[:i]
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// IntervalFromFileName derives the kline interval from the name of a Binance kline dump.
+// For example, the interval of `BTCUSDT-1m-2024-01.zip` is `1m`.
+// An empty string is returned if the name doesn't contain an interval.
+func IntervalFromFileName(path string) string {
+	parts := strings.Split(filepath.Base(path), "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// ParseFile parses the klines of the given symbol and interval from a CSV file or a zip archive of CSV files, as published by Binance.
+func ParseFile(path, symbol, interval string) ([]models.Kline, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+
+		var klines []models.Kline
+		for _, file := range archive.File {
+			if !strings.EqualFold(filepath.Ext(file.Name), ".csv") {
+				continue
+			}
+			f, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			k, err := ParseCSV(f, symbol, interval)
+			_ = f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file.Name, err)
+			}
+			klines = append(klines, k...)
+		}
+		return klines, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseCSV(f, symbol, interval)
+}
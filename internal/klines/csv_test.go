@@ -0,0 +1,43 @@
+package klines
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSV(t *testing.T) {
+	data := `open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore
+1704067200000,42283.58,42298.62,42261.02,42298.61,35.92724,1704067259999,1519224.71437601,1327,19.40866,820708.95637581,0
+1735689600000000,93576.00,93610.93,93537.50,93610.93,8.21827,1735689659999999,768978.57930480,1317,5.60573,524516.00442920,0
+`
+	klines, err := ParseCSV(strings.NewReader(data), "BTCUSDT", "1m")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(klines))
+
+	assert.Equal(t, "BTCUSDT", klines[0].Symbol)
+	assert.Equal(t, "1m", klines[0].Interval)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), klines[0].OpenTime)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 59, 999_000_000, time.UTC), klines[0].CloseTime)
+	assert.Equal(t, 42298.61, klines[0].Close)
+	assert.Equal(t, 1519224.71437601, klines[0].QuoteVolume)
+
+	// Timestamps in microseconds.
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), klines[1].OpenTime)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 59, 999_999_000, time.UTC), klines[1].CloseTime)
+
+	_, err = ParseCSV(strings.NewReader("1704067200000,42283.58,42298.62\n"), "BTCUSDT", "1m")
+	assert.Error(t, err)
+}
+
+func TestSymbolFromFileName(t *testing.T) {
+	assert.Equal(t, "BTCUSDT", SymbolFromFileName("data/BTCUSDT-1m-2024-01.zip"))
+	assert.Equal(t, "ETHUSDT", SymbolFromFileName("ETHUSDT.csv"))
+}
+
+func TestIntervalFromFileName(t *testing.T) {
+	assert.Equal(t, "1m", IntervalFromFileName("data/BTCUSDT-1m-2024-01.zip"))
+	assert.Equal(t, "1h", IntervalFromFileName("ETHUSDT-1h-2024-01-31.csv"))
+	assert.Equal(t, "", IntervalFromFileName("ETHUSDT.csv"))
+}
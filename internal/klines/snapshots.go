@@ -0,0 +1,99 @@
+package klines
+
+import (
+	"github.com/sleeyax/voltra/internal/database"
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/sleeyax/voltra/internal/market"
+	"slices"
+	"time"
+)
+
+// quoteVolumeWindow is the period over which the quote volume of a coin is summed, to match the 24h ticker statistics of a live market.
+const quoteVolumeWindow = 24 * time.Hour
+
+// Snapshots converts the given klines into snapshots of the given market at the given resolution.
+// Each snapshot is taken at the end of a period of the given resolution and contains the close price of the latest kline of each symbol within that period.
+// The quote volume traded of each coin is the sum of the quote volume of its klines over the preceding 24 hours.
+// A coin without a kline in a period keeps its last known price, so that gaps in the data don't look like a crash to the bot.
+func Snapshots(klines []models.Kline, marketName string, resolution time.Duration) []market.Snapshot {
+	bySymbol := make(map[string][]models.Kline)
+	for _, kline := range klines {
+		bySymbol[kline.Symbol] = append(bySymbol[kline.Symbol], kline)
+	}
+
+	coinsByTime := make(map[time.Time]market.Coins)
+	for symbol, symbolKlines := range bySymbol {
+		slices.SortFunc(symbolKlines, func(a, b models.Kline) int {
+			return a.OpenTime.Compare(b.OpenTime)
+		})
+
+		var quoteVolume float64
+		first := 0
+		for i, kline := range symbolKlines {
+			quoteVolume += kline.QuoteVolume
+			// Klines that are longer than the window itself still count towards their own volume.
+			for ; first < i && symbolKlines[first].OpenTime.Before(kline.CloseTime.Add(-quoteVolumeWindow)); first++ {
+				quoteVolume -= symbolKlines[first].QuoteVolume
+			}
+
+			snapshotTime := kline.CloseTime.Truncate(resolution).Add(resolution)
+			coins, ok := coinsByTime[snapshotTime]
+			if !ok {
+				coins = make(market.Coins)
+				coinsByTime[snapshotTime] = coins
+			}
+			coins[symbol] = market.Coin{
+				Symbol:            symbol,
+				Price:             kline.Close,
+				QuoteVolumeTraded: quoteVolume,
+				Time:              snapshotTime,
+			}
+		}
+	}
+
+	snapshots := make([]market.Snapshot, 0, len(coinsByTime))
+	for t, coins := range coinsByTime {
+		snapshots = append(snapshots, market.Snapshot{Market: marketName, Time: t, Coins: coins})
+	}
+	slices.SortFunc(snapshots, func(a, b market.Snapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	last := make(market.Coins)
+	for _, snapshot := range snapshots {
+		for symbol, coin := range last {
+			if _, ok := snapshot.Coins[symbol]; !ok {
+				coin.Time = snapshot.Time
+				snapshot.Coins[symbol] = coin
+			}
+		}
+		for symbol, coin := range snapshot.Coins {
+			last[symbol] = coin
+		}
+	}
+
+	return snapshots
+}
+
+// Load loads the klines of the given interval within the given time range from the database and converts them into snapshots.
+// Klines of the 24 hours before the start of the range are loaded as well, so that the quote volume traded is complete from the very first snapshot.
+func Load(db database.KlineDatabase, marketName, interval string, resolution time.Duration, from, to time.Time) ([]market.Snapshot, error) {
+	warmUp := from
+	if !from.IsZero() {
+		warmUp = from.Add(-quoteVolumeWindow)
+	}
+
+	klines, err := db.GetKlines(interval, warmUp, to)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := Snapshots(klines, marketName, resolution)
+	if !from.IsZero() {
+		snapshots = slices.DeleteFunc(snapshots, func(s market.Snapshot) bool {
+			return s.Time.Before(from)
+		})
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,56 @@
+package klines
+
+import (
+	"github.com/sleeyax/voltra/internal/database/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSnapshots(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	kline := func(symbol string, hour int, price, quoteVolume float64) models.Kline {
+		openTime := start.Add(time.Duration(hour) * time.Hour)
+		return models.Kline{
+			Symbol:      symbol,
+			OpenTime:    openTime,
+			CloseTime:   openTime.Add(time.Hour - time.Millisecond),
+			Close:       price,
+			QuoteVolume: quoteVolume,
+		}
+	}
+
+	var klines []models.Kline
+	for hour := 0; hour < 26; hour++ {
+		klines = append(klines, kline("BTCUSDT", hour, float64(100+hour), 10))
+	}
+	klines = append(klines, kline("ETHUSDT", 1, 50, 20))
+
+	snapshots := Snapshots(klines, "binance", time.Hour)
+	assert.Equal(t, 26, len(snapshots))
+
+	assert.Equal(t, "binance", snapshots[0].Market)
+	assert.Equal(t, start.Add(time.Hour), snapshots[0].Time)
+	assert.Equal(t, 100.0, snapshots[0].Coins["BTCUSDT"].Price)
+	assert.Equal(t, 10.0, snapshots[0].Coins["BTCUSDT"].QuoteVolumeTraded)
+	_, ok := snapshots[0].Coins["ETHUSDT"]
+	assert.False(t, ok)
+
+	assert.Equal(t, 50.0, snapshots[1].Coins["ETHUSDT"].Price)
+	assert.Equal(t, 20.0, snapshots[1].Coins["ETHUSDT"].QuoteVolumeTraded)
+
+	// Coins without a kline in a period keep their last known price.
+	assert.Equal(t, 50.0, snapshots[2].Coins["ETHUSDT"].Price)
+	assert.Equal(t, snapshots[2].Time, snapshots[2].Coins["ETHUSDT"].Time)
+	assert.Equal(t, 50.0, snapshots[25].Coins["ETHUSDT"].Price)
+
+	// The quote volume traded never exceeds 24 hours worth of klines.
+	assert.Equal(t, 240.0, snapshots[23].Coins["BTCUSDT"].QuoteVolumeTraded)
+	assert.Equal(t, 240.0, snapshots[25].Coins["BTCUSDT"].QuoteVolumeTraded)
+
+	// A lower resolution only keeps the latest price of each period.
+	snapshots = Snapshots(klines, "binance", 4*time.Hour)
+	assert.Equal(t, 7, len(snapshots))
+	assert.Equal(t, start.Add(4*time.Hour), snapshots[0].Time)
+	assert.Equal(t, 103.0, snapshots[0].Coins["BTCUSDT"].Price)
+}
@@ -0,0 +1,21 @@
+package market
+
+// Balance is the amount of an asset held on the market.
+type Balance struct {
+	// The asset, e.g. BTC.
+	Asset string
+
+	// The amount that is available for trading.
+	Free float64
+
+	// The amount that is reserved for open orders.
+	Locked float64
+}
+
+// Total returns the total amount held, including the amount reserved for open orders.
+func (b Balance) Total() float64 {
+	return b.Free + b.Locked
+}
+
+// Balances maps each asset to its balance.
+type Balances map[string]Balance
@@ -2,8 +2,14 @@ package market
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/sleeyax/voltra/internal/clock"
 	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/utils"
+	"net/http"
 	"strconv"
 	"time"
 )
@@ -11,15 +17,72 @@ import (
 // Ensures Binance implements the Market interface.
 var _ Market = (*Binance)(nil)
 
+// binanceRequestWeightLimit is the maximum total weight of all requests to send to Binance per minute.
+// Binance allows a maximum weight of 1200 per minute per IP.
+const binanceRequestWeightLimit = 1200
+
+// binanceStopLimitOffset is the difference in PERCENTAGE between the stop price and the limit price of the stop loss order of an OCO.
+// The limit price is lower, so that the order is still filled when the price drops quickly after the stop price has been reached.
+const binanceStopLimitOffset = 0.5
+
+// binanceOrderPollInterval is the interval at which the status of an open limit order is checked.
+const binanceOrderPollInterval = time.Second
+
+// binanceRequestWeights is the weight of each endpoint that is called, as documented by Binance.
+// Endpoints that are not listed weigh 1.
+var binanceRequestWeights = map[string]int{
+	"/api/v3/ticker/price": 4,
+	"/api/v3/ticker/24hr":  80,
+	"/api/v3/exchangeInfo": 20,
+	"/api/v3/account":      20,
+	"/api/v3/order":        4,
+	"/api/v3/myTrades":     20,
+	"/api/v3/orderList":    4,
+}
+
+// binanceSymbolNotFoundCode is the code of Binance errors for symbols that don't exist.
+const binanceSymbolNotFoundCode = -1121
+
+// binanceTransientErrorCodes maps the codes of Binance errors that are expected to resolve themselves to whether the request was rejected.
+// See https://developers.binance.com/docs/binance-spot-api-docs/errors.
+var binanceTransientErrorCodes = map[int64]bool{
+	-1000: false, // UNKNOWN
+	-1001: false, // DISCONNECTED
+	-1003: true,  // TOO_MANY_REQUESTS
+	-1006: false, // UNEXPECTED_RESP
+	-1007: false, // TIMEOUT
+	-1008: true,  // SERVER_BUSY
+	-1015: true,  // TOO_MANY_ORDERS
+}
+
 type Binance struct {
-	config config.Configuration
-	client *binance.Client
+	config            config.Configuration
+	client            *binance.Client
+	stream            *binanceStream
+	orderPollInterval time.Duration
 }
 
 func NewBinance(config config.Configuration) *Binance {
 	m := config.Markets.Binance
 	client := binance.NewClient(m.AccessKey, m.SecretKey)
-	return &Binance{config: config, client: client}
+	client.HTTPClient = &http.Client{
+		Transport: serverErrorTransport{
+			transport: newRateLimiter(http.DefaultTransport, clock.Real{}, binanceRequestWeightLimit, binanceRequestWeights, "X-Mbx-Used-Weight-1m"),
+		},
+	}
+	return &Binance{
+		config:            config,
+		client:            client,
+		stream:            newBinanceStream(binanceStreamEndpoint),
+		orderPollInterval: binanceOrderPollInterval,
+	}
+}
+
+// Stream keeps the prices returned by GetCoins up to date using Binance's all-market mini-ticker stream until the given context is cancelled.
+// This saves a request to the REST API on every GetCoins call. The connection is automatically re-established whenever it drops.
+// Meanwhile, GetCoins falls back to the REST API. Connection errors are passed to onError.
+func (b *Binance) Stream(ctx context.Context, onError func(error)) {
+	b.stream.run(ctx, b.fetchCoins, onError)
 }
 
 func (b *Binance) Name() string {
@@ -27,9 +90,18 @@ func (b *Binance) Name() string {
 }
 
 func (b *Binance) GetCoins(ctx context.Context) (Coins, error) {
+	if coins, ok := b.stream.snapshot(time.Now()); ok {
+		return coins, nil
+	}
+
+	return b.fetchCoins(ctx)
+}
+
+// fetchCoins returns the current price of all coins from the REST API.
+func (b *Binance) fetchCoins(ctx context.Context) (Coins, error) {
 	prices, err := b.client.NewListPricesService().Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, binanceError(err)
 	}
 
 	coins := make(Coins)
@@ -53,7 +125,7 @@ func (b *Binance) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
 	if b.config.TradingOptions.MinQuoteVolumeTraded != 0.0 {
 		priceStats24Hours, err := b.client.NewListPriceChangeStatsService().Do(ctx)
 		if err != nil {
-			return nil, err
+			return nil, binanceError(err)
 		}
 		for _, priceStat := range priceStats24Hours {
 			quoteVolumeAsFloat, _ := strconv.ParseFloat(priceStat.QuoteVolume, 64)
@@ -66,42 +138,123 @@ func (b *Binance) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
 func (b *Binance) GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error) {
 	info, err := b.client.NewExchangeInfoService().Symbol(symbol).Do(ctx)
 	if err != nil {
-		return SymbolInfo{}, err
+		return SymbolInfo{}, binanceError(err)
 	}
 
 	for _, s := range info.Symbols {
 		if s.Symbol == symbol {
-			stepSize, _ := strconv.ParseFloat(s.LotSizeFilter().StepSize, 64)
-
-			return SymbolInfo{
-				Symbol:   s.Symbol,
-				StepSize: stepSize,
-			}, nil
+			return toSymbolInfo(s), nil
 		}
 	}
 
 	return SymbolInfo{}, SymbolNotFoundError
 }
 
+func (b *Binance) GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error) {
+	info, err := b.client.NewExchangeInfoService().Do(ctx)
+	if err != nil {
+		return nil, binanceError(err)
+	}
+
+	symbols := make([]SymbolInfo, 0, len(info.Symbols))
+	for _, s := range info.Symbols {
+		symbols = append(symbols, toSymbolInfo(s))
+	}
+
+	return symbols, nil
+}
+
+// toSymbolInfo converts the given Binance symbol and its filters to a SymbolInfo.
+func toSymbolInfo(s binance.Symbol) SymbolInfo {
+	info := SymbolInfo{
+		Symbol:              s.Symbol,
+		BaseAssetPrecision:  s.BaseAssetPrecision,
+		QuoteAssetPrecision: s.QuoteAssetPrecision,
+		Halted:              s.Status != string(binance.SymbolStatusTypeTrading) || !s.IsSpotTradingAllowed,
+	}
+
+	if f := s.LotSizeFilter(); f != nil {
+		info.StepSize, _ = strconv.ParseFloat(f.StepSize, 64)
+		info.MinQuantity, _ = strconv.ParseFloat(f.MinQuantity, 64)
+		info.MaxQuantity, _ = strconv.ParseFloat(f.MaxQuantity, 64)
+	}
+
+	// Market orders may be limited to a lower maximum quantity than limit orders.
+	if f := s.MarketLotSizeFilter(); f != nil {
+		if maxQuantity, _ := strconv.ParseFloat(f.MaxQuantity, 64); maxQuantity > 0 && (info.MaxQuantity == 0 || maxQuantity < info.MaxQuantity) {
+			info.MaxQuantity = maxQuantity
+		}
+	}
+
+	if f := s.PriceFilter(); f != nil {
+		info.TickSize, _ = strconv.ParseFloat(f.TickSize, 64)
+	}
+
+	if f := s.NotionalFilter(); f != nil {
+		info.MinNotional, _ = strconv.ParseFloat(f.MinNotional, 64)
+	} else {
+		// Older symbols may still use the deprecated MIN_NOTIONAL filter, which isn't supported by the client library.
+		for _, filter := range s.Filters {
+			if filter["filterType"] == "MIN_NOTIONAL" {
+				if minNotional, ok := filter["minNotional"].(string); ok {
+					info.MinNotional, _ = strconv.ParseFloat(minNotional, 64)
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+func (b *Binance) GetBalances(ctx context.Context) (Balances, error) {
+	account, err := b.client.NewGetAccountService().OmitZeroBalances(true).Do(ctx)
+	if err != nil {
+		return nil, binanceError(err)
+	}
+
+	balances := make(Balances, len(account.Balances))
+	for _, balance := range account.Balances {
+		free, _ := strconv.ParseFloat(balance.Free, 64)
+		locked, _ := strconv.ParseFloat(balance.Locked, 64)
+		balances[balance.Asset] = Balance{
+			Asset:  balance.Asset,
+			Free:   free,
+			Locked: locked,
+		}
+	}
+
+	return balances, nil
+}
+
 func (b *Binance) executeOrder(ctx context.Context, coin string, quantity float64, side binance.SideType) (Order, error) {
-	quantityAsString := strconv.FormatFloat(quantity, 'f', -1, 64)
+	if b.config.TradingOptions.OrderOptions.Type == config.LimitOrderType {
+		return b.executeLimitOrder(ctx, coin, quantity, side)
+	}
+
+	return b.executeMarketOrder(ctx, coin, quantity, side)
+}
 
+func (b *Binance) executeMarketOrder(ctx context.Context, coin string, quantity float64, side binance.SideType) (Order, error) {
 	marketOrder, err := b.client.NewCreateOrderService().
 		Symbol(coin).
 		Side(side).
 		Type(binance.OrderTypeMarket).
-		Quantity(quantityAsString).
+		Quantity(formatBinanceFloat(quantity)).
+		NewOrderRespType(binance.NewOrderRespTypeFULL).
 		Do(ctx)
 
 	if err != nil {
-		return Order{}, err
+		return Order{}, binanceError(err)
 	}
 
 	order := Order{
 		OrderID:         marketOrder.OrderID,
 		Symbol:          marketOrder.Symbol,
-		TransactionTime: time.Unix(marketOrder.TransactTime, 0),
+		TransactionTime: time.UnixMilli(marketOrder.TransactTime),
+		Status:          OrderStatus(marketOrder.Status),
 	}
+	order.FilledQuantity, _ = strconv.ParseFloat(marketOrder.ExecutedQuantity, 64)
+	order.CumulativeQuoteQuantity, _ = strconv.ParseFloat(marketOrder.CummulativeQuoteQuantity, 64)
 
 	// Market orders are not always filled at one singular price.
 	// If that's the case, we need to find the averages of all 'parts' (fills) of this order in order to calculate the total price (see code below).
@@ -112,15 +265,17 @@ func (b *Binance) executeOrder(ctx context.Context, coin string, quantity float6
 		return order, nil
 	}
 
-	// Calculate the average price of all fills.
+	// Calculate the average price and total commission of all fills.
 	var totalPrice float64
 	var totalQuantity float64
 
 	for _, fill := range marketOrder.Fills {
 		qty, _ := strconv.ParseFloat(fill.Quantity, 64)
 		price, _ := strconv.ParseFloat(fill.Price, 64)
+		commission, _ := strconv.ParseFloat(fill.Commission, 64)
 		totalQuantity += qty
 		totalPrice += price * qty
+		order.Commissions.Add(fill.CommissionAsset, commission)
 	}
 
 	fillAvg := totalPrice / totalQuantity
@@ -130,6 +285,177 @@ func (b *Binance) executeOrder(ctx context.Context, coin string, quantity float6
 	return order, nil
 }
 
+// executeLimitOrder places a limit order at the best price on the order book plus the configured offset and waits for it to fill.
+// If the order isn't filled completely within the configured timeout, it's cancelled and the unfilled quantity is traded with a market order instead.
+// Returns the combined result of both orders, which is only partially filled if the remaining quantity is too small to trade.
+func (b *Binance) executeLimitOrder(ctx context.Context, coin string, quantity float64, side binance.SideType) (Order, error) {
+	options := b.config.TradingOptions.OrderOptions
+
+	info, err := b.GetSymbolInfo(ctx, coin)
+	if err != nil {
+		return Order{}, err
+	}
+
+	price, err := b.limitPrice(ctx, info, side, options.LimitOffset)
+	if err != nil {
+		return Order{}, err
+	}
+
+	limitOrder, err := b.client.NewCreateOrderService().
+		Symbol(coin).
+		Side(side).
+		Type(binance.OrderTypeLimit).
+		TimeInForce(binance.TimeInForceTypeGTC).
+		Quantity(formatBinanceFloat(quantity)).
+		Price(formatBinanceFloat(price)).
+		Do(ctx)
+	if err != nil {
+		return Order{}, binanceError(err)
+	}
+
+	// Wait for the order to fill.
+	status := limitOrder.Status
+	deadline := time.Now().Add(time.Duration(options.LimitTimeout) * time.Second)
+	for (status == binance.OrderStatusTypeNew || status == binance.OrderStatusTypePartiallyFilled) && time.Now().Before(deadline) {
+		if sleep(ctx, b.orderPollInterval) != nil {
+			break
+		}
+
+		// Failures are ignored until the deadline, because the order is cancelled afterward anyway.
+		if o, err := b.client.NewGetOrderService().Symbol(coin).OrderID(limitOrder.OrderID).Do(ctx); err == nil {
+			status = o.Status
+		}
+	}
+
+	// The order must never be left open on the order book, not even when the bot is stopping.
+	if status == binance.OrderStatusTypeNew || status == binance.OrderStatusTypePartiallyFilled {
+		if _, err = b.client.NewCancelOrderService().Symbol(coin).OrderID(limitOrder.OrderID).Do(context.WithoutCancel(ctx)); err != nil {
+			return Order{}, fmt.Errorf("failed to cancel limit order %d: %w", limitOrder.OrderID, binanceError(err))
+		}
+	}
+
+	order, err := b.getFilledOrder(context.WithoutCancel(ctx), coin, limitOrder.OrderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	remaining := quantity - order.FilledQuantity
+	if info.StepSize != 0 {
+		remaining = utils.RoundStepSize(remaining, info.StepSize)
+	}
+	if remaining <= 0 {
+		order.Status = FilledOrderStatus
+		return order, nil
+	}
+	if remaining < info.MinQuantity || remaining*price < info.MinNotional {
+		if order.FilledQuantity == 0 {
+			return Order{}, fmt.Errorf("limit order %d wasn't filled and the quantity is too small for a market order", limitOrder.OrderID)
+		}
+		order.Status = PartiallyFilledOrderStatus
+		return order, nil
+	}
+
+	marketOrder, err := b.executeMarketOrder(ctx, coin, remaining, side)
+	if err != nil {
+		if order.FilledQuantity == 0 {
+			return Order{}, err
+		}
+		// Report what was filled, so that it can still be tracked.
+		order.Status = PartiallyFilledOrderStatus
+		return order, nil
+	}
+
+	if order.FilledQuantity == 0 {
+		return marketOrder, nil
+	}
+
+	return mergeOrders(order, marketOrder), nil
+}
+
+// limitPrice returns the price at which to place a limit order for the given symbol.
+// Buy orders are placed above the best bid and sell orders below the best ask by the given offset in percent, rounded to the tick size.
+func (b *Binance) limitPrice(ctx context.Context, info SymbolInfo, side binance.SideType, offset float64) (float64, error) {
+	tickers, err := b.client.NewListBookTickersService().Symbol(info.Symbol).Do(ctx)
+	if err != nil {
+		return 0, binanceError(err)
+	}
+	if len(tickers) == 0 {
+		return 0, SymbolNotFoundError
+	}
+
+	var price float64
+	if side == binance.SideTypeBuy {
+		bid, _ := strconv.ParseFloat(tickers[0].BidPrice, 64)
+		price = bid * (1 + offset/100)
+	} else {
+		ask, _ := strconv.ParseFloat(tickers[0].AskPrice, 64)
+		price = ask * (1 - offset/100)
+	}
+
+	if info.TickSize != 0 {
+		price = utils.RoundStepSize(price, info.TickSize)
+	}
+
+	return price, nil
+}
+
+// getFilledOrder returns the filled quantity, average price and commission of the given order, based on its trades.
+func (b *Binance) getFilledOrder(ctx context.Context, coin string, orderID int64) (Order, error) {
+	trades, err := b.client.NewListTradesService().Symbol(coin).OrderId(orderID).Do(ctx)
+	if err != nil {
+		return Order{}, binanceError(err)
+	}
+
+	order := Order{
+		OrderID: orderID,
+		Symbol:  coin,
+		Status:  CanceledOrderStatus,
+	}
+
+	var totalPrice float64
+	for _, trade := range trades {
+		qty, _ := strconv.ParseFloat(trade.Quantity, 64)
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		commission, _ := strconv.ParseFloat(trade.Commission, 64)
+		order.FilledQuantity += qty
+		totalPrice += price * qty
+		order.Commissions.Add(trade.CommissionAsset, commission)
+		order.TransactionTime = time.UnixMilli(trade.Time)
+	}
+
+	order.CumulativeQuoteQuantity = totalPrice
+
+	if order.FilledQuantity > 0 {
+		order.Price = totalPrice / order.FilledQuantity
+		order.Status = PartiallyFilledOrderStatus
+	}
+
+	return order, nil
+}
+
+// mergeOrders combines the fills of a partially filled limit order with those of the market order that traded the remaining quantity.
+func mergeOrders(limitOrder, marketOrder Order) Order {
+	order := limitOrder
+	order.FilledQuantity = limitOrder.FilledQuantity + marketOrder.FilledQuantity
+	order.Price = (limitOrder.Price*limitOrder.FilledQuantity + marketOrder.Price*marketOrder.FilledQuantity) / order.FilledQuantity
+	order.Status = marketOrder.Status
+	order.TransactionTime = marketOrder.TransactionTime
+	order.CumulativeQuoteQuantity = limitOrder.CumulativeQuoteQuantity + marketOrder.CumulativeQuoteQuantity
+	order.Commissions = make(Commissions, len(limitOrder.Commissions)+len(marketOrder.Commissions))
+	for asset, amount := range limitOrder.Commissions {
+		order.Commissions.Add(asset, amount)
+	}
+	for asset, amount := range marketOrder.Commissions {
+		order.Commissions.Add(asset, amount)
+	}
+	return order
+}
+
+// formatBinanceFloat formats the given quantity or price without an exponent, as required by Binance.
+func formatBinanceFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
 func (b *Binance) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
 	return b.executeOrder(ctx, coin, quantity, binance.SideTypeBuy)
 }
@@ -137,3 +463,91 @@ func (b *Binance) Buy(ctx context.Context, coin string, quantity float64) (Order
 func (b *Binance) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
 	return b.executeOrder(ctx, coin, quantity, binance.SideTypeSell)
 }
+
+func (b *Binance) PlaceOCO(ctx context.Context, coin string, quantity, takeProfit, stopLoss float64) (OCO, error) {
+	info, err := b.GetSymbolInfo(ctx, coin)
+	if err != nil {
+		return OCO{}, err
+	}
+
+	stopLimit := stopLoss * (1 - binanceStopLimitOffset/100)
+	if info.TickSize != 0 {
+		takeProfit = utils.RoundStepSize(takeProfit, info.TickSize)
+		stopLoss = utils.RoundStepSize(stopLoss, info.TickSize)
+		stopLimit = utils.RoundStepSize(stopLimit, info.TickSize)
+	}
+
+	res, err := b.client.NewCreateOCOService().
+		Symbol(coin).
+		Side(binance.SideTypeSell).
+		Quantity(formatBinanceFloat(quantity)).
+		Price(formatBinanceFloat(takeProfit)).
+		StopPrice(formatBinanceFloat(stopLoss)).
+		StopLimitPrice(formatBinanceFloat(stopLimit)).
+		StopLimitTimeInForce(binance.TimeInForceTypeGTC).
+		Do(ctx)
+	if err != nil {
+		return OCO{}, binanceError(err)
+	}
+
+	oco := OCO{OrderListID: res.OrderListID, Symbol: coin}
+	for _, report := range res.OrderReports {
+		if report.Type == binance.OrderTypeLimitMaker {
+			oco.TakeProfitOrderID = report.OrderID
+		} else {
+			oco.StopLossOrderID = report.OrderID
+		}
+	}
+
+	return oco, nil
+}
+
+func (b *Binance) CancelOCO(ctx context.Context, oco OCO) error {
+	if _, err := b.client.NewCancelOCOService().Symbol(oco.Symbol).OrderListID(oco.OrderListID).Do(ctx); err != nil {
+		return binanceError(err)
+	}
+	return nil
+}
+
+func (b *Binance) GetOCOFill(ctx context.Context, oco OCO) (Order, bool, error) {
+	canceled := 0
+	for _, orderID := range []int64{oco.TakeProfitOrderID, oco.StopLossOrderID} {
+		o, err := b.client.NewGetOrderService().Symbol(oco.Symbol).OrderID(orderID).Do(ctx)
+		if err != nil {
+			return Order{}, false, binanceError(err)
+		}
+
+		switch o.Status {
+		case binance.OrderStatusTypeFilled:
+			order, err := b.getFilledOrder(ctx, oco.Symbol, orderID)
+			if err != nil {
+				return Order{}, false, err
+			}
+			order.Status = FilledOrderStatus
+			return order, true, nil
+		case binance.OrderStatusTypeCanceled, binance.OrderStatusTypeExpired, binance.OrderStatusTypeRejected:
+			canceled++
+		}
+	}
+
+	// The market expires the other order when one is filled, so both orders are only done without a fill if the OCO has been cancelled.
+	if canceled == 2 {
+		return Order{}, false, OCOCanceledError
+	}
+
+	return Order{}, false, nil
+}
+
+// binanceError marks the given error as transient if Binance reports it as such, or as SymbolNotFoundError if the symbol doesn't exist.
+func binanceError(err error) error {
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == binanceSymbolNotFoundCode {
+			return fmt.Errorf("%w: %s", SymbolNotFoundError, err)
+		}
+		if rejected, ok := binanceTransientErrorCodes[apiErr.Code]; ok {
+			return &TransientError{Err: err, Rejected: rejected}
+		}
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/adshao/go-binance/v2"
+	"github.com/gorilla/websocket"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// binanceStreamEndpoint is the websocket endpoint of Binance's all-market mini-ticker stream.
+const binanceStreamEndpoint = "wss://stream.binance.com:9443/ws/!miniTicker@arr"
+
+// binanceStreamMaxAge is the maximum age of the streamed prices before they are considered stale.
+// Binance pushes the mini-ticker stream every second, so not receiving anything for this long means the connection is dead.
+const binanceStreamMaxAge = 10 * time.Second
+
+// Reconnect delays of the stream, doubled after every failed attempt.
+const (
+	binanceStreamMinBackoff = 1 * time.Second
+	binanceStreamMaxBackoff = 1 * time.Minute
+)
+
+// binanceStream keeps an in-memory snapshot of the prices of all coins up to date using Binance's all-market mini-ticker stream.
+type binanceStream struct {
+	endpoint string
+
+	mu        sync.RWMutex
+	coins     Coins
+	updatedAt time.Time
+}
+
+func newBinanceStream(endpoint string) *binanceStream {
+	return &binanceStream{endpoint: endpoint, coins: make(Coins)}
+}
+
+// snapshot returns a copy of the streamed prices.
+// Returns false if the stream is disconnected or hasn't received an update recently.
+func (s *binanceStream) snapshot(now time.Time) (Coins, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.updatedAt.IsZero() || now.Sub(s.updatedAt) > binanceStreamMaxAge {
+		return nil, false
+	}
+
+	return maps.Clone(s.coins), true
+}
+
+// run keeps the snapshot up to date until the given context is cancelled, reconnecting with exponential backoff whenever the connection drops.
+// Every (re)connect first replaces the snapshot with the result of the given seed function, because the stream only pushes the coins whose price changed.
+func (s *binanceStream) run(ctx context.Context, seed func(ctx context.Context) (Coins, error), onError func(error)) {
+	backoff := binanceStreamMinBackoff
+
+	for {
+		seeded, err := s.connect(ctx, seed, onError)
+		s.invalidate()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if seeded {
+			backoff = binanceStreamMinBackoff
+		}
+		if err != nil {
+			onError(err)
+		}
+
+		if sleep(ctx, backoff) != nil {
+			return
+		}
+
+		backoff = min(backoff*2, binanceStreamMaxBackoff)
+	}
+}
+
+// connect reads from a single connection to the stream until it fails or the given context is cancelled.
+// Returns true if the snapshot was seeded, i.e. the connection was usable.
+func (s *binanceStream) connect(ctx context.Context, seed func(ctx context.Context) (Coins, error), onError func(error)) (bool, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Unblock the read below as soon as the context is cancelled.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	coins, err := seed(ctx)
+	if err != nil {
+		return false, err
+	}
+	s.replace(coins, time.Now())
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(binanceStreamMaxAge))
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+
+		var event binance.WsAllMiniMarketsStatEvent
+		if err = json.Unmarshal(message, &event); err != nil {
+			onError(err)
+			continue
+		}
+
+		coins := make(Coins, len(event))
+		for _, ticker := range event {
+			price, err := strconv.ParseFloat(ticker.LastPrice, 64)
+			if err != nil {
+				continue
+			}
+			coins[ticker.Symbol] = Coin{
+				Symbol: ticker.Symbol,
+				Price:  price,
+				Time:   time.UnixMilli(ticker.Time),
+			}
+		}
+		s.merge(coins, time.Now())
+	}
+}
+
+// replace replaces the entire snapshot with the given coins.
+func (s *binanceStream) replace(coins Coins, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.coins = maps.Clone(coins)
+	s.updatedAt = now
+}
+
+// merge adds the given coins to the snapshot, overwriting the previous prices.
+func (s *binanceStream) merge(coins Coins, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps.Copy(s.coins, coins)
+	s.updatedAt = now
+}
+
+// invalidate marks the snapshot as stale, so that GetCoins immediately falls back to the REST API.
+func (s *binanceStream) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updatedAt = time.Time{}
+}
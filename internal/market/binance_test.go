@@ -0,0 +1,441 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/gorilla/websocket"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// binanceStandIn is a local stand-in for the Binance REST API and mini-ticker stream.
+type binanceStandIn struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	restPrice string
+
+	// Messages to send per stream connection, after which the connection is closed.
+	// The last connection is kept open until the test ends.
+	messages [][]string
+
+	connections atomic.Int32
+}
+
+func newBinanceStandIn(t *testing.T, restPrice string, messages ...[]string) *binanceStandIn {
+	s := &binanceStandIn{restPrice: restPrice, messages: messages}
+	done := make(chan struct{})
+
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/ticker/price", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `[{"symbol":"BTCUSDT","price":"%s"},{"symbol":"ETHUSDT","price":"10"}]`, s.restPrice)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		n := int(s.connections.Add(1))
+		if n > len(s.messages) {
+			return
+		}
+		for _, message := range s.messages[n-1] {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+		if n == len(s.messages) {
+			<-done
+		}
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(func() {
+		close(done)
+		s.Server.Close()
+	})
+
+	return s
+}
+
+func (s *binanceStandIn) setRestPrice(price string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restPrice = price
+}
+
+func (s *binanceStandIn) newBinance() *Binance {
+	b := NewBinance(config.Configuration{})
+	b.client.BaseURL = s.URL
+	b.stream.endpoint = "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+	return b
+}
+
+func miniTicker(symbol, price string) string {
+	return fmt.Sprintf(`[{"e":"24hrMiniTicker","E":%d,"s":"%s","c":"%s","o":"1","h":"1","l":"1","v":"1","q":"1"}]`, time.Now().UnixMilli(), symbol, price)
+}
+
+func getPrice(t *testing.T, b *Binance, symbol string) float64 {
+	coins, err := b.GetCoins(context.Background())
+	assert.NoError(t, err)
+	return coins[symbol].Price
+}
+
+func TestBinance_GetCoins_stream(t *testing.T) {
+	s := newBinanceStandIn(t, "100", []string{miniTicker("BTCUSDT", "101")})
+	b := s.newBinance()
+
+	// Without a stream, the REST API is used.
+	assert.Equal(t, 100.0, getPrice(t, b, "BTCUSDT"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Stream(ctx, func(err error) {})
+
+	// Prices from the stream are merged into the prices from the REST API.
+	s.setRestPrice("50")
+	assert.Eventually(t, func() bool {
+		return getPrice(t, b, "BTCUSDT") == 101
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 10.0, getPrice(t, b, "ETHUSDT"))
+
+	// Falls back to the REST API once the stream is stopped.
+	cancel()
+	assert.Eventually(t, func() bool {
+		return getPrice(t, b, "BTCUSDT") == 50
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestBinance_GetCoins_stream_reconnect(t *testing.T) {
+	s := newBinanceStandIn(t, "100", []string{miniTicker("BTCUSDT", "101")}, []string{miniTicker("BTCUSDT", "102")})
+	b := s.newBinance()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failures atomic.Int32
+	go b.Stream(ctx, func(err error) {
+		failures.Add(1)
+	})
+
+	assert.Eventually(t, func() bool {
+		return getPrice(t, b, "BTCUSDT") == 102
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), s.connections.Load())
+	assert.Equal(t, int32(1), failures.Load())
+}
+
+func TestBinance_errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/ticker/price":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/api/v3/ticker/24hr":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"code":-1003,"msg":"Too many requests."}`))
+		case "/api/v3/order":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":-2010,"msg":"Account has insufficient balance for requested action."}`))
+		}
+	}))
+	defer server.Close()
+
+	b := NewBinance(config.Configuration{TradingOptions: config.TradingOptions{MinQuoteVolumeTraded: 1}})
+	b.client.BaseURL = server.URL
+
+	var transientErr *TransientError
+
+	_, err := b.GetCoins(context.Background())
+	assert.ErrorAs(t, err, &transientErr)
+	assert.False(t, transientErr.Rejected)
+
+	_, err = b.Buy(context.Background(), "BTCUSDT", 1)
+	var apiErr *common.APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.False(t, errors.As(err, &transientErr))
+
+	// Requested last, because the rate limiter backs off after this.
+	_, err = b.GetCoinsVolume(context.Background())
+	assert.ErrorAs(t, err, &transientErr)
+	assert.True(t, transientErr.Rejected)
+}
+
+func TestBinance_GetSymbolInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"symbols":[{
+			"symbol":"BTCUSDT","status":"TRADING","isSpotTradingAllowed":true,"baseAsset":"BTC","baseAssetPrecision":8,"quoteAsset":"USDT","quoteAssetPrecision":8,
+			"filters":[
+				{"filterType":"PRICE_FILTER","minPrice":"0.01","maxPrice":"1000000.00","tickSize":"0.01"},
+				{"filterType":"LOT_SIZE","minQty":"0.00001","maxQty":"9000.00000","stepSize":"0.00001"},
+				{"filterType":"MARKET_LOT_SIZE","minQty":"0.00000","maxQty":"120.5","stepSize":"0.00000"},
+				{"filterType":"NOTIONAL","minNotional":"5.00","applyMinToMarket":true,"maxNotional":"9000000.00","applyMaxToMarket":false,"avgPriceMins":5}
+			]
+		},{
+			"symbol":"LUNAUSDT","status":"BREAK","isSpotTradingAllowed":true,"baseAsset":"LUNA","baseAssetPrecision":8,"quoteAsset":"USDT","quoteAssetPrecision":8,
+			"filters":[]
+		}]}`))
+	}))
+	defer server.Close()
+
+	b := NewBinance(config.Configuration{})
+	b.client.BaseURL = server.URL
+
+	info, err := b.GetSymbolInfo(context.Background(), "BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, SymbolInfo{
+		Symbol:              "BTCUSDT",
+		StepSize:            0.00001,
+		MinQuantity:         0.00001,
+		MaxQuantity:         120.5,
+		TickSize:            0.01,
+		MinNotional:         5,
+		BaseAssetPrecision:  8,
+		QuoteAssetPrecision: 8,
+	}, info)
+
+	_, err = b.GetSymbolInfo(context.Background(), "ETHUSDT")
+	assert.ErrorIs(t, err, SymbolNotFoundError)
+
+	symbols, err := b.GetSymbolsInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(symbols))
+	assert.Equal(t, info, symbols[0])
+	assert.Equal(t, "LUNAUSDT", symbols[1].Symbol)
+	assert.True(t, symbols[1].Halted)
+}
+
+func TestBinance_GetBalances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/account", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("omitZeroBalances"))
+		_, _ = w.Write([]byte(`{"balances":[{"asset":"BTC","free":"0.5","locked":"0.25"},{"asset":"USDT","free":"100.00","locked":"0.00"}]}`))
+	}))
+	defer server.Close()
+
+	b := NewBinance(config.Configuration{})
+	b.client.BaseURL = server.URL
+
+	balances, err := b.GetBalances(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Balances{
+		"BTC":  {Asset: "BTC", Free: 0.5, Locked: 0.25},
+		"USDT": {Asset: "USDT", Free: 100},
+	}, balances)
+	assert.Equal(t, 0.75, balances["BTC"].Total())
+}
+
+// binanceOrderStandIn is a local stand-in for the order endpoints of the Binance REST API.
+// The limit order gets the given statuses when it's queried, in order.
+type binanceOrderStandIn struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []string
+	orders   []url.Values
+	canceled bool
+}
+
+func newBinanceOrderStandIn(t *testing.T, trades string, statuses ...string) *binanceOrderStandIn {
+	s := &binanceOrderStandIn{statuses: statuses}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/exchangeInfo", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"symbols":[{
+			"symbol":"BTCUSDT","status":"TRADING","isSpotTradingAllowed":true,"baseAsset":"BTC","quoteAsset":"USDT",
+			"filters":[
+				{"filterType":"PRICE_FILTER","tickSize":"0.01"},
+				{"filterType":"LOT_SIZE","minQty":"0.01","maxQty":"100","stepSize":"0.001"}
+			]
+		}]}`))
+	})
+	mux.HandleFunc("/api/v3/ticker/bookTicker", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","bidPrice":"100.00","bidQty":"1","askPrice":"101.00","askQty":"1"}`))
+	})
+	mux.HandleFunc("/api/v3/order", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPost:
+			_ = r.ParseForm()
+			s.orders = append(s.orders, r.Form)
+			if r.Form.Get("type") == "MARKET" {
+				_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","orderId":2,"transactTime":1704067200000,"executedQty":"0.6","status":"FILLED",
+					"fills":[{"price":"100.5","qty":"0.6","commission":"0.06","commissionAsset":"USDT"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","orderId":1,"status":"NEW"}`))
+		case http.MethodGet:
+			status := s.statuses[0]
+			if len(s.statuses) > 1 {
+				s.statuses = s.statuses[1:]
+			}
+			_, _ = fmt.Fprintf(w, `{"symbol":"BTCUSDT","orderId":1,"status":"%s"}`, status)
+		case http.MethodDelete:
+			s.canceled = true
+			_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","orderId":1,"status":"CANCELED"}`))
+		}
+	})
+	mux.HandleFunc("/api/v3/myTrades", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("orderId"))
+		_, _ = w.Write([]byte(trades))
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Server.Close)
+
+	return s
+}
+
+func (s *binanceOrderStandIn) newBinance(limitTimeout int) *Binance {
+	b := NewBinance(config.Configuration{
+		TradingOptions: config.TradingOptions{
+			OrderOptions: config.OrderOptions{
+				Type:         config.LimitOrderType,
+				LimitOffset:  0.01,
+				LimitTimeout: limitTimeout,
+			},
+		},
+	})
+	b.client.BaseURL = s.URL
+	b.orderPollInterval = 10 * time.Millisecond
+	return b
+}
+
+func TestBinance_Buy_limit(t *testing.T) {
+	s := newBinanceOrderStandIn(t, `[{"orderId":1,"price":"100.01","qty":"1","commission":"0.001","commissionAsset":"BTC","time":1704067200000}]`, "NEW", "FILLED")
+	b := s.newBinance(5)
+
+	order, err := b.Buy(context.Background(), "BTCUSDT", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Order{
+		OrderID:                 1,
+		Symbol:                  "BTCUSDT",
+		TransactionTime:         time.UnixMilli(1704067200000),
+		Price:                   100.01,
+		Status:                  FilledOrderStatus,
+		FilledQuantity:          1,
+		CumulativeQuoteQuantity: 100.01,
+		Commissions:             Commissions{"BTC": 0.001},
+	}, order)
+
+	// The limit is placed just above the best bid.
+	assert.Equal(t, 1, len(s.orders))
+	assert.Equal(t, "LIMIT", s.orders[0].Get("type"))
+	assert.Equal(t, "GTC", s.orders[0].Get("timeInForce"))
+	assert.Equal(t, "100.01", s.orders[0].Get("price"))
+	assert.False(t, s.canceled)
+}
+
+func TestBinance_Sell_limit_timeout(t *testing.T) {
+	s := newBinanceOrderStandIn(t, `[{"orderId":1,"price":"100.99","qty":"0.4","commission":"0.04","commissionAsset":"USDT","time":1704067100000}]`, "PARTIALLY_FILLED")
+	b := s.newBinance(0)
+
+	order, err := b.Sell(context.Background(), "BTCUSDT", 1)
+	assert.NoError(t, err)
+
+	// The limit order is cancelled and the remaining quantity is sold with a market order.
+	assert.True(t, s.canceled)
+	assert.Equal(t, 2, len(s.orders))
+	assert.Equal(t, "100.99", s.orders[0].Get("price"))
+	assert.Equal(t, "MARKET", s.orders[1].Get("type"))
+	assert.Equal(t, "0.6", s.orders[1].Get("quantity"))
+
+	assert.Equal(t, int64(1), order.OrderID)
+	assert.Equal(t, FilledOrderStatus, order.Status)
+	assert.InDelta(t, 1, order.FilledQuantity, 1e-9)
+	assert.InDelta(t, 0.4*100.99+0.6*100.5, order.Price, 1e-9)
+	assert.InDelta(t, 0.1, order.Commissions["USDT"], 1e-9)
+}
+
+func TestBinance_Buy_limit_partially_filled(t *testing.T) {
+	s := newBinanceOrderStandIn(t, `[{"orderId":1,"price":"100.01","qty":"0.995","commission":"0","commissionAsset":"BTC","time":1704067200000}]`, "PARTIALLY_FILLED")
+	b := s.newBinance(0)
+
+	// The remaining quantity is below the minimum quantity, so it can't be bought with a market order.
+	order, err := b.Buy(context.Background(), "BTCUSDT", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(s.orders))
+	assert.Equal(t, PartiallyFilledOrderStatus, order.Status)
+	assert.Equal(t, 0.995, order.FilledQuantity)
+}
+
+func TestBinance_OCO(t *testing.T) {
+	var form url.Values
+	statuses := map[string]string{"11": "NEW", "12": "NEW"}
+	var mu sync.Mutex
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/exchangeInfo", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"symbols":[{"symbol":"BTCUSDT","status":"TRADING","isSpotTradingAllowed":true,"filters":[{"filterType":"PRICE_FILTER","tickSize":"0.01"}]}]}`))
+	})
+	mux.HandleFunc("/api/v3/order/oco", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.Form
+		_, _ = w.Write([]byte(`{"orderListId":10,"symbol":"BTCUSDT","orderReports":[
+			{"symbol":"BTCUSDT","orderId":12,"orderListId":10,"type":"STOP_LOSS_LIMIT"},
+			{"symbol":"BTCUSDT","orderId":11,"orderListId":10,"type":"LIMIT_MAKER"}
+		]}`))
+	})
+	mux.HandleFunc("/api/v3/order", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := r.URL.Query().Get("orderId")
+		_, _ = fmt.Fprintf(w, `{"symbol":"BTCUSDT","orderId":%s,"status":"%s"}`, id, statuses[id])
+	})
+	mux.HandleFunc("/api/v3/myTrades", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "12", r.URL.Query().Get("orderId"))
+		_, _ = w.Write([]byte(`[{"orderId":12,"price":"94.5","qty":"1","commission":"0.0945","commissionAsset":"USDT","time":1704067200000}]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	b := NewBinance(config.Configuration{})
+	b.client.BaseURL = server.URL
+	ctx := context.Background()
+
+	oco, err := b.PlaceOCO(ctx, "BTCUSDT", 1, 110.004, 95)
+	assert.NoError(t, err)
+	assert.Equal(t, OCO{OrderListID: 10, Symbol: "BTCUSDT", TakeProfitOrderID: 11, StopLossOrderID: 12}, oco)
+	assert.Equal(t, "SELL", form.Get("side"))
+	assert.Equal(t, "110", form.Get("price"))
+	assert.Equal(t, "95", form.Get("stopPrice"))
+	assert.Equal(t, "94.53", form.Get("stopLimitPrice"))
+
+	_, filled, err := b.GetOCOFill(ctx, oco)
+	assert.NoError(t, err)
+	assert.False(t, filled)
+
+	// The market expires the take profit order once the stop loss order is filled.
+	mu.Lock()
+	statuses = map[string]string{"11": "EXPIRED", "12": "FILLED"}
+	mu.Unlock()
+
+	order, filled, err := b.GetOCOFill(ctx, oco)
+	assert.NoError(t, err)
+	assert.True(t, filled)
+	assert.Equal(t, int64(12), order.OrderID)
+	assert.Equal(t, FilledOrderStatus, order.Status)
+	assert.Equal(t, 94.5, order.Price)
+	assert.Equal(t, Commissions{"USDT": 0.0945}, order.Commissions)
+
+	mu.Lock()
+	statuses = map[string]string{"11": "CANCELED", "12": "CANCELED"}
+	mu.Unlock()
+
+	_, _, err = b.GetOCOFill(ctx, oco)
+	assert.ErrorIs(t, err, OCOCanceledError)
+}
@@ -0,0 +1,456 @@
+package market
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Ensures Bybit implements the Market interface.
+var _ Market = (*Bybit)(nil)
+
+const bybitBaseURL = "https://api.bybit.com"
+
+// bybitRequestLimit is the maximum number of requests to send to Bybit per minute.
+// Bybit allows 600 requests per 5 seconds per IP, but limits most private endpoints to 10 or 20 requests per second.
+const bybitRequestLimit = 600
+
+// bybitRecvWindow is how long a signed request is valid after its timestamp, in milliseconds.
+const bybitRecvWindow = "5000"
+
+// bybitOrderPollInterval is the interval at which the status of a placed order is checked until it's done.
+const bybitOrderPollInterval = 500 * time.Millisecond
+
+// bybitOrderTimeout is how long to wait for a market order to be done, after which it's returned as is.
+const bybitOrderTimeout = 30 * time.Second
+
+// bybitSymbolNotFoundCode is the code of Bybit errors for orders of symbols that don't exist.
+const bybitSymbolNotFoundCode = 170121
+
+// bybitTransientErrorCodes maps the codes of Bybit errors that are expected to resolve themselves to whether the request was rejected.
+// See https://bybit-exchange.github.io/docs/v5/error.
+var bybitTransientErrorCodes = map[int]bool{
+	10000: false, // server timeout
+	10002: true,  // request time exceeds the receive window
+	10006: true,  // too many visits
+	10016: false, // server error
+	10018: true,  // exceeded the IP rate limit
+}
+
+// Bybit is a market for the Bybit spot exchange, using the v5 API.
+// Bybit uses the same symbols as all other markets (e.g. BTCUSDT), so they don't need to be converted.
+type Bybit struct {
+	config            config.Configuration
+	client            *http.Client
+	baseURL           string
+	orderPollInterval time.Duration
+}
+
+// bybitInstrument is a spot instrument as returned by Bybit.
+type bybitInstrument struct {
+	Symbol        string `json:"symbol"`
+	BaseCoin      string `json:"baseCoin"`
+	QuoteCoin     string `json:"quoteCoin"`
+	Status        string `json:"status"`
+	LotSizeFilter struct {
+		BasePrecision  string `json:"basePrecision"`
+		QuotePrecision string `json:"quotePrecision"`
+		MinOrderQty    string `json:"minOrderQty"`
+		MaxOrderQty    string `json:"maxOrderQty"`
+		MinOrderAmt    string `json:"minOrderAmt"`
+	} `json:"lotSizeFilter"`
+	PriceFilter struct {
+		TickSize string `json:"tickSize"`
+	} `json:"priceFilter"`
+}
+
+// bybitOrder is an order as returned by Bybit.
+type bybitOrder struct {
+	OrderID     string `json:"orderId"`
+	OrderStatus string `json:"orderStatus"`
+	AvgPrice    string `json:"avgPrice"`
+
+	// The filled quantity, its value in the quote currency excluding fees and the fees.
+	CumExecQty   string `json:"cumExecQty"`
+	CumExecValue string `json:"cumExecValue"`
+	CumExecFee   string `json:"cumExecFee"`
+
+	// The time the order was last updated, in milliseconds since the unix epoch.
+	UpdatedTime string `json:"updatedTime"`
+}
+
+func NewBybit(config config.Configuration) *Bybit {
+	return &Bybit{
+		config: config,
+		client: &http.Client{
+			Transport: serverErrorTransport{
+				transport: newRateLimiter(http.DefaultTransport, clock.Real{}, bybitRequestLimit, nil, ""),
+			},
+		},
+		baseURL:           bybitBaseURL,
+		orderPollInterval: bybitOrderPollInterval,
+	}
+}
+
+func (b *Bybit) Name() string {
+	return "bybit"
+}
+
+func (b *Bybit) GetCoins(ctx context.Context) (Coins, error) {
+	var res struct {
+		List []struct {
+			Symbol      string `json:"symbol"`
+			LastPrice   string `json:"lastPrice"`
+			Turnover24h string `json:"turnover24h"`
+		} `json:"list"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/v5/market/tickers", url.Values{"category": {"spot"}}, nil, &res); err != nil {
+		return nil, err
+	}
+
+	coins := make(Coins, len(res.List))
+	now := time.Now()
+
+	for _, ticker := range res.List {
+		price, _ := strconv.ParseFloat(ticker.LastPrice, 64)
+		volume, _ := strconv.ParseFloat(ticker.Turnover24h, 64)
+		coins[ticker.Symbol] = Coin{
+			Symbol:            ticker.Symbol,
+			Price:             price,
+			QuoteVolumeTraded: volume,
+			Time:              now,
+		}
+	}
+
+	return coins, nil
+}
+
+func (b *Bybit) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
+	volumeMap := make(TradeVolumes)
+	if b.config.TradingOptions.MinQuoteVolumeTraded != 0.0 {
+		coins, err := b.GetCoins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for symbol, coin := range coins {
+			volumeMap[symbol] = coin.QuoteVolumeTraded
+		}
+	}
+	return volumeMap, nil
+}
+
+func (b *Bybit) GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error) {
+	instrument, err := b.getInstrument(ctx, symbol)
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+	return instrument.toSymbolInfo(), nil
+}
+
+func (b *Bybit) GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error) {
+	instruments, err := b.getInstruments(ctx, url.Values{"category": {"spot"}})
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]SymbolInfo, 0, len(instruments))
+	for _, instrument := range instruments {
+		symbols = append(symbols, instrument.toSymbolInfo())
+	}
+
+	return symbols, nil
+}
+
+// getInstruments returns the spot instruments that match the given query parameters.
+func (b *Bybit) getInstruments(ctx context.Context, params url.Values) ([]bybitInstrument, error) {
+	var res struct {
+		List []bybitInstrument `json:"list"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/v5/market/instruments-info", params, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.List, nil
+}
+
+// getInstrument returns the spot instrument of the given symbol, or SymbolNotFoundError if it doesn't exist.
+// Bybit returns an empty list rather than an error for unknown symbols.
+func (b *Bybit) getInstrument(ctx context.Context, symbol string) (bybitInstrument, error) {
+	instruments, err := b.getInstruments(ctx, url.Values{"category": {"spot"}, "symbol": {symbol}})
+	if err != nil {
+		return bybitInstrument{}, err
+	}
+
+	for _, instrument := range instruments {
+		if instrument.Symbol == symbol {
+			return instrument, nil
+		}
+	}
+
+	return bybitInstrument{}, SymbolNotFoundError
+}
+
+// toSymbolInfo converts the given Bybit instrument and its filters to a SymbolInfo.
+func (i bybitInstrument) toSymbolInfo() SymbolInfo {
+	info := SymbolInfo{
+		Symbol: i.Symbol,
+		Halted: i.Status != "Trading",
+	}
+	info.StepSize, _ = strconv.ParseFloat(i.LotSizeFilter.BasePrecision, 64)
+	info.MinQuantity, _ = strconv.ParseFloat(i.LotSizeFilter.MinOrderQty, 64)
+	info.MaxQuantity, _ = strconv.ParseFloat(i.LotSizeFilter.MaxOrderQty, 64)
+	info.MinNotional, _ = strconv.ParseFloat(i.LotSizeFilter.MinOrderAmt, 64)
+	info.TickSize, _ = strconv.ParseFloat(i.PriceFilter.TickSize, 64)
+
+	if info.StepSize > 0 {
+		info.BaseAssetPrecision = int(math.Round(-math.Log10(info.StepSize)))
+	}
+	if quotePrecision, _ := strconv.ParseFloat(i.LotSizeFilter.QuotePrecision, 64); quotePrecision > 0 {
+		info.QuoteAssetPrecision = int(math.Round(-math.Log10(quotePrecision)))
+	}
+
+	return info
+}
+
+// GetBalances returns the balances of the unified trading account, which is the only account Bybit trades spot from.
+func (b *Bybit) GetBalances(ctx context.Context) (Balances, error) {
+	var res struct {
+		List []struct {
+			Coin []struct {
+				Coin          string `json:"coin"`
+				WalletBalance string `json:"walletBalance"`
+				Locked        string `json:"locked"`
+			} `json:"coin"`
+		} `json:"list"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/v5/account/wallet-balance", url.Values{"accountType": {"UNIFIED"}}, nil, &res); err != nil {
+		return nil, err
+	}
+
+	balances := make(Balances)
+	for _, account := range res.List {
+		for _, coin := range account.Coin {
+			total, _ := strconv.ParseFloat(coin.WalletBalance, 64)
+			locked, _ := strconv.ParseFloat(coin.Locked, 64)
+			if total == 0 {
+				continue
+			}
+			balances[coin.Coin] = Balance{
+				Asset:  coin.Coin,
+				Free:   total - locked,
+				Locked: locked,
+			}
+		}
+	}
+
+	return balances, nil
+}
+
+func (b *Bybit) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return b.executeOrder(ctx, coin, quantity, "Buy")
+}
+
+func (b *Bybit) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return b.executeOrder(ctx, coin, quantity, "Sell")
+}
+
+// executeOrder places a market order for the given quantity of the given coin and waits for it to be done.
+// The order history is used to determine the fill, because the response to the order only contains its ID.
+func (b *Bybit) executeOrder(ctx context.Context, coin string, quantity float64, side string) (Order, error) {
+	instrument, err := b.getInstrument(ctx, coin)
+	if err != nil {
+		return Order{}, err
+	}
+
+	body := map[string]string{
+		"category":    "spot",
+		"symbol":      coin,
+		"side":        side,
+		"orderType":   "Market",
+		"qty":         strconv.FormatFloat(quantity, 'f', -1, 64),
+		"marketUnit":  "baseCoin",
+		"orderLinkId": uuid.NewString(),
+	}
+	var created struct {
+		OrderID string `json:"orderId"`
+	}
+	if err = b.do(ctx, http.MethodPost, "/v5/order/create", nil, body, &created); err != nil {
+		return Order{}, err
+	}
+
+	// Spot trading fees are paid in the asset that is received.
+	commissionAsset := instrument.QuoteCoin
+	if side == "Buy" {
+		commissionAsset = instrument.BaseCoin
+	}
+
+	deadline := time.Now().Add(bybitOrderTimeout)
+	for {
+		var res struct {
+			List []bybitOrder `json:"list"`
+		}
+		params := url.Values{"category": {"spot"}, "orderId": {created.OrderID}}
+		if err = b.do(ctx, http.MethodGet, "/v5/order/history", params, nil, &res); err != nil {
+			return Order{}, err
+		}
+
+		// The order may not show up in the history right away.
+		if len(res.List) > 0 {
+			o := res.List[0]
+			done := o.OrderStatus != "New" && o.OrderStatus != "PartiallyFilled"
+			if done || time.Now().After(deadline) {
+				return o.toOrder(coin, commissionAsset), nil
+			}
+		} else if time.Now().After(deadline) {
+			return Order{}, fmt.Errorf("bybit order %s not found", created.OrderID)
+		}
+
+		if err = sleep(ctx, b.orderPollInterval); err != nil {
+			return Order{}, err
+		}
+	}
+}
+
+// toOrder converts the given Bybit order of the given coin to an Order, recording its fee in the given asset.
+func (o bybitOrder) toOrder(coin, commissionAsset string) Order {
+	order := Order{Symbol: coin}
+	order.OrderID, _ = strconv.ParseInt(o.OrderID, 10, 64)
+	if updatedTime, err := strconv.ParseInt(o.UpdatedTime, 10, 64); err == nil {
+		order.TransactionTime = time.UnixMilli(updatedTime)
+	}
+	order.Price, _ = strconv.ParseFloat(o.AvgPrice, 64)
+	order.FilledQuantity, _ = strconv.ParseFloat(o.CumExecQty, 64)
+	order.CumulativeQuoteQuantity, _ = strconv.ParseFloat(o.CumExecValue, 64)
+	if order.Price == 0 && order.FilledQuantity > 0 {
+		order.Price = order.CumulativeQuoteQuantity / order.FilledQuantity
+	}
+	if fee, _ := strconv.ParseFloat(o.CumExecFee, 64); fee != 0 {
+		order.Commissions.Add(commissionAsset, fee)
+	}
+
+	switch {
+	case o.OrderStatus == "Filled":
+		order.Status = FilledOrderStatus
+	case order.FilledQuantity > 0:
+		order.Status = PartiallyFilledOrderStatus
+	case o.OrderStatus == "New" || o.OrderStatus == "Untriggered":
+		order.Status = NewOrderStatus
+	default:
+		order.Status = CanceledOrderStatus
+	}
+
+	return order
+}
+
+// PlaceOCO always returns NotSupportedError, because Bybit doesn't support OCO orders on its spot market through the v5 API.
+func (b *Bybit) PlaceOCO(_ context.Context, _ string, _, _, _ float64) (OCO, error) {
+	return OCO{}, NotSupportedError
+}
+
+func (b *Bybit) CancelOCO(_ context.Context, _ OCO) error {
+	return NotSupportedError
+}
+
+func (b *Bybit) GetOCOFill(_ context.Context, _ OCO) (Order, bool, error) {
+	return Order{}, false, NotSupportedError
+}
+
+// do sends a signed request with the given query parameters and JSON body to the given path and decodes the result of the response into the given value.
+// Public endpoints accept signed requests too, so all requests are signed.
+func (b *Bybit) do(ctx context.Context, method, path string, params url.Values, body any, result any) error {
+	query := params.Encode()
+	endpoint := path
+	if query != "" {
+		endpoint += "?" + query
+	}
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// GET requests are signed with their query string, POST requests with their body.
+	payload := query
+	if method != http.MethodGet {
+		payload = string(reqBody)
+	}
+	b.sign(req, payload, time.Now())
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		RetCode int             `json:"retCode"`
+		RetMsg  string          `json:"retMsg"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err = json.Unmarshal(raw, &envelope); err != nil {
+		return fmt.Errorf("%s %s: %s: %w", method, path, res.Status, err)
+	}
+	if envelope.RetCode != 0 {
+		return bybitError(envelope.RetCode, envelope.RetMsg)
+	}
+
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// sign adds the headers that authenticate the given request with the given payload to Bybit.
+// See https://bybit-exchange.github.io/docs/v5/guide#authentication.
+func (b *Bybit) sign(req *http.Request, payload string, now time.Time) {
+	c := b.config.Markets.Bybit
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+
+	req.Header.Set("X-BAPI-API-KEY", c.APIKey)
+	req.Header.Set("X-BAPI-TIMESTAMP", timestamp)
+	req.Header.Set("X-BAPI-RECV-WINDOW", bybitRecvWindow)
+	req.Header.Set("X-BAPI-SIGN", bybitSignature(c.SecretKey, timestamp+c.APIKey+bybitRecvWindow+payload))
+}
+
+// bybitSignature signs the given message with the given secret.
+func bybitSignature(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// bybitError converts the given Bybit error to an error, marking it as transient if Bybit reports it as such.
+func bybitError(code int, msg string) error {
+	err := errors.New(strconv.Itoa(code) + ": " + msg)
+	if code == bybitSymbolNotFoundCode {
+		return fmt.Errorf("%w: %s", SymbolNotFoundError, err)
+	}
+	if rejected, ok := bybitTransientErrorCodes[code]; ok {
+		return &TransientError{Err: err, Rejected: rejected}
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package market_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/market/markettest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+// newBybit returns a Bybit client that sends its requests to a server answering them with the given fixtures.
+// The behavior Bybit shares with all other markets is covered by the conformance suite, so only what's specific to Bybit is tested here.
+func newBybit(t *testing.T, fixtures markettest.Fixtures) (*market.Bybit, *markettest.Server) {
+	s := markettest.NewServer(t, fixtures)
+	b := market.NewBybit(config.Configuration{
+		Markets: config.Markets{
+			Bybit: config.Bybit{APIKey: "api key", SecretKey: "secret"},
+		},
+	})
+	market.SetBaseURL(b, s.URL)
+	return b, s
+}
+
+func TestBybit_signing(t *testing.T) {
+	b, s := newBybit(t, loadFixtures(t, "bybit"))
+
+	_, err := b.GetBalances(context.Background())
+	assert.NoError(t, err)
+	_, err = b.Buy(context.Background(), "PEPEUSDT", 1000000)
+	assert.NoError(t, err)
+
+	// Every request is signed with the API key and secret, over the query of GET requests and the body of POST requests.
+	requests := s.Requests("")
+	assert.GreaterOrEqual(t, len(requests), 3)
+	for _, r := range requests {
+		payload := r.URL.RawQuery
+		if r.Method == http.MethodPost {
+			payload = string(r.Body)
+		}
+		timestamp := r.Header.Get("X-BAPI-TIMESTAMP")
+		assert.Equal(t, "api key", r.Header.Get("X-BAPI-API-KEY"))
+		assert.Equal(t, market.BybitRecvWindow, r.Header.Get("X-BAPI-RECV-WINDOW"))
+		assert.Equal(t, market.BybitSignature("secret", timestamp+"api key"+market.BybitRecvWindow+payload), r.Header.Get("X-BAPI-SIGN"))
+	}
+}
+
+func TestBybit_symbols(t *testing.T) {
+	b, s := newBybit(t, loadFixtures(t, "bybit"))
+
+	// Instruments that aren't trading yet are halted.
+	symbols, err := b.GetSymbolsInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(symbols))
+	for _, symbol := range symbols {
+		assert.Equal(t, symbol.Symbol == "NEWUSDT", symbol.Halted)
+	}
+
+	// Orders are placed on the spot market, with the quantity in the base coin.
+	order, err := b.Buy(context.Background(), "PEPEUSDT", 1000000)
+	assert.NoError(t, err)
+	requests := s.Requests("/v5/order/create")
+	assert.Equal(t, 1, len(requests))
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(requests[0].Body, &body))
+	assert.Equal(t, "spot", body["category"])
+	assert.Equal(t, "PEPEUSDT", body["symbol"])
+	assert.Equal(t, "baseCoin", body["marketUnit"])
+
+	// Bybit charges the fee of buy orders in the base coin.
+	assert.Equal(t, market.Commissions{"PEPE": 1000}, order.Commissions)
+}
+
+func TestBybit_errors(t *testing.T) {
+	fixtures := loadFixtures(t, "bybit")
+	fixtures["/v5/order/create"] = []markettest.Response{
+		{Body: json.RawMessage(`{"retCode":10006,"retMsg":"Too many visits!","result":{},"retExtInfo":{},"time":1704067200000}`)},
+		{Body: json.RawMessage(`{"retCode":170131,"retMsg":"Insufficient balance.","result":{},"retExtInfo":{},"time":1704067200000}`)},
+	}
+	b, _ := newBybit(t, fixtures)
+
+	// Rate limits are transient and rejected, so the order may be retried.
+	_, err := b.Sell(context.Background(), "PEPEUSDT", 1000000)
+	transient, rejected := market.ClassifyError(err)
+	assert.True(t, transient)
+	assert.True(t, rejected)
+
+	_, err = b.Sell(context.Background(), "PEPEUSDT", 1000000)
+	assert.EqualError(t, err, "170131: Insufficient balance.")
+	transient, _ = market.ClassifyError(err)
+	assert.False(t, transient)
+}
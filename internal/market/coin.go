@@ -18,6 +18,9 @@ type Coin struct {
 
 	// The time this coin was indexed.
 	Time time.Time `json:"time"`
+
+	// Whether trading the coin is halted.
+	Halted bool `json:"halted,omitempty"`
 }
 
 type VolatileCoin struct {
@@ -41,6 +44,31 @@ type SymbolInfo struct {
 	// The step size of the coin.
 	// E.g. 0.001.
 	StepSize float64
+
+	// The minimum quantity of the coin per order.
+	// Zero means there's no minimum.
+	MinQuantity float64
+
+	// The maximum quantity of the coin per order.
+	// Zero means there's no maximum.
+	MaxQuantity float64
+
+	// The smallest price increment of the coin.
+	// E.g. 0.01.
+	TickSize float64
+
+	// The minimum value of an order in the quote asset, i.e. price * quantity.
+	// Zero means there's no minimum.
+	MinNotional float64
+
+	// The number of decimals of the base asset (e.g. BTC in BTCUSDT).
+	BaseAssetPrecision int
+
+	// The number of decimals of the quote asset (e.g. USDT in BTCUSDT).
+	QuoteAssetPrecision int
+
+	// Whether trading the symbol is halted, e.g. because it's under maintenance or being delisted.
+	Halted bool
 }
 
 func (c Coin) String() string {
@@ -48,8 +76,11 @@ func (c Coin) String() string {
 }
 
 // IsAvailableForTrading checks if the coin should be picked up by the bot for trading.
-// It checks whether the coin has the desired minimum quote asset trading volume, is in the custom list, and it's not a blacklisted symbol. These options are defined in the given config file.
+// It checks whether the coin can be traded at all, has the desired minimum quote asset trading volume, is in the custom list, and it's not a blacklisted symbol. These options are defined in the given config file.
 func (c Coin) IsAvailableForTrading(allowList, denyList []string, pairWith string, minQuoteVolumeTraded float64) bool {
+	if c.Halted {
+		return false
+	}
 	if minQuoteVolumeTraded != 0.0 && c.QuoteVolumeTraded < minQuoteVolumeTraded {
 		return false
 	}
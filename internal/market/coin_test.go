@@ -76,4 +76,9 @@ func TestCoin_IsAvailableForTrading(t *testing.T) {
 	coin.Symbol = "BTCUSDC"
 	coin.QuoteVolumeTraded = 10000
 	assert.Equal(t, false, coin.IsAvailableForTrading(allowList, denyList, pairWith, minQuoteVolumeTraded))
+
+	// test halted trading
+	coin.Symbol = "BTCUSDT"
+	coin.Halted = true
+	assert.Equal(t, false, coin.IsAvailableForTrading(allowList, denyList, pairWith, minQuoteVolumeTraded))
 }
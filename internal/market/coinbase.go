@@ -0,0 +1,524 @@
+package market
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ensures Coinbase implements the Market interface.
+var _ Market = (*Coinbase)(nil)
+
+const coinbaseBaseURL = "https://api.coinbase.com"
+
+// coinbaseRequestLimit is the maximum number of requests to send to Coinbase per minute.
+// Coinbase allows 30 private requests per second.
+const coinbaseRequestLimit = 1800
+
+// coinbaseJWTLifetime is how long the JWT of a request is valid, as required by Coinbase.
+const coinbaseJWTLifetime = 2 * time.Minute
+
+// coinbaseOrderPollInterval is the interval at which the status of a placed order is checked until it's done.
+const coinbaseOrderPollInterval = 500 * time.Millisecond
+
+// coinbaseOrderTimeout is how long to wait for a market order to be done, after which it's returned as is.
+const coinbaseOrderTimeout = 30 * time.Second
+
+// Coinbase is a market for the Coinbase Advanced Trade API.
+// Coinbase names its products after the base and quote currency (e.g. BTC-USD), so they are converted to the concatenated symbols used by all other markets (e.g. BTCUSD).
+// Coinbase identifies orders by a UUID, which doesn't fit in Order.OrderID, so it's left empty.
+type Coinbase struct {
+	config            config.Configuration
+	client            *http.Client
+	baseURL           string
+	orderPollInterval time.Duration
+
+	// The products by symbol, refreshed by every request for all of them.
+	mu       sync.Mutex
+	products map[string]coinbaseProduct
+}
+
+// coinbaseProduct is a product as returned by Coinbase.
+type coinbaseProduct struct {
+	ProductID                 string `json:"product_id"`
+	Price                     string `json:"price"`
+	Volume24h                 string `json:"volume_24h"`
+	ApproximateQuote24hVolume string `json:"approximate_quote_24h_volume"`
+	BaseIncrement             string `json:"base_increment"`
+	QuoteIncrement            string `json:"quote_increment"`
+	PriceIncrement            string `json:"price_increment"`
+	BaseMinSize               string `json:"base_min_size"`
+	BaseMaxSize               string `json:"base_max_size"`
+	QuoteMinSize              string `json:"quote_min_size"`
+	Status                    string `json:"status"`
+	TradingDisabled           bool   `json:"trading_disabled"`
+	IsDisabled                bool   `json:"is_disabled"`
+	CancelOnly                bool   `json:"cancel_only"`
+	LimitOnly                 bool   `json:"limit_only"`
+	PostOnly                  bool   `json:"post_only"`
+}
+
+// coinbaseOrder is an order as returned by Coinbase.
+type coinbaseOrder struct {
+	ProductID string `json:"product_id"`
+	Status    string `json:"status"`
+
+	// The filled quantity, its average price and its value in the quote currency, excluding fees.
+	FilledSize         string `json:"filled_size"`
+	AverageFilledPrice string `json:"average_filled_price"`
+	FilledValue        string `json:"filled_value"`
+
+	// The total fees of the order in the quote currency.
+	TotalFees string `json:"total_fees"`
+
+	CreatedTime  time.Time `json:"created_time"`
+	LastFillTime time.Time `json:"last_fill_time"`
+}
+
+func NewCoinbase(config config.Configuration) *Coinbase {
+	return &Coinbase{
+		config: config,
+		client: &http.Client{
+			Transport: serverErrorTransport{
+				transport: newRateLimiter(http.DefaultTransport, clock.Real{}, coinbaseRequestLimit, nil, ""),
+			},
+		},
+		baseURL:           coinbaseBaseURL,
+		orderPollInterval: coinbaseOrderPollInterval,
+	}
+}
+
+func (c *Coinbase) Name() string {
+	return "coinbase"
+}
+
+// coinbaseSymbol converts the given Coinbase product ID to a symbol.
+func coinbaseSymbol(productID string) string {
+	return strings.ReplaceAll(productID, "-", "")
+}
+
+// The products include their current price and volume, so all coins are fetched in a single request.
+func (c *Coinbase) GetCoins(ctx context.Context) (Coins, error) {
+	products, err := c.fetchProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	coins := make(Coins, len(products))
+	now := time.Now()
+
+	for symbol, product := range products {
+		price, _ := strconv.ParseFloat(product.Price, 64)
+		coins[symbol] = Coin{
+			Symbol:            symbol,
+			Price:             price,
+			QuoteVolumeTraded: product.quoteVolume(),
+			Time:              now,
+			Halted:            product.halted(),
+		}
+	}
+
+	return coins, nil
+}
+
+// quoteVolume returns the quote volume traded over the last 24 hours.
+// Falls back to converting the base volume at the current price if Coinbase doesn't report it.
+func (p coinbaseProduct) quoteVolume() float64 {
+	if volume, _ := strconv.ParseFloat(p.ApproximateQuote24hVolume, 64); volume != 0 {
+		return volume
+	}
+	volume, _ := strconv.ParseFloat(p.Volume24h, 64)
+	price, _ := strconv.ParseFloat(p.Price, 64)
+	return volume * price
+}
+
+// halted returns whether the product can't be traded with market orders.
+func (p coinbaseProduct) halted() bool {
+	return p.Status != "online" || p.TradingDisabled || p.IsDisabled || p.CancelOnly || p.LimitOnly || p.PostOnly
+}
+
+func (c *Coinbase) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
+	volumeMap := make(TradeVolumes)
+	if c.config.TradingOptions.MinQuoteVolumeTraded != 0.0 {
+		coins, err := c.GetCoins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for symbol, coin := range coins {
+			volumeMap[symbol] = coin.QuoteVolumeTraded
+		}
+	}
+	return volumeMap, nil
+}
+
+func (c *Coinbase) GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error) {
+	product, err := c.getProduct(ctx, symbol)
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+
+	return product.toSymbolInfo(symbol), nil
+}
+
+func (c *Coinbase) GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error) {
+	products, err := c.fetchProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]SymbolInfo, 0, len(products))
+	for symbol, product := range products {
+		symbols = append(symbols, product.toSymbolInfo(symbol))
+	}
+
+	return symbols, nil
+}
+
+// toSymbolInfo converts the given Coinbase product to a SymbolInfo.
+func (p coinbaseProduct) toSymbolInfo(symbol string) SymbolInfo {
+	info := SymbolInfo{
+		Symbol: symbol,
+		Halted: p.halted(),
+	}
+	info.StepSize, _ = strconv.ParseFloat(p.BaseIncrement, 64)
+	info.MinQuantity, _ = strconv.ParseFloat(p.BaseMinSize, 64)
+	info.MaxQuantity, _ = strconv.ParseFloat(p.BaseMaxSize, 64)
+	info.MinNotional, _ = strconv.ParseFloat(p.QuoteMinSize, 64)
+
+	quoteIncrement, _ := strconv.ParseFloat(p.QuoteIncrement, 64)
+	info.TickSize, _ = strconv.ParseFloat(p.PriceIncrement, 64)
+	if info.TickSize == 0 {
+		info.TickSize = quoteIncrement
+	}
+
+	if info.StepSize > 0 {
+		info.BaseAssetPrecision = int(math.Round(-math.Log10(info.StepSize)))
+	}
+	if quoteIncrement > 0 {
+		info.QuoteAssetPrecision = int(math.Round(-math.Log10(quoteIncrement)))
+	}
+
+	return info
+}
+
+// fetchProducts fetches all spot products from Coinbase and returns them by symbol.
+func (c *Coinbase) fetchProducts(ctx context.Context) (map[string]coinbaseProduct, error) {
+	var res struct {
+		Products []coinbaseProduct `json:"products"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v3/brokerage/products", url.Values{"product_type": {"SPOT"}}, nil, &res); err != nil {
+		return nil, err
+	}
+
+	products := make(map[string]coinbaseProduct, len(res.Products))
+	for _, product := range res.Products {
+		products[coinbaseSymbol(product.ProductID)] = product
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.products = products
+
+	return products, nil
+}
+
+// getProduct returns the product of the given symbol, fetching all products if they haven't been fetched yet.
+func (c *Coinbase) getProduct(ctx context.Context, symbol string) (coinbaseProduct, error) {
+	c.mu.Lock()
+	products := c.products
+	c.mu.Unlock()
+
+	if products == nil {
+		var err error
+		if products, err = c.fetchProducts(ctx); err != nil {
+			return coinbaseProduct{}, err
+		}
+	}
+
+	product, ok := products[symbol]
+	if !ok {
+		return coinbaseProduct{}, SymbolNotFoundError
+	}
+
+	return product, nil
+}
+
+func (c *Coinbase) GetBalances(ctx context.Context) (Balances, error) {
+	balances := make(Balances)
+
+	params := url.Values{"limit": {"250"}}
+	for {
+		var res struct {
+			Accounts []struct {
+				Currency         string `json:"currency"`
+				AvailableBalance struct {
+					Value string `json:"value"`
+				} `json:"available_balance"`
+				Hold struct {
+					Value string `json:"value"`
+				} `json:"hold"`
+			} `json:"accounts"`
+			HasNext bool   `json:"has_next"`
+			Cursor  string `json:"cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/api/v3/brokerage/accounts", params, nil, &res); err != nil {
+			return nil, err
+		}
+
+		for _, account := range res.Accounts {
+			free, _ := strconv.ParseFloat(account.AvailableBalance.Value, 64)
+			locked, _ := strconv.ParseFloat(account.Hold.Value, 64)
+			if free == 0 && locked == 0 {
+				continue
+			}
+			balances[account.Currency] = Balance{
+				Asset:  account.Currency,
+				Free:   free,
+				Locked: locked,
+			}
+		}
+
+		if !res.HasNext {
+			return balances, nil
+		}
+		params.Set("cursor", res.Cursor)
+	}
+}
+
+func (c *Coinbase) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return c.executeOrder(ctx, coin, quantity, "BUY")
+}
+
+func (c *Coinbase) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return c.executeOrder(ctx, coin, quantity, "SELL")
+}
+
+// executeOrder places an immediate-or-cancel market order for the given quantity of the given coin and waits for it to be done.
+func (c *Coinbase) executeOrder(ctx context.Context, coin string, quantity float64, side string) (Order, error) {
+	product, err := c.getProduct(ctx, coin)
+	if err != nil {
+		return Order{}, err
+	}
+
+	body := map[string]any{
+		"client_order_id": uuid.NewString(),
+		"product_id":      product.ProductID,
+		"side":            side,
+		"order_configuration": map[string]any{
+			"market_market_ioc": map[string]string{
+				"base_size": strconv.FormatFloat(quantity, 'f', -1, 64),
+			},
+		},
+	}
+	var created struct {
+		Success         bool `json:"success"`
+		SuccessResponse struct {
+			OrderID string `json:"order_id"`
+		} `json:"success_response"`
+		ErrorResponse struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		} `json:"error_response"`
+	}
+	if err = c.do(ctx, http.MethodPost, "/api/v3/brokerage/orders", nil, body, &created); err != nil {
+		return Order{}, err
+	}
+	if !created.Success {
+		return Order{}, fmt.Errorf("coinbase rejected the order: %s: %s", created.ErrorResponse.Error, created.ErrorResponse.Message)
+	}
+	orderID := created.SuccessResponse.OrderID
+
+	deadline := time.Now().Add(coinbaseOrderTimeout)
+	for {
+		var res struct {
+			Order coinbaseOrder `json:"order"`
+		}
+		if err = c.do(ctx, http.MethodGet, "/api/v3/brokerage/orders/historical/"+orderID, nil, nil, &res); err != nil {
+			return Order{}, err
+		}
+
+		if (res.Order.Status != "PENDING" && res.Order.Status != "OPEN" && res.Order.Status != "QUEUED") || time.Now().After(deadline) {
+			_, quoteAsset, _ := strings.Cut(product.ProductID, "-")
+			return res.Order.toOrder(coin, quoteAsset), nil
+		}
+
+		if err = sleep(ctx, c.orderPollInterval); err != nil {
+			return Order{}, err
+		}
+	}
+}
+
+// toOrder converts the given Coinbase order of the given coin to an Order.
+// Coinbase charges the fees in the given quote asset.
+func (o coinbaseOrder) toOrder(coin, quoteAsset string) Order {
+	order := Order{Symbol: coin, TransactionTime: o.LastFillTime}
+	if order.TransactionTime.IsZero() {
+		order.TransactionTime = o.CreatedTime
+	}
+	order.FilledQuantity, _ = strconv.ParseFloat(o.FilledSize, 64)
+	order.Price, _ = strconv.ParseFloat(o.AverageFilledPrice, 64)
+	order.CumulativeQuoteQuantity, _ = strconv.ParseFloat(o.FilledValue, 64)
+	if fee, _ := strconv.ParseFloat(o.TotalFees, 64); fee != 0 {
+		order.Commissions.Add(quoteAsset, fee)
+	}
+
+	switch {
+	case o.Status == "FILLED":
+		order.Status = FilledOrderStatus
+	case order.FilledQuantity > 0:
+		order.Status = PartiallyFilledOrderStatus
+	case o.Status == "CANCELLED" || o.Status == "EXPIRED" || o.Status == "FAILED":
+		order.Status = CanceledOrderStatus
+	default:
+		order.Status = NewOrderStatus
+	}
+
+	return order
+}
+
+// PlaceOCO always returns NotSupportedError, because Coinbase doesn't support OCO orders.
+func (c *Coinbase) PlaceOCO(_ context.Context, _ string, _, _, _ float64) (OCO, error) {
+	return OCO{}, NotSupportedError
+}
+
+func (c *Coinbase) CancelOCO(_ context.Context, _ OCO) error {
+	return NotSupportedError
+}
+
+func (c *Coinbase) GetOCOFill(_ context.Context, _ OCO) (Order, bool, error) {
+	return Order{}, false, NotSupportedError
+}
+
+// do sends an authenticated request with the given query parameters and JSON body to the given path and decodes the response into the given value.
+func (c *Coinbase) do(ctx context.Context, method, path string, params url.Values, body any, result any) error {
+	u, err := url.Parse(c.baseURL + path)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = params.Encode()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	token, err := c.jwt(method, u.Host, u.Path, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&e)
+		err = fmt.Errorf("%s %s: %s: %s", method, path, res.Status, e.Message)
+		switch res.StatusCode {
+		case http.StatusTooManyRequests:
+			return &TransientError{Err: err, Rejected: true}
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", SymbolNotFoundError, err)
+		}
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// jwt returns a JWT that authenticates a request with the given method to the given host and path.
+// See https://docs.cdp.coinbase.com/advanced-trade/docs/rest-api-auth.
+func (c *Coinbase) jwt(method, host, path string, now time.Time) (string, error) {
+	key, err := parseCoinbaseKey(c.config.Markets.Coinbase.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	keyName := c.config.Markets.Coinbase.KeyName
+
+	header, _ := json.Marshal(map[string]string{
+		"alg":   "ES256",
+		"typ":   "JWT",
+		"kid":   keyName,
+		"nonce": uuid.NewString(),
+	})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": "cdp",
+		"sub": keyName,
+		"nbf": now.Unix(),
+		"exp": now.Add(coinbaseJWTLifetime).Unix(),
+		"uri": method + " " + host + path,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	// ES256 signatures are the concatenation of r and s, each padded to 32 bytes.
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseCoinbaseKey parses the given PEM encoded EC private key of a Coinbase API key.
+func parseCoinbaseKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	// Keys are often pasted with escaped newlines, as they are shown in the JSON file downloaded from Coinbase.
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(privateKey, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("invalid coinbase private key: no PEM data found")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coinbase private key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid coinbase private key: not an EC key")
+	}
+
+	return ecKey, nil
+}
@@ -0,0 +1,143 @@
+package market_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/market/markettest"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newCoinbase returns a Coinbase client that sends its requests to a server answering them with the given fixtures.
+// It also returns the public key of the private key the requests are signed with.
+// The behavior Coinbase shares with all other markets is covered by the conformance suite, so only what's specific to Coinbase is tested here.
+func newCoinbase(t *testing.T, fixtures markettest.Fixtures) (*market.Coinbase, *markettest.Server, *ecdsa.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	s := markettest.NewServer(t, fixtures)
+	c := market.NewCoinbase(config.Configuration{
+		Markets: config.Markets{
+			// The key is pasted with escaped newlines, like it's shown in the file downloaded from Coinbase.
+			Coinbase: config.Coinbase{KeyName: "organizations/org/apiKeys/key", PrivateKey: strings.ReplaceAll(privateKey, "\n", `\n`)},
+		},
+	})
+	market.SetBaseURL(c, s.URL)
+	return c, s, &key.PublicKey
+}
+
+// verifyCoinbaseJWT verifies the signature of the given JWT with the given public key and returns its claims.
+func verifyCoinbaseJWT(t *testing.T, key *ecdsa.PublicKey, token string) map[string]any {
+	parts := strings.Split(token, ".")
+	if !assert.Equal(t, 3, len(parts)) {
+		return nil
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(key, hash[:], r, s), "invalid JWT signature")
+
+	var header map[string]string
+	rawHeader, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.NoError(t, json.Unmarshal(rawHeader, &header))
+	assert.Equal(t, "ES256", header["alg"])
+	assert.Equal(t, "organizations/org/apiKeys/key", header["kid"])
+	assert.NotEmpty(t, header["nonce"])
+
+	var claims map[string]any
+	rawClaims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, json.Unmarshal(rawClaims, &claims))
+
+	return claims
+}
+
+func TestCoinbase_signing(t *testing.T) {
+	c, s, key := newCoinbase(t, loadFixtures(t, "coinbase"))
+
+	// The accounts are spread over two pages, which are requested with the same URI.
+	balances, err := c.GetBalances(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, market.Balances{
+		"BTC": {Asset: "BTC", Free: 0.5, Locked: 0.1},
+		"USD": {Asset: "USD", Free: 1500.25},
+	}, balances)
+	_, err = c.Buy(context.Background(), "BTCUSD", 0.01)
+	assert.NoError(t, err)
+
+	// Every request carries a JWT for its URI, signed with the private key.
+	u, _ := url.Parse(s.URL)
+	requests := s.Requests("")
+	assert.GreaterOrEqual(t, len(requests), 3)
+	for _, r := range requests {
+		claims := verifyCoinbaseJWT(t, key, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		assert.Equal(t, "organizations/org/apiKeys/key", claims["sub"])
+		assert.Equal(t, r.Method+" "+u.Host+r.URL.Path, claims["uri"])
+	}
+}
+
+func TestCoinbase_symbols(t *testing.T) {
+	c, s, _ := newCoinbase(t, loadFixtures(t, "coinbase"))
+
+	// Products are named like on all other markets.
+	coins, err := c.GetCoins(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(coins))
+	for _, symbol := range []string{"BTCUSD", "ETHUSDC", "SHIBUSD"} {
+		assert.Contains(t, coins, symbol)
+	}
+
+	// Market orders can't be placed on limit only products.
+	assert.True(t, coins["SHIBUSD"].Halted)
+	assert.False(t, coins["BTCUSD"].Halted)
+
+	// Products can't be looked up by their Coinbase name.
+	_, err = c.GetSymbolInfo(context.Background(), "BTC-USD")
+	assert.ErrorIs(t, err, market.SymbolNotFoundError)
+
+	// Orders are placed with the Coinbase name of the product.
+	_, err = c.Buy(context.Background(), "BTCUSD", 0.01)
+	assert.NoError(t, err)
+	requests := s.Requests("/api/v3/brokerage/orders")
+	assert.Equal(t, 1, len(requests))
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(requests[0].Body, &body))
+	assert.Equal(t, "BTC-USD", body["product_id"])
+}
+
+func TestCoinbase_errors(t *testing.T) {
+	fixtures := loadFixtures(t, "coinbase")
+	fixtures["/api/v3/brokerage/orders"] = []markettest.Response{
+		{Status: http.StatusTooManyRequests, Body: json.RawMessage(`{"error":"RESOURCE_EXHAUSTED","message":"Too many requests"}`)},
+		{Body: json.RawMessage(`{"success":false,"failure_reason":"UNKNOWN_FAILURE_REASON","error_response":{"error":"INSUFFICIENT_FUND","message":"Insufficient balance in source account","error_details":"","preview_failure_reason":"PREVIEW_INSUFFICIENT_FUND"}}`)},
+	}
+	c, _, _ := newCoinbase(t, fixtures)
+
+	// Rate limits are transient and rejected, so the order may be retried.
+	_, err := c.Sell(context.Background(), "BTCUSD", 1)
+	transient, rejected := market.ClassifyError(err)
+	assert.True(t, transient)
+	assert.True(t, rejected)
+
+	_, err = c.Sell(context.Background(), "BTCUSD", 1)
+	assert.ErrorContains(t, err, "INSUFFICIENT_FUND")
+	transient, _ = market.ClassifyError(err)
+	assert.False(t, transient)
+}
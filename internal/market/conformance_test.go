@@ -0,0 +1,65 @@
+package market_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/market/markettest"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+// loadFixtures loads the fixtures of the market with the given name from testdata.
+func loadFixtures(t *testing.T, name string) markettest.Fixtures {
+	return markettest.LoadFixtures(t, filepath.Join("testdata", "conformance", name+".json"))
+}
+
+// newConformanceSuite returns the conformance suite of the market with the given name, using its fixtures from testdata.
+func newConformanceSuite(t *testing.T, name, symbol string, quantity float64, newMarket func(c config.Configuration) market.Market, c config.Configuration) markettest.Suite {
+	return markettest.Suite{
+		Name: name,
+		NewMarket: func(t *testing.T, baseURL string) market.Market {
+			m := newMarket(c)
+			market.SetBaseURL(m, baseURL)
+			return m
+		},
+		Fixtures: loadFixtures(t, name),
+		Symbol:   symbol,
+		Quantity: quantity,
+	}
+}
+
+func TestConformance(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	c := config.Configuration{
+		Markets: config.Markets{
+			Binance:  config.Binance{AccessKey: "access key", SecretKey: "secret"},
+			Kraken:   config.Kraken{APIKey: "api key", PrivateKey: base64.StdEncoding.EncodeToString([]byte("secret"))},
+			Coinbase: config.Coinbase{KeyName: "organizations/org/apiKeys/key", PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))},
+			KuCoin:   config.KuCoin{APIKey: "api key", SecretKey: "secret", Passphrase: "passphrase"},
+			Bybit:    config.Bybit{APIKey: "api key", SecretKey: "secret"},
+		},
+	}
+
+	suites := []markettest.Suite{
+		newConformanceSuite(t, "binance", "BTCUSDT", 0.01, func(c config.Configuration) market.Market { return market.NewBinance(c) }, c),
+		newConformanceSuite(t, "kraken", "BTCUSDT", 0.01, func(c config.Configuration) market.Market { return market.NewKraken(c) }, c),
+		newConformanceSuite(t, "coinbase", "BTCUSD", 0.01, func(c config.Configuration) market.Market { return market.NewCoinbase(c) }, c),
+		newConformanceSuite(t, "kucoin", "PEPEUSDT", 1000000, func(c config.Configuration) market.Market { return market.NewKuCoin(c) }, c),
+		newConformanceSuite(t, "bybit", "PEPEUSDT", 1000000, func(c config.Configuration) market.Market { return market.NewBybit(c) }, c),
+	}
+
+	for _, suite := range suites {
+		t.Run(suite.Name, suite.Run)
+	}
+}
@@ -0,0 +1,79 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// TransientError wraps an error that is expected to resolve itself, such as a rate limit or a server error.
+// Requests that failed with a transient error may succeed when they are retried later on.
+type TransientError struct {
+	Err error
+
+	// Whether the market is known to have rejected the request before processing it.
+	// Only then it's safe to retry requests that aren't idempotent, such as orders.
+	// Otherwise, the request may have been processed regardless of the error.
+	Rejected bool
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError reports whether the given error is transient and whether the request that caused it was rejected before it reached the market.
+// See TransientError.
+func classifyError(err error) (transient bool, rejected bool) {
+	var transientErr *TransientError
+	if errors.As(err, &transientErr) {
+		return true, transientErr.Rejected
+	}
+
+	// Connection failures, including DNS lookups, happen before anything is sent.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true, true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true, true
+	}
+
+	// The connection broke or timed out after the request may have been sent.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true, false
+	}
+
+	return false, false
+}
+
+// serverErrorTransport is an HTTP transport that turns 5xx responses into transient errors.
+// The request may have been processed regardless, so they are not marked as rejected.
+type serverErrorTransport struct {
+	transport http.RoundTripper
+}
+
+func (t serverErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		_ = res.Body.Close()
+		return nil, &TransientError{Err: fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, res.Status)}
+	}
+
+	return res, nil
+}
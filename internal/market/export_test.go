@@ -0,0 +1,37 @@
+package market
+
+import "time"
+
+// SetBaseURL points the HTTP requests of the given market at the given base URL and makes it poll orders without delay.
+// It's exported for the conformance tests in package market_test only.
+func SetBaseURL(m Market, baseURL string) {
+	switch m := m.(type) {
+	case *Binance:
+		m.client.BaseURL = baseURL
+		m.orderPollInterval = time.Millisecond
+	case *Kraken:
+		m.baseURL = baseURL
+		m.orderPollInterval = time.Millisecond
+	case *Coinbase:
+		m.baseURL = baseURL
+		m.orderPollInterval = time.Millisecond
+	case *KuCoin:
+		m.baseURL = baseURL
+		m.orderPollInterval = time.Millisecond
+	case *Bybit:
+		m.baseURL = baseURL
+		m.orderPollInterval = time.Millisecond
+	default:
+		panic("SetBaseURL: unsupported market " + m.Name())
+	}
+}
+
+// Exported for the tests of the markets in package market_test, which can't be in package market because they use package markettest.
+var (
+	ClassifyError   = classifyError
+	KrakenSignature = krakenSignature
+	KuCoinSignature = kucoinSignature
+	BybitSignature  = bybitSignature
+)
+
+const BybitRecvWindow = bybitRecvWindow
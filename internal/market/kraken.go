@@ -0,0 +1,517 @@
+package market
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ensures Kraken implements the Market interface.
+var _ Market = (*Kraken)(nil)
+
+const krakenBaseURL = "https://api.kraken.com"
+
+// krakenRequestLimit is the maximum number of requests to send to Kraken per minute.
+// Kraken allows about 1 public request per second and decays the counter of private requests at a similar rate.
+const krakenRequestLimit = 60
+
+// krakenOrderPollInterval is the interval at which the status of a placed order is checked until it's closed.
+const krakenOrderPollInterval = 500 * time.Millisecond
+
+// krakenOrderTimeout is how long to wait for a market order to be closed, after which it's returned as is.
+const krakenOrderTimeout = 30 * time.Second
+
+// krakenAssetAliases maps the names Kraken uses for some assets to the names used by all other markets.
+var krakenAssetAliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// krakenTransientErrors maps the Kraken errors that are expected to resolve themselves to whether the request was rejected.
+// See https://docs.kraken.com/api/docs/guides/spot-errors.
+var krakenTransientErrors = map[string]bool{
+	"EAPI:Rate limit exceeded":   true,
+	"EOrder:Rate limit exceeded": true,
+	"EGeneral:Temporary lockout": true,
+	"EService:Unavailable":       true,
+	"EService:Busy":              true,
+	"EGeneral:Internal error":    false,
+	"EService:Deadline elapsed":  false,
+}
+
+// Kraken is a market for the Kraken spot exchange.
+// Kraken names its pairs and some assets differently (e.g. XXBTZUSD for BTC/USD), so they are converted to the concatenated symbols and asset names used by all other markets (e.g. BTCUSD).
+// Kraken identifies orders by a string transaction ID, which doesn't fit in Order.OrderID, so it's left empty.
+type Kraken struct {
+	config            config.Configuration
+	client            *http.Client
+	baseURL           string
+	orderPollInterval time.Duration
+
+	mu    sync.Mutex
+	nonce int64
+
+	// The asset pairs by symbol, the symbols by Kraken pair name and the asset names by Kraken asset name.
+	// They are loaded on first use and refreshed by GetSymbolsInfo.
+	pairs   map[string]krakenPair
+	symbols map[string]string
+	assets  map[string]string
+}
+
+// krakenPair is a tradable asset pair as returned by Kraken.
+type krakenPair struct {
+	name string
+
+	WSName       string `json:"wsname"`
+	Base         string `json:"base"`
+	Quote        string `json:"quote"`
+	LotDecimals  int    `json:"lot_decimals"`
+	PairDecimals int    `json:"pair_decimals"`
+	CostDecimals int    `json:"cost_decimals"`
+	OrderMin     string `json:"ordermin"`
+	CostMin      string `json:"costmin"`
+	TickSize     string `json:"tick_size"`
+	Status       string `json:"status"`
+}
+
+// krakenTicker is the ticker of an asset pair as returned by Kraken.
+type krakenTicker struct {
+	// The last trade closed as [price, lot volume].
+	Close []string `json:"c"`
+
+	// The volume as [today, last 24 hours].
+	Volume []string `json:"v"`
+
+	// The volume weighted average price as [today, last 24 hours].
+	VWAP []string `json:"p"`
+}
+
+// krakenOrder is an order as returned by Kraken.
+type krakenOrder struct {
+	Status string `json:"status"`
+
+	// The times the order was opened and closed, in seconds since the unix epoch.
+	OpenTime  float64 `json:"opentm"`
+	CloseTime float64 `json:"closetm"`
+
+	// The requested and the executed volume.
+	Volume     string `json:"vol"`
+	VolumeExec string `json:"vol_exec"`
+
+	// The total cost of the order in the quote currency, excluding the fee.
+	Cost string `json:"cost"`
+
+	// The total fee of the order in the quote currency.
+	Fee string `json:"fee"`
+
+	// The average price of the order.
+	Price string `json:"price"`
+}
+
+func NewKraken(config config.Configuration) *Kraken {
+	return &Kraken{
+		config: config,
+		client: &http.Client{
+			Transport: serverErrorTransport{
+				transport: newRateLimiter(http.DefaultTransport, clock.Real{}, krakenRequestLimit, nil, ""),
+			},
+		},
+		baseURL:           krakenBaseURL,
+		orderPollInterval: krakenOrderPollInterval,
+	}
+}
+
+func (k *Kraken) Name() string {
+	return "kraken"
+}
+
+func (k *Kraken) GetCoins(ctx context.Context) (Coins, error) {
+	pairs, symbols, _, err := k.loadPairs(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickers map[string]krakenTicker
+	if err = k.public(ctx, "/0/public/Ticker", nil, &tickers); err != nil {
+		return nil, err
+	}
+
+	coins := make(Coins, len(tickers))
+	now := time.Now()
+
+	for name, ticker := range tickers {
+		symbol, ok := symbols[name]
+		if !ok || len(ticker.Close) == 0 {
+			continue
+		}
+
+		price, _ := strconv.ParseFloat(ticker.Close[0], 64)
+		coins[symbol] = Coin{
+			Symbol:            symbol,
+			Price:             price,
+			QuoteVolumeTraded: ticker.quoteVolume(),
+			Time:              now,
+			Halted:            pairs[symbol].Status != "online",
+		}
+	}
+
+	return coins, nil
+}
+
+// quoteVolume returns the quote volume traded over the last 24 hours.
+// Kraken only reports the volume in the base asset, so it's converted at the volume weighted average price.
+func (t krakenTicker) quoteVolume() float64 {
+	if len(t.Volume) < 2 || len(t.VWAP) < 2 {
+		return 0
+	}
+	volume, _ := strconv.ParseFloat(t.Volume[1], 64)
+	vwap, _ := strconv.ParseFloat(t.VWAP[1], 64)
+	return volume * vwap
+}
+
+func (k *Kraken) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
+	volumeMap := make(TradeVolumes)
+	if k.config.TradingOptions.MinQuoteVolumeTraded != 0.0 {
+		coins, err := k.GetCoins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for symbol, coin := range coins {
+			volumeMap[symbol] = coin.QuoteVolumeTraded
+		}
+	}
+	return volumeMap, nil
+}
+
+func (k *Kraken) GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error) {
+	pairs, _, _, err := k.loadPairs(ctx, false)
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+
+	pair, ok := pairs[symbol]
+	if !ok {
+		return SymbolInfo{}, SymbolNotFoundError
+	}
+
+	return pair.toSymbolInfo(symbol), nil
+}
+
+func (k *Kraken) GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error) {
+	pairs, _, _, err := k.loadPairs(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]SymbolInfo, 0, len(pairs))
+	for symbol, pair := range pairs {
+		symbols = append(symbols, pair.toSymbolInfo(symbol))
+	}
+
+	return symbols, nil
+}
+
+// toSymbolInfo converts the given Kraken pair to a SymbolInfo.
+func (p krakenPair) toSymbolInfo(symbol string) SymbolInfo {
+	info := SymbolInfo{
+		Symbol:              symbol,
+		StepSize:            math.Pow10(-p.LotDecimals),
+		BaseAssetPrecision:  p.LotDecimals,
+		QuoteAssetPrecision: p.CostDecimals,
+		Halted:              p.Status != "online",
+	}
+	info.MinQuantity, _ = strconv.ParseFloat(p.OrderMin, 64)
+	info.MinNotional, _ = strconv.ParseFloat(p.CostMin, 64)
+	info.TickSize, _ = strconv.ParseFloat(p.TickSize, 64)
+	if info.TickSize == 0 {
+		info.TickSize = math.Pow10(-p.PairDecimals)
+	}
+	return info
+}
+
+// loadPairs returns the asset pairs by symbol, the symbols by Kraken pair name and the asset names by Kraken asset name.
+// They are only fetched from Kraken if they haven't been loaded yet or reload is true.
+func (k *Kraken) loadPairs(ctx context.Context, reload bool) (map[string]krakenPair, map[string]string, map[string]string, error) {
+	k.mu.Lock()
+	if k.pairs != nil && !reload {
+		defer k.mu.Unlock()
+		return k.pairs, k.symbols, k.assets, nil
+	}
+	k.mu.Unlock()
+
+	var result map[string]krakenPair
+	if err := k.public(ctx, "/0/public/AssetPairs", nil, &result); err != nil {
+		return nil, nil, nil, err
+	}
+
+	pairs := make(map[string]krakenPair, len(result))
+	symbols := make(map[string]string, len(result))
+	assets := make(map[string]string)
+	for name, pair := range result {
+		// Dark pool pairs don't have a websocket name and can't be traded with market orders.
+		base, quote, ok := strings.Cut(pair.WSName, "/")
+		if !ok {
+			continue
+		}
+		base, quote = krakenAssetName(base), krakenAssetName(quote)
+		symbol := base + quote
+
+		pair.name = name
+		pairs[symbol] = pair
+		symbols[name] = symbol
+		assets[pair.Base] = base
+		assets[pair.Quote] = quote
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pairs, k.symbols, k.assets = pairs, symbols, assets
+
+	return pairs, symbols, assets, nil
+}
+
+// krakenAssetName converts the given Kraken asset name to the name used by all other markets.
+func krakenAssetName(asset string) string {
+	if alias, ok := krakenAssetAliases[asset]; ok {
+		return alias
+	}
+	return asset
+}
+
+func (k *Kraken) GetBalances(ctx context.Context) (Balances, error) {
+	_, _, assets, err := k.loadPairs(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]struct {
+		Balance   string `json:"balance"`
+		HoldTrade string `json:"hold_trade"`
+	}
+	if err = k.private(ctx, "/0/private/BalanceEx", nil, &result); err != nil {
+		return nil, err
+	}
+
+	balances := make(Balances, len(result))
+	for name, b := range result {
+		asset, ok := assets[name]
+		if !ok {
+			asset = krakenAssetName(name)
+		}
+
+		total, _ := strconv.ParseFloat(b.Balance, 64)
+		locked, _ := strconv.ParseFloat(b.HoldTrade, 64)
+		if total == 0 {
+			continue
+		}
+		balances[asset] = Balance{
+			Asset:  asset,
+			Free:   total - locked,
+			Locked: locked,
+		}
+	}
+
+	return balances, nil
+}
+
+func (k *Kraken) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return k.executeOrder(ctx, coin, quantity, "buy")
+}
+
+func (k *Kraken) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return k.executeOrder(ctx, coin, quantity, "sell")
+}
+
+// executeOrder places a market order for the given quantity of the given coin and waits for it to be closed.
+func (k *Kraken) executeOrder(ctx context.Context, coin string, quantity float64, side string) (Order, error) {
+	pairs, _, assets, err := k.loadPairs(ctx, false)
+	if err != nil {
+		return Order{}, err
+	}
+	pair, ok := pairs[coin]
+	if !ok {
+		return Order{}, SymbolNotFoundError
+	}
+
+	params := url.Values{
+		"pair":      {pair.name},
+		"type":      {side},
+		"ordertype": {"market"},
+		"volume":    {strconv.FormatFloat(quantity, 'f', pair.LotDecimals, 64)},
+	}
+	var added struct {
+		TxID []string `json:"txid"`
+	}
+	if err = k.private(ctx, "/0/private/AddOrder", params, &added); err != nil {
+		return Order{}, err
+	}
+	if len(added.TxID) == 0 {
+		return Order{}, errors.New("kraken didn't return the transaction ID of the order")
+	}
+	txID := added.TxID[0]
+
+	deadline := time.Now().Add(krakenOrderTimeout)
+	for {
+		var orders map[string]krakenOrder
+		if err = k.private(ctx, "/0/private/QueryOrders", url.Values{"txid": {txID}}, &orders); err != nil {
+			return Order{}, err
+		}
+		o, ok := orders[txID]
+		if !ok {
+			return Order{}, fmt.Errorf("kraken order %s not found", txID)
+		}
+
+		if (o.Status != "pending" && o.Status != "open") || time.Now().After(deadline) {
+			return o.toOrder(coin, assets[pair.Quote]), nil
+		}
+
+		if err = sleep(ctx, k.orderPollInterval); err != nil {
+			return Order{}, err
+		}
+	}
+}
+
+// toOrder converts the given Kraken order of the given coin to an Order.
+// Kraken charges the fees of market orders in the given quote asset.
+func (o krakenOrder) toOrder(coin, quoteAsset string) Order {
+	order := Order{Symbol: coin}
+	volume, _ := strconv.ParseFloat(o.Volume, 64)
+	order.FilledQuantity, _ = strconv.ParseFloat(o.VolumeExec, 64)
+	order.Price, _ = strconv.ParseFloat(o.Price, 64)
+	order.CumulativeQuoteQuantity, _ = strconv.ParseFloat(o.Cost, 64)
+	if fee, _ := strconv.ParseFloat(o.Fee, 64); fee != 0 {
+		order.Commissions.Add(quoteAsset, fee)
+	}
+
+	transactionTime := o.CloseTime
+	if transactionTime == 0 {
+		transactionTime = o.OpenTime
+	}
+	order.TransactionTime = time.UnixMilli(int64(transactionTime * 1000))
+
+	switch {
+	case order.FilledQuantity > 0 && order.FilledQuantity >= volume:
+		order.Status = FilledOrderStatus
+	case order.FilledQuantity > 0:
+		order.Status = PartiallyFilledOrderStatus
+	case o.Status == "canceled" || o.Status == "expired":
+		order.Status = CanceledOrderStatus
+	default:
+		order.Status = NewOrderStatus
+	}
+
+	return order
+}
+
+// PlaceOCO always returns NotSupportedError, because Kraken doesn't support OCO orders on spot markets.
+func (k *Kraken) PlaceOCO(_ context.Context, _ string, _, _, _ float64) (OCO, error) {
+	return OCO{}, NotSupportedError
+}
+
+func (k *Kraken) CancelOCO(_ context.Context, _ OCO) error {
+	return NotSupportedError
+}
+
+func (k *Kraken) GetOCOFill(_ context.Context, _ OCO) (Order, bool, error) {
+	return Order{}, false, NotSupportedError
+}
+
+// public sends a request to the given public endpoint and decodes its result into the given value.
+func (k *Kraken) public(ctx context.Context, path string, params url.Values, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return k.do(req, result)
+}
+
+// private sends a signed request to the given private endpoint and decodes its result into the given value.
+// See https://docs.kraken.com/api/docs/guides/spot-rest-auth.
+func (k *Kraken) private(ctx context.Context, path string, params url.Values, result any) error {
+	secret, err := base64.StdEncoding.DecodeString(k.config.Markets.Kraken.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid kraken private key: %w", err)
+	}
+
+	if params == nil {
+		params = url.Values{}
+	}
+	nonce := strconv.FormatInt(k.nextNonce(), 10)
+	params.Set("nonce", nonce)
+	body := params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.baseURL+path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("API-Key", k.config.Markets.Kraken.APIKey)
+	req.Header.Set("API-Sign", krakenSignature(path, nonce, body, secret))
+
+	return k.do(req, result)
+}
+
+// krakenSignature signs the given request body with the given secret.
+func krakenSignature(path, nonce, body string, secret []byte) string {
+	hash := sha256.Sum256([]byte(nonce + body))
+	mac := hmac.New(sha512.New, secret)
+	mac.Write([]byte(path))
+	mac.Write(hash[:])
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// nextNonce returns a nonce that is higher than all previous ones, as required by Kraken.
+func (k *Kraken) nextNonce() int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.nonce = max(k.nonce+1, time.Now().UnixMilli())
+	return k.nonce
+}
+
+// do sends the given request and decodes the result of the response into the given value.
+func (k *Kraken) do(req *http.Request, result any) error {
+	res, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Error  []string        `json:"error"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return fmt.Errorf("%s %s: %s: %w", req.Method, req.URL.Path, res.Status, err)
+	}
+	if len(body.Error) > 0 {
+		return krakenError(body.Error)
+	}
+
+	return json.Unmarshal(body.Result, result)
+}
+
+// krakenError converts the given Kraken errors to an error, marking it as transient if Kraken reports it as such.
+func krakenError(errs []string) error {
+	err := errors.New(strings.Join(errs, ", "))
+	if errs[0] == "EQuery:Unknown asset pair" {
+		return fmt.Errorf("%w: %s", SymbolNotFoundError, err)
+	}
+	if rejected, ok := krakenTransientErrors[errs[0]]; ok {
+		return &TransientError{Err: err, Rejected: rejected}
+	}
+	return err
+}
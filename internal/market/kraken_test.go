@@ -0,0 +1,106 @@
+package market_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/market/markettest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// newKraken returns a Kraken client that sends its requests to a server answering them with the given fixtures.
+// The behavior Kraken shares with all other markets is covered by the conformance suite, so only what's specific to Kraken is tested here.
+func newKraken(t *testing.T, fixtures markettest.Fixtures) (*market.Kraken, *markettest.Server) {
+	s := markettest.NewServer(t, fixtures)
+	k := market.NewKraken(config.Configuration{
+		Markets: config.Markets{
+			Kraken: config.Kraken{APIKey: "api key", PrivateKey: base64.StdEncoding.EncodeToString([]byte("kraken secret"))},
+		},
+	})
+	market.SetBaseURL(k, s.URL)
+	return k, s
+}
+
+func TestKraken_signing(t *testing.T) {
+	k, s := newKraken(t, loadFixtures(t, "kraken"))
+
+	_, err := k.GetBalances(context.Background())
+	assert.NoError(t, err)
+	_, err = k.Buy(context.Background(), "BTCUSDT", 0.01)
+	assert.NoError(t, err)
+
+	// Private requests are signed with the API key and the decoded private key.
+	private := 0
+	for _, r := range s.Requests("") {
+		if r.Method != http.MethodPost {
+			continue
+		}
+		private++
+		form, err := url.ParseQuery(string(r.Body))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, form.Get("nonce"))
+		assert.Equal(t, "api key", r.Header.Get("API-Key"))
+		assert.Equal(t, market.KrakenSignature(r.URL.Path, form.Get("nonce"), string(r.Body), []byte("kraken secret")), r.Header.Get("API-Sign"))
+	}
+	assert.GreaterOrEqual(t, private, 2)
+}
+
+func TestKraken_symbols(t *testing.T) {
+	k, s := newKraken(t, loadFixtures(t, "kraken"))
+
+	// Pairs and assets are named like on all other markets, e.g. XBT is BTC and XDG is DOGE, without the dark pools.
+	coins, err := k.GetCoins(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(coins))
+	for _, symbol := range []string{"BTCUSD", "BTCUSDT", "DOGEUSDT", "ADAUSDT"} {
+		assert.Contains(t, coins, symbol)
+	}
+
+	// Pairs that don't accept new orders are halted.
+	assert.True(t, coins["ADAUSDT"].Halted)
+	assert.False(t, coins["BTCUSDT"].Halted)
+
+	// Pairs can't be looked up by their Kraken name.
+	_, err = k.GetSymbolInfo(context.Background(), "XBTUSDT")
+	assert.ErrorIs(t, err, market.SymbolNotFoundError)
+
+	balances, err := k.GetBalances(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(balances))
+	assert.InDelta(t, 0.05, balances["BTC"].Free, 1e-12)
+	assert.Equal(t, 0.0025, balances["BTC"].Locked)
+	assert.Equal(t, market.Balance{Asset: "USDT", Free: 1500}, balances["USDT"])
+
+	// Orders are placed with the Kraken name of the pair.
+	_, err = k.Buy(context.Background(), "BTCUSDT", 0.01)
+	assert.NoError(t, err)
+	orders := s.Requests("/0/private/AddOrder")
+	assert.Equal(t, 1, len(orders))
+	form, _ := url.ParseQuery(string(orders[0].Body))
+	assert.Equal(t, "XBTUSDT", form.Get("pair"))
+}
+
+func TestKraken_errors(t *testing.T) {
+	fixtures := loadFixtures(t, "kraken")
+	fixtures["/0/private/AddOrder"] = []markettest.Response{
+		{Body: json.RawMessage(`{"error":["EAPI:Rate limit exceeded"]}`)},
+		{Body: json.RawMessage(`{"error":["EOrder:Insufficient funds"]}`)},
+	}
+	k, _ := newKraken(t, fixtures)
+
+	// Rate limits are transient and rejected, so the order may be retried.
+	_, err := k.Sell(context.Background(), "BTCUSDT", 1)
+	transient, rejected := market.ClassifyError(err)
+	assert.True(t, transient)
+	assert.True(t, rejected)
+
+	_, err = k.Sell(context.Background(), "BTCUSDT", 1)
+	assert.EqualError(t, err, "EOrder:Insufficient funds")
+	transient, _ = market.ClassifyError(err)
+	assert.False(t, transient)
+}
@@ -0,0 +1,472 @@
+package market
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ensures KuCoin implements the Market interface.
+var _ Market = (*KuCoin)(nil)
+
+const kucoinBaseURL = "https://api.kucoin.com"
+
+// kucoinRequestLimit is the maximum number of requests to send to KuCoin per minute.
+// KuCoin limits the total weight of requests per 30 seconds, which most endpoints count a few times.
+const kucoinRequestLimit = 1200
+
+// kucoinSuccessCode is the code of successful KuCoin responses.
+const kucoinSuccessCode = "200000"
+
+// kucoinSymbolNotFoundCode is the code of KuCoin errors for symbols that don't exist.
+const kucoinSymbolNotFoundCode = "900001"
+
+// kucoinOrderPollInterval is the interval at which the status of a placed order is checked until it's done.
+const kucoinOrderPollInterval = 500 * time.Millisecond
+
+// kucoinOrderTimeout is how long to wait for a market order to be done, after which it's returned as is.
+const kucoinOrderTimeout = 30 * time.Second
+
+// kucoinTransientErrorCodes maps the codes of KuCoin errors that are expected to resolve themselves to whether the request was rejected.
+// See https://www.kucoin.com/docs/basic-info/request-rate-limit/rest-api.
+var kucoinTransientErrorCodes = map[string]bool{
+	"429000": true,  // too many requests
+	"500000": false, // internal server error
+}
+
+// KuCoin is a market for the KuCoin spot exchange.
+// KuCoin separates the base and quote currency in its symbols (e.g. BTC-USDT), so they are converted to the concatenated symbols used by all other markets (e.g. BTCUSDT).
+// KuCoin identifies orders by a string ID, which doesn't fit in Order.OrderID, so it's left empty.
+type KuCoin struct {
+	config            config.Configuration
+	client            *http.Client
+	baseURL           string
+	orderPollInterval time.Duration
+
+	// The symbols by concatenated symbol, refreshed by every request for all of them.
+	mu      sync.Mutex
+	symbols map[string]kucoinSymbol
+}
+
+// kucoinSymbol is a trading pair as returned by KuCoin.
+type kucoinSymbol struct {
+	Symbol         string `json:"symbol"`
+	BaseCurrency   string `json:"baseCurrency"`
+	QuoteCurrency  string `json:"quoteCurrency"`
+	BaseMinSize    string `json:"baseMinSize"`
+	BaseMaxSize    string `json:"baseMaxSize"`
+	BaseIncrement  string `json:"baseIncrement"`
+	QuoteIncrement string `json:"quoteIncrement"`
+	PriceIncrement string `json:"priceIncrement"`
+	MinFunds       string `json:"minFunds"`
+	EnableTrading  bool   `json:"enableTrading"`
+}
+
+// kucoinOrder is an order as returned by KuCoin.
+type kucoinOrder struct {
+	IsActive    bool   `json:"isActive"`
+	CancelExist bool   `json:"cancelExist"`
+	Size        string `json:"size"`
+
+	// The filled quantity and its value in the quote currency, excluding fees.
+	DealSize  string `json:"dealSize"`
+	DealFunds string `json:"dealFunds"`
+
+	Fee         string `json:"fee"`
+	FeeCurrency string `json:"feeCurrency"`
+
+	// The time the order was created, in milliseconds since the unix epoch.
+	CreatedAt int64 `json:"createdAt"`
+}
+
+func NewKuCoin(config config.Configuration) *KuCoin {
+	return &KuCoin{
+		config: config,
+		client: &http.Client{
+			Transport: serverErrorTransport{
+				transport: newRateLimiter(http.DefaultTransport, clock.Real{}, kucoinRequestLimit, nil, ""),
+			},
+		},
+		baseURL:           kucoinBaseURL,
+		orderPollInterval: kucoinOrderPollInterval,
+	}
+}
+
+func (k *KuCoin) Name() string {
+	return "kucoin"
+}
+
+// kucoinSymbolName converts the given KuCoin symbol to a symbol.
+func kucoinSymbolName(symbol string) string {
+	return strings.ReplaceAll(symbol, "-", "")
+}
+
+func (k *KuCoin) GetCoins(ctx context.Context) (Coins, error) {
+	var res struct {
+		Ticker []struct {
+			Symbol   string `json:"symbol"`
+			Last     string `json:"last"`
+			VolValue string `json:"volValue"`
+		} `json:"ticker"`
+	}
+	if err := k.do(ctx, http.MethodGet, "/api/v1/market/allTickers", nil, nil, &res); err != nil {
+		return nil, err
+	}
+
+	// Whether trading is enabled is only known from the symbols, which are fetched again when a ticker belongs to a symbol listed since they were fetched last.
+	symbols, err := k.loadSymbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ticker := range res.Ticker {
+		if _, ok := symbols[kucoinSymbolName(ticker.Symbol)]; !ok {
+			if symbols, err = k.fetchSymbols(ctx); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	coins := make(Coins, len(res.Ticker))
+	now := time.Now()
+
+	for _, ticker := range res.Ticker {
+		// Symbols that aren't trading don't have a last price.
+		price, err := strconv.ParseFloat(ticker.Last, 64)
+		if err != nil {
+			continue
+		}
+		volume, _ := strconv.ParseFloat(ticker.VolValue, 64)
+
+		symbol := kucoinSymbolName(ticker.Symbol)
+		coins[symbol] = Coin{
+			Symbol:            symbol,
+			Price:             price,
+			QuoteVolumeTraded: volume,
+			Time:              now,
+			Halted:            !symbols[symbol].EnableTrading,
+		}
+	}
+
+	return coins, nil
+}
+
+func (k *KuCoin) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
+	volumeMap := make(TradeVolumes)
+	if k.config.TradingOptions.MinQuoteVolumeTraded != 0.0 {
+		coins, err := k.GetCoins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for symbol, coin := range coins {
+			volumeMap[symbol] = coin.QuoteVolumeTraded
+		}
+	}
+	return volumeMap, nil
+}
+
+func (k *KuCoin) GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error) {
+	s, err := k.getSymbol(ctx, symbol)
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+
+	return s.toSymbolInfo(), nil
+}
+
+func (k *KuCoin) GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error) {
+	symbols, err := k.fetchSymbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := make([]SymbolInfo, 0, len(symbols))
+	for _, s := range symbols {
+		info = append(info, s.toSymbolInfo())
+	}
+
+	return info, nil
+}
+
+// toSymbolInfo converts the given KuCoin symbol to a SymbolInfo.
+func (s kucoinSymbol) toSymbolInfo() SymbolInfo {
+	info := SymbolInfo{
+		Symbol: s.BaseCurrency + s.QuoteCurrency,
+		Halted: !s.EnableTrading,
+	}
+	info.StepSize, _ = strconv.ParseFloat(s.BaseIncrement, 64)
+	info.MinQuantity, _ = strconv.ParseFloat(s.BaseMinSize, 64)
+	info.MaxQuantity, _ = strconv.ParseFloat(s.BaseMaxSize, 64)
+	info.TickSize, _ = strconv.ParseFloat(s.PriceIncrement, 64)
+	info.MinNotional, _ = strconv.ParseFloat(s.MinFunds, 64)
+
+	if info.StepSize > 0 {
+		info.BaseAssetPrecision = int(math.Round(-math.Log10(info.StepSize)))
+	}
+	if quoteIncrement, _ := strconv.ParseFloat(s.QuoteIncrement, 64); quoteIncrement > 0 {
+		info.QuoteAssetPrecision = int(math.Round(-math.Log10(quoteIncrement)))
+	}
+
+	return info
+}
+
+// fetchSymbols fetches all symbols from KuCoin and returns them by concatenated symbol.
+func (k *KuCoin) fetchSymbols(ctx context.Context) (map[string]kucoinSymbol, error) {
+	var res []kucoinSymbol
+	if err := k.do(ctx, http.MethodGet, "/api/v2/symbols", nil, nil, &res); err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string]kucoinSymbol, len(res))
+	for _, s := range res {
+		symbols[s.BaseCurrency+s.QuoteCurrency] = s
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.symbols = symbols
+
+	return symbols, nil
+}
+
+// loadSymbols returns the cached symbols by concatenated symbol, fetching them if they haven't been fetched yet.
+func (k *KuCoin) loadSymbols(ctx context.Context) (map[string]kucoinSymbol, error) {
+	k.mu.Lock()
+	symbols := k.symbols
+	k.mu.Unlock()
+
+	if symbols == nil {
+		return k.fetchSymbols(ctx)
+	}
+
+	return symbols, nil
+}
+
+// getSymbol returns the KuCoin symbol of the given symbol, fetching all symbols if they haven't been fetched yet.
+func (k *KuCoin) getSymbol(ctx context.Context, symbol string) (kucoinSymbol, error) {
+	symbols, err := k.loadSymbols(ctx)
+	if err != nil {
+		return kucoinSymbol{}, err
+	}
+
+	s, ok := symbols[symbol]
+	if !ok {
+		return kucoinSymbol{}, SymbolNotFoundError
+	}
+
+	return s, nil
+}
+
+// GetBalances returns the balances of the trading account, which is the only account KuCoin trades from.
+func (k *KuCoin) GetBalances(ctx context.Context) (Balances, error) {
+	var res []struct {
+		Currency  string `json:"currency"`
+		Available string `json:"available"`
+		Holds     string `json:"holds"`
+	}
+	if err := k.do(ctx, http.MethodGet, "/api/v1/accounts", url.Values{"type": {"trade"}}, nil, &res); err != nil {
+		return nil, err
+	}
+
+	balances := make(Balances, len(res))
+	for _, account := range res {
+		free, _ := strconv.ParseFloat(account.Available, 64)
+		locked, _ := strconv.ParseFloat(account.Holds, 64)
+		if free == 0 && locked == 0 {
+			continue
+		}
+		balances[account.Currency] = Balance{
+			Asset:  account.Currency,
+			Free:   free,
+			Locked: locked,
+		}
+	}
+
+	return balances, nil
+}
+
+func (k *KuCoin) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return k.executeOrder(ctx, coin, quantity, "buy")
+}
+
+func (k *KuCoin) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return k.executeOrder(ctx, coin, quantity, "sell")
+}
+
+// executeOrder places a market order for the given quantity of the given coin and waits for it to be done.
+func (k *KuCoin) executeOrder(ctx context.Context, coin string, quantity float64, side string) (Order, error) {
+	s, err := k.getSymbol(ctx, coin)
+	if err != nil {
+		return Order{}, err
+	}
+
+	body := map[string]string{
+		"clientOid": uuid.NewString(),
+		"side":      side,
+		"symbol":    s.Symbol,
+		"type":      "market",
+		"size":      strconv.FormatFloat(quantity, 'f', -1, 64),
+	}
+	var created struct {
+		OrderID string `json:"orderId"`
+	}
+	if err = k.do(ctx, http.MethodPost, "/api/v1/orders", nil, body, &created); err != nil {
+		return Order{}, err
+	}
+
+	deadline := time.Now().Add(kucoinOrderTimeout)
+	for {
+		var o kucoinOrder
+		if err = k.do(ctx, http.MethodGet, "/api/v1/orders/"+created.OrderID, nil, nil, &o); err != nil {
+			return Order{}, err
+		}
+
+		if !o.IsActive || time.Now().After(deadline) {
+			return o.toOrder(coin), nil
+		}
+
+		if err = sleep(ctx, k.orderPollInterval); err != nil {
+			return Order{}, err
+		}
+	}
+}
+
+// toOrder converts the given KuCoin order of the given coin to an Order.
+func (o kucoinOrder) toOrder(coin string) Order {
+	order := Order{
+		Symbol:          coin,
+		TransactionTime: time.UnixMilli(o.CreatedAt),
+	}
+	size, _ := strconv.ParseFloat(o.Size, 64)
+	order.FilledQuantity, _ = strconv.ParseFloat(o.DealSize, 64)
+	order.CumulativeQuoteQuantity, _ = strconv.ParseFloat(o.DealFunds, 64)
+	if order.FilledQuantity > 0 {
+		order.Price = order.CumulativeQuoteQuantity / order.FilledQuantity
+	}
+	if fee, _ := strconv.ParseFloat(o.Fee, 64); fee != 0 {
+		order.Commissions.Add(o.FeeCurrency, fee)
+	}
+
+	switch {
+	case order.FilledQuantity > 0 && order.FilledQuantity >= size:
+		order.Status = FilledOrderStatus
+	case order.FilledQuantity > 0:
+		order.Status = PartiallyFilledOrderStatus
+	case !o.IsActive:
+		order.Status = CanceledOrderStatus
+	default:
+		order.Status = NewOrderStatus
+	}
+
+	return order
+}
+
+// PlaceOCO always returns NotSupportedError, because KuCoin only supports OCO orders through a separate API.
+func (k *KuCoin) PlaceOCO(_ context.Context, _ string, _, _, _ float64) (OCO, error) {
+	return OCO{}, NotSupportedError
+}
+
+func (k *KuCoin) CancelOCO(_ context.Context, _ OCO) error {
+	return NotSupportedError
+}
+
+func (k *KuCoin) GetOCOFill(_ context.Context, _ OCO) (Order, bool, error) {
+	return Order{}, false, NotSupportedError
+}
+
+// do sends a signed request with the given query parameters and JSON body to the given path and decodes the data of the response into the given value.
+// Public endpoints accept signed requests too, so all requests are signed.
+func (k *KuCoin) do(ctx context.Context, method, path string, params url.Values, body any, result any) error {
+	endpoint := path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, k.baseURL+endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	k.sign(req, endpoint, reqBody, time.Now())
+
+	res, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err = json.Unmarshal(raw, &envelope); err != nil {
+		return fmt.Errorf("%s %s: %s: %w", method, path, res.Status, err)
+	}
+	if envelope.Code != kucoinSuccessCode {
+		return kucoinError(envelope.Code, envelope.Msg)
+	}
+
+	return json.Unmarshal(envelope.Data, result)
+}
+
+// sign adds the headers that authenticate the given request to KuCoin.
+// See https://www.kucoin.com/docs/basic-info/connection-method/authentication/signing-a-message.
+func (k *KuCoin) sign(req *http.Request, endpoint string, body []byte, now time.Time) {
+	c := k.config.Markets.KuCoin
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+
+	req.Header.Set("KC-API-KEY", c.APIKey)
+	req.Header.Set("KC-API-TIMESTAMP", timestamp)
+	req.Header.Set("KC-API-SIGN", kucoinSignature(c.SecretKey, timestamp+req.Method+endpoint+string(body)))
+	req.Header.Set("KC-API-PASSPHRASE", kucoinSignature(c.SecretKey, c.Passphrase))
+	req.Header.Set("KC-API-KEY-VERSION", "2")
+}
+
+// kucoinSignature signs the given message with the given secret.
+func kucoinSignature(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// kucoinError converts the given KuCoin error to an error, marking it as transient if KuCoin reports it as such.
+func kucoinError(code, msg string) error {
+	err := errors.New(code + ": " + msg)
+	if code == kucoinSymbolNotFoundCode {
+		return fmt.Errorf("%w: %s", SymbolNotFoundError, err)
+	}
+	if rejected, ok := kucoinTransientErrorCodes[code]; ok {
+		return &TransientError{Err: err, Rejected: rejected}
+	}
+	return err
+}
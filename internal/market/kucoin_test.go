@@ -0,0 +1,102 @@
+package market_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/market/markettest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newKuCoin returns a KuCoin client that sends its requests to a server answering them with the given fixtures.
+// The behavior KuCoin shares with all other markets is covered by the conformance suite, so only what's specific to KuCoin is tested here.
+func newKuCoin(t *testing.T, fixtures markettest.Fixtures) (*market.KuCoin, *markettest.Server) {
+	s := markettest.NewServer(t, fixtures)
+	k := market.NewKuCoin(config.Configuration{
+		Markets: config.Markets{
+			KuCoin: config.KuCoin{APIKey: "api key", SecretKey: "secret", Passphrase: "passphrase"},
+		},
+	})
+	market.SetBaseURL(k, s.URL)
+	return k, s
+}
+
+func TestKuCoin_signing(t *testing.T) {
+	k, s := newKuCoin(t, loadFixtures(t, "kucoin"))
+
+	_, err := k.GetBalances(context.Background())
+	assert.NoError(t, err)
+	_, err = k.Buy(context.Background(), "PEPEUSDT", 1000000)
+	assert.NoError(t, err)
+
+	// Every request is signed with the API key and secret, including the passphrase.
+	requests := s.Requests("")
+	assert.GreaterOrEqual(t, len(requests), 3)
+	for _, r := range requests {
+		timestamp := r.Header.Get("KC-API-TIMESTAMP")
+		assert.Equal(t, "api key", r.Header.Get("KC-API-KEY"))
+		assert.Equal(t, "2", r.Header.Get("KC-API-KEY-VERSION"))
+		assert.Equal(t, market.KuCoinSignature("secret", "passphrase"), r.Header.Get("KC-API-PASSPHRASE"))
+		assert.Equal(t, market.KuCoinSignature("secret", timestamp+r.Method+r.URL.RequestURI()+string(r.Body)), r.Header.Get("KC-API-SIGN"))
+	}
+}
+
+func TestKuCoin_symbols(t *testing.T) {
+	fixtures := loadFixtures(t, "kucoin")
+	// NEW-USDT is priced before trading is enabled, and PEPE-USDT is listed after the symbols were fetched first.
+	tickers := fixtures["/api/v1/market/allTickers"][0]
+	tickers.Body = json.RawMessage(strings.Replace(string(tickers.Body), `"last": null`, `"last": "0.5"`, 1))
+	fixtures["/api/v1/market/allTickers"] = []markettest.Response{tickers}
+	symbols := fixtures["/api/v2/symbols"][0]
+	unlisted := markettest.Response{Body: json.RawMessage(strings.ReplaceAll(string(symbols.Body), "PEPE", "OLD"))}
+	fixtures["/api/v2/symbols"] = []markettest.Response{unlisted, symbols}
+	k, s := newKuCoin(t, fixtures)
+
+	// Symbols can't be looked up by their KuCoin name.
+	_, err := k.GetSymbolInfo(context.Background(), "BTC-USDT")
+	assert.ErrorIs(t, err, market.SymbolNotFoundError)
+
+	// Symbols are named like on all other markets, and the symbols are fetched again for the new listing.
+	coins, err := k.GetCoins(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(coins))
+	for _, symbol := range []string{"BTCUSDT", "PEPEUSDT", "NEWUSDT"} {
+		assert.Contains(t, coins, symbol)
+	}
+	assert.Equal(t, 2, len(s.Requests("/api/v2/symbols")))
+	assert.True(t, coins["NEWUSDT"].Halted)
+	assert.False(t, coins["PEPEUSDT"].Halted)
+
+	// Orders are placed with the KuCoin name of the symbol.
+	_, err = k.Buy(context.Background(), "PEPEUSDT", 1000000)
+	assert.NoError(t, err)
+	requests := s.Requests("/api/v1/orders")
+	assert.Equal(t, 1, len(requests))
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(requests[0].Body, &body))
+	assert.Equal(t, "PEPE-USDT", body["symbol"])
+}
+
+func TestKuCoin_errors(t *testing.T) {
+	fixtures := loadFixtures(t, "kucoin")
+	fixtures["/api/v1/orders"] = []markettest.Response{
+		{Status: http.StatusTooManyRequests, Body: json.RawMessage(`{"code":"429000","msg":"Too Many Requests"}`)},
+		{Body: json.RawMessage(`{"code":"200004","msg":"Balance insufficient!"}`)},
+	}
+	k, _ := newKuCoin(t, fixtures)
+
+	// Rate limits are transient and rejected, so the order may be retried.
+	_, err := k.Sell(context.Background(), "BTCUSDT", 1)
+	transient, rejected := market.ClassifyError(err)
+	assert.True(t, transient)
+	assert.True(t, rejected)
+
+	_, err = k.Sell(context.Background(), "BTCUSDT", 1)
+	assert.EqualError(t, err, "200004: Balance insufficient!")
+	transient, _ = market.ClassifyError(err)
+	assert.False(t, transient)
+}
@@ -20,9 +20,27 @@ type Market interface {
 	// GetSymbolInfo returns the symbol info for the given symbol.
 	GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error)
 
+	// GetSymbolsInfo returns the symbol info for all symbols on the market.
+	GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error)
+
+	// GetBalances returns the balances of all assets held in the account on the market.
+	// Assets with a zero balance may be omitted.
+	GetBalances(ctx context.Context) (Balances, error)
+
 	// Buy buys the given quantity of the given coin.
 	Buy(ctx context.Context, coin string, quantity float64) (Order, error)
 
 	// Sell sells the given quantity of the given coin.
 	Sell(ctx context.Context, coin string, quantity float64) (Order, error)
+
+	// PlaceOCO places an OCO order to sell the given quantity of the given coin at the take profit or stop loss price, whichever is reached first.
+	// Returns NotSupportedError if the market doesn't support OCO orders.
+	PlaceOCO(ctx context.Context, coin string, quantity, takeProfit, stopLoss float64) (OCO, error)
+
+	// CancelOCO cancels both orders of the given OCO.
+	CancelOCO(ctx context.Context, oco OCO) error
+
+	// GetOCOFill returns the order with which the given OCO was filled, or false if neither of its orders has been filled yet.
+	// Returns OCOCanceledError if the OCO has been cancelled without being filled, e.g. manually.
+	GetOCOFill(ctx context.Context, oco OCO) (Order, bool, error)
 }
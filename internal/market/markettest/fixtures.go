@@ -0,0 +1,180 @@
+package markettest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Fixtures maps requests to the responses that were recorded for them.
+//
+// Keys are request paths, optionally followed by query parameters that the request must have, e.g. `/api/v3/exchangeInfo?symbol=BTCUSDT`.
+// The key with the most matching query parameters wins, so that a path can be answered differently per symbol.
+// Requests with multiple responses are answered with them in order, repeating the last one.
+type Fixtures map[string][]Response
+
+// Response is a recorded HTTP response.
+type Response struct {
+	// The status code of the response. Defaults to 200 OK.
+	Status int `json:"status"`
+
+	// The JSON body of the response.
+	Body json.RawMessage `json:"body"`
+}
+
+// LoadFixtures reads the fixtures from the given JSON file, failing the test if it can't be read.
+func LoadFixtures(t testing.TB, path string) Fixtures {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %s", err)
+	}
+
+	var fixtures Fixtures
+	if err = json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("failed to parse fixtures %s: %s", path, err)
+	}
+
+	return fixtures
+}
+
+// Server is a server that answers requests with fixtures and records the requests it received.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+
+	// The raw body of the request.
+	Body []byte
+}
+
+// NewServer starts a server that answers every request with the matching fixture.
+// Requests that don't match any fixture fail the test and are answered with 404 Not Found.
+// Authentication isn't checked, because that's up to the tests of each market.
+func NewServer(t testing.TB, fixtures Fixtures) *Server {
+	s := &Server{}
+	served := make(map[string]int)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, URL: r.URL, Header: r.Header.Clone(), Body: body})
+		s.mu.Unlock()
+
+		key, ok := fixtures.match(r.URL)
+		if !ok {
+			t.Errorf("no fixture for %s %s", r.Method, r.URL.RequestURI())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		s.mu.Lock()
+		responses := fixtures[key]
+		res := responses[min(served[key], len(responses)-1)]
+		served[key]++
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if res.Status == http.StatusTooManyRequests {
+			// Don't make the rate limiter of the market back off for the rest of the test.
+			w.Header().Set("Retry-After", "0")
+		}
+		if res.Status != 0 {
+			w.WriteHeader(res.Status)
+		}
+		_, _ = w.Write(res.Body)
+	}))
+	t.Cleanup(s.Server.Close)
+
+	return s
+}
+
+// Requests returns the requests for the given path the server received so far, in order.
+// An empty path returns all of them.
+func (s *Server) Requests(path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []Request
+	for _, r := range s.requests {
+		if path == "" || r.URL.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// NewHangingServer starts a server that never answers, until the request is cancelled or the test ends.
+func NewHangingServer(t testing.TB) *httptest.Server {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+
+	return server
+}
+
+// match returns the key of the fixture that best matches the given request URL.
+func (f Fixtures) match(u *url.URL) (string, bool) {
+	query := u.Query()
+	best, bestParams := "", -1
+
+	for key, responses := range f {
+		if len(responses) == 0 {
+			continue
+		}
+
+		path, rawQuery, _ := strings.Cut(key, "?")
+		if path != u.Path {
+			continue
+		}
+
+		params, err := url.ParseQuery(rawQuery)
+		if err != nil || !contains(query, params) {
+			continue
+		}
+
+		if len(params) > bestParams {
+			best, bestParams = key, len(params)
+		}
+	}
+
+	return best, bestParams >= 0
+}
+
+// contains reports whether query has all the given params.
+func contains(query, params url.Values) bool {
+	for name, values := range params {
+		for _, value := range values {
+			if !slices.Contains(query[name], value) {
+				return false
+			}
+		}
+	}
+	return true
+}
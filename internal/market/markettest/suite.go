@@ -0,0 +1,145 @@
+// Package markettest provides a conformance test suite that every market.Market implementation is expected to pass.
+// The suite runs against recorded HTTP responses, so it doesn't need network access or API keys.
+package markettest
+
+import (
+	"context"
+	"errors"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// UnknownSymbol is a symbol that doesn't exist on any market.
+// The fixtures of a market must answer requests for it the way the market answers requests for unknown symbols.
+const UnknownSymbol = "UNKNOWNUSDT"
+
+// cancelTimeout is how long a market may take to return after its context is cancelled.
+const cancelTimeout = 5 * time.Second
+
+// namePattern is the pattern the name of every market must match, because it's stored alongside every order in the database.
+var namePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// Suite is the conformance test suite for a market.
+type Suite struct {
+	// The name the market is expected to report.
+	Name string
+
+	// NewMarket returns the market under test, sending all its HTTP requests to the given base URL.
+	NewMarket func(t *testing.T, baseURL string) market.Market
+
+	// The recorded responses to answer the requests of the market with.
+	Fixtures Fixtures
+
+	// A symbol that exists in the fixtures, and the quantity of it to buy and sell.
+	Symbol   string
+	Quantity float64
+}
+
+// Run runs all tests of the suite as subtests of t.
+// Each subtest gets its own server, so the fixtures are served from the start again.
+func (s Suite) Run(t *testing.T) {
+	t.Run("Name", s.testName)
+	t.Run("GetCoins", s.testGetCoins)
+	t.Run("GetSymbolInfo", s.testGetSymbolInfo)
+	t.Run("GetSymbolInfo unknown symbol", s.testGetSymbolInfoUnknown)
+	t.Run("Buy", func(t *testing.T) {
+		s.testOrder(t, s.newMarket(t).Buy)
+	})
+	t.Run("Sell", func(t *testing.T) {
+		s.testOrder(t, s.newMarket(t).Sell)
+	})
+	t.Run("context cancellation", s.testCancel)
+}
+
+// newMarket returns the market under test, answering its requests with the fixtures.
+func (s Suite) newMarket(t *testing.T) market.Market {
+	return s.NewMarket(t, NewServer(t, s.Fixtures).URL)
+}
+
+// testName checks that the name is stable, because it's used to tell the orders of different markets apart in the database.
+func (s Suite) testName(t *testing.T) {
+	m := s.newMarket(t)
+	assert.Equal(t, s.Name, m.Name())
+	assert.Equal(t, m.Name(), m.Name())
+	assert.Regexp(t, namePattern, m.Name())
+}
+
+func (s Suite) testGetCoins(t *testing.T) {
+	coins, err := s.newMarket(t).GetCoins(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotEmpty(t, coins)
+	assert.Contains(t, coins, s.Symbol)
+	for symbol, coin := range coins {
+		assert.Equal(t, symbol, coin.Symbol)
+		assert.Greater(t, coin.Price, 0.0, symbol)
+		assert.False(t, coin.Time.IsZero(), symbol)
+	}
+}
+
+func (s Suite) testGetSymbolInfo(t *testing.T) {
+	info, err := s.newMarket(t).GetSymbolInfo(context.Background(), s.Symbol)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, s.Symbol, info.Symbol)
+	assert.Greater(t, info.StepSize, 0.0)
+}
+
+func (s Suite) testGetSymbolInfoUnknown(t *testing.T) {
+	_, err := s.newMarket(t).GetSymbolInfo(context.Background(), UnknownSymbol)
+	assert.ErrorIs(t, err, market.SymbolNotFoundError)
+}
+
+func (s Suite) testOrder(t *testing.T, execute func(ctx context.Context, coin string, quantity float64) (market.Order, error)) {
+	order, err := execute(context.Background(), s.Symbol, s.Quantity)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, s.Symbol, order.Symbol)
+	assert.Greater(t, order.Price, 0.0)
+	assert.Greater(t, order.FilledQuantity, 0.0)
+}
+
+// testCancel checks that requests are abandoned as soon as their context is cancelled, so the bot can shut down while the market is unresponsive.
+func (s Suite) testCancel(t *testing.T) {
+	m := s.NewMarket(t, NewHangingServer(t).URL)
+
+	calls := map[string]func(ctx context.Context) error{
+		"GetCoins": func(ctx context.Context) error {
+			_, err := m.GetCoins(ctx)
+			return err
+		},
+		"GetSymbolInfo": func(ctx context.Context) error {
+			_, err := m.GetSymbolInfo(ctx, s.Symbol)
+			return err
+		},
+		"Buy": func(ctx context.Context) error {
+			_, err := m.Buy(ctx, s.Symbol, s.Quantity)
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- call(ctx)
+		}()
+
+		select {
+		case err := <-errs:
+			assert.True(t, errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled), "%s: %v", name, err)
+		case <-time.After(cancelTimeout):
+			t.Errorf("%s: still running %s after the context was cancelled", name, cancelTimeout)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,22 @@
+package market
+
+import "errors"
+
+var NotSupportedError = errors.New("not supported by the market")
+
+var OCOCanceledError = errors.New("OCO has been cancelled")
+
+// OCO is a one-cancels-the-other order, i.e. a take profit limit order and a stop loss order to sell the same coin.
+// When either of them is filled, the market cancels the other one.
+type OCO struct {
+	// The ID of the order list on the market.
+	OrderListID int64
+
+	Symbol string
+
+	// The ID of the take profit limit order.
+	TakeProfitOrderID int64
+
+	// The ID of the stop loss order.
+	StopLossOrderID int64
+}
@@ -2,9 +2,52 @@ package market
 
 import "time"
 
+type OrderStatus string
+
+const (
+	// The order has been accepted, but nothing has been filled yet.
+	NewOrderStatus OrderStatus = "NEW"
+
+	// Part of the order has been filled.
+	// Also used for orders that were cancelled after they were partially filled.
+	PartiallyFilledOrderStatus OrderStatus = "PARTIALLY_FILLED"
+
+	// The order has been filled completely.
+	FilledOrderStatus OrderStatus = "FILLED"
+
+	// The order has been cancelled before anything was filled.
+	CanceledOrderStatus OrderStatus = "CANCELED"
+)
+
 type Order struct {
 	OrderID         int64
 	TransactionTime time.Time
 	Symbol          string
-	Price           float64
+
+	// The average price at which the order was filled.
+	Price float64
+
+	// The status of the order.
+	Status OrderStatus
+
+	// The executed quantity, i.e. what was actually bought or sold.
+	// This may be less than the requested quantity if the order was partially filled.
+	FilledQuantity float64
+
+	// The total amount of the quote asset that was spent or received for the filled quantity, excluding commissions.
+	CumulativeQuoteQuantity float64
+
+	// The trading fees that were paid for the filled quantity.
+	Commissions Commissions `gorm:"serializer:json"`
+}
+
+// Commissions maps each asset in which trading fees were paid (e.g. USDT or BNB) to the total amount that was paid in it.
+type Commissions map[string]float64
+
+// Add adds the given amount of the given asset to the commissions, initializing them if needed.
+func (c *Commissions) Add(asset string, amount float64) {
+	if *c == nil {
+		*c = make(Commissions)
+	}
+	(*c)[asset] += amount
 }
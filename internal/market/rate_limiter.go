@@ -0,0 +1,147 @@
+package market
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default backoff periods when the exchange doesn't send a Retry-After header.
+const (
+	// HTTP 429 means the rate limit has been exceeded, which resets at the start of the next window.
+	rateLimitedBackoff = time.Minute
+
+	// HTTP 418 means the IP has been banned for repeatedly exceeding the rate limit, which lasts at least 2 minutes.
+	bannedBackoff = 2 * time.Minute
+)
+
+// rateLimiter is an HTTP transport that keeps the total weight of all requests sent per minute below the given limit.
+// Requests that would exceed the limit are delayed until the next minute.
+// When the exchange responds with HTTP 429 or 418 anyway, all requests are delayed until the exchange allows them again.
+type rateLimiter struct {
+	transport http.RoundTripper
+	clock     clock.Clock
+
+	// The maximum total weight of all requests per minute.
+	limit int
+
+	// The weight per URL path. Paths that are not listed weigh 1.
+	weights map[string]int
+
+	// The name of the response header in which the exchange reports the weight used in the current minute.
+	usedWeightHeader string
+
+	mu           sync.Mutex
+	window       time.Time
+	used         int
+	backoffUntil time.Time
+}
+
+func newRateLimiter(transport http.RoundTripper, clock clock.Clock, limit int, weights map[string]int, usedWeightHeader string) *rateLimiter {
+	return &rateLimiter{
+		transport:        transport,
+		clock:            clock,
+		limit:            limit,
+		weights:          weights,
+		usedWeightHeader: usedWeightHeader,
+	}
+}
+
+func (l *rateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	weight, ok := l.weights[req.URL.Path]
+	if !ok {
+		weight = 1
+	}
+
+	for {
+		delay := l.reserve(weight)
+		if delay == 0 {
+			break
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := l.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	l.update(res)
+
+	return res, nil
+}
+
+// reserve adds the given weight to the current window.
+// Returns how long to wait before trying again if the weight doesn't fit in the current window, or 0 if it has been reserved.
+func (l *rateLimiter) reserve(weight int) time.Duration {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.backoffUntil) {
+		return l.backoffUntil.Sub(now)
+	}
+
+	if window := now.Truncate(time.Minute); window.After(l.window) {
+		l.window = window
+		l.used = 0
+	}
+
+	// A single request that weighs more than the limit can never fit, so it's only sent in an otherwise empty window.
+	if l.used > 0 && l.used+weight > l.limit {
+		return l.window.Add(time.Minute).Sub(now)
+	}
+
+	l.used += weight
+
+	return 0
+}
+
+// update synchronizes the limiter with the used weight and rate limit errors reported in the given response.
+func (l *rateLimiter) update(res *http.Response) {
+	now := l.clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The exchange knows best, e.g. when other clients on the same IP are sending requests too.
+	if used, err := strconv.Atoi(res.Header.Get(l.usedWeightHeader)); err == nil && now.Truncate(time.Minute).Equal(l.window) {
+		l.used = max(l.used, used)
+	}
+
+	var backoff time.Duration
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		backoff = rateLimitedBackoff
+	case http.StatusTeapot:
+		backoff = bannedBackoff
+	default:
+		return
+	}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		backoff = time.Duration(seconds) * time.Second
+	}
+
+	if until := now.Add(backoff); until.After(l.backoffUntil) {
+		l.backoffUntil = until
+	}
+}
+
+// sleep waits for the given duration or until the given context is cancelled, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package market
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_reserve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	c := clock.NewSimulated(start)
+	l := newRateLimiter(http.DefaultTransport, c, 10, nil, "X-Used-Weight")
+
+	assert.Equal(t, time.Duration(0), l.reserve(4))
+	assert.Equal(t, time.Duration(0), l.reserve(6))
+
+	// The limit has been reached, so wait until the next minute.
+	assert.Equal(t, 30*time.Second, l.reserve(1))
+
+	c.Advance(30 * time.Second)
+	assert.Equal(t, time.Duration(0), l.reserve(1))
+
+	// A request that weighs more than the limit is sent in an empty window.
+	c.Advance(time.Minute)
+	assert.Equal(t, time.Duration(0), l.reserve(20))
+	assert.Equal(t, time.Minute, l.reserve(1))
+}
+
+func TestRateLimiter_RoundTrip(t *testing.T) {
+	status := http.StatusOK
+	headers := map[string]string{}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewSimulated(start)
+	l := newRateLimiter(http.DefaultTransport, c, 100, map[string]int{"/heavy": 10}, "X-Used-Weight")
+	client := &http.Client{Transport: l}
+
+	get := func(ctx context.Context, path string) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		res, err := client.Do(req)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		return err
+	}
+
+	t.Run("Counts the weight of each request", func(t *testing.T) {
+		assert.NoError(t, get(context.Background(), "/heavy"))
+		assert.NoError(t, get(context.Background(), "/light"))
+		assert.Equal(t, 11, l.used)
+	})
+
+	t.Run("Trusts the used weight reported by the exchange", func(t *testing.T) {
+		headers["X-Used-Weight"] = "95"
+		assert.NoError(t, get(context.Background(), "/light"))
+		assert.Equal(t, 95, l.used)
+		assert.Equal(t, time.Duration(0), l.reserve(5))
+
+		// Doesn't send the request if the context is cancelled while waiting for the next minute.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, get(ctx, "/heavy"), context.Canceled)
+		assert.Equal(t, 3, requests)
+	})
+
+	t.Run("Backs off when rate limited", func(t *testing.T) {
+		c.Advance(time.Minute)
+		headers = map[string]string{"Retry-After": "30"}
+		status = http.StatusTooManyRequests
+		assert.NoError(t, get(context.Background(), "/light"))
+		assert.Equal(t, 30*time.Second, l.reserve(1))
+	})
+
+	t.Run("Backs off when banned", func(t *testing.T) {
+		c.Advance(time.Minute)
+		headers = map[string]string{}
+		status = http.StatusTeapot
+		assert.NoError(t, get(context.Background(), "/light"))
+		assert.Equal(t, bannedBackoff, l.reserve(1))
+	})
+}
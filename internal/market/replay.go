@@ -0,0 +1,196 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"github.com/sleeyax/voltra/internal/clock"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Ensures Replay implements the Market interface.
+var _ Market = (*Replay)(nil)
+
+var NoSnapshotError = errors.New("no snapshot available at the current time")
+
+// Replay is a market that replays previously recorded snapshots.
+// The snapshot that is served is the most recent one at the time reported by the given clock.
+// Orders are always filled at the price of the served snapshot.
+type Replay struct {
+	name      string
+	snapshots []Snapshot
+	clock     clock.Clock
+
+	mu          sync.Mutex
+	lastOrderID int64
+}
+
+// NewReplay creates a new replay of the given snapshots under the given market name.
+func NewReplay(name string, snapshots []Snapshot, clock clock.Clock) *Replay {
+	sorted := slices.Clone(snapshots)
+	slices.SortStableFunc(sorted, func(a, b Snapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+	return &Replay{name: name, snapshots: sorted, clock: clock}
+}
+
+func (r *Replay) Name() string {
+	return r.name
+}
+
+// Start returns the time of the first snapshot.
+func (r *Replay) Start() time.Time {
+	if len(r.snapshots) == 0 {
+		return time.Time{}
+	}
+	return r.snapshots[0].Time
+}
+
+// End returns the time of the last snapshot.
+func (r *Replay) End() time.Time {
+	if len(r.snapshots) == 0 {
+		return time.Time{}
+	}
+	return r.snapshots[len(r.snapshots)-1].Time
+}
+
+// Next returns the time of the first snapshot after the given time.
+func (r *Replay) Next(after time.Time) (time.Time, bool) {
+	i, _ := slices.BinarySearchFunc(r.snapshots, after, func(s Snapshot, t time.Time) int {
+		if s.Time.After(t) {
+			return 1
+		}
+		return -1
+	})
+	if i == len(r.snapshots) {
+		return time.Time{}, false
+	}
+	return r.snapshots[i].Time, true
+}
+
+// current returns the most recent snapshot at the current time of the clock.
+func (r *Replay) current() (Snapshot, error) {
+	now := r.clock.Now()
+	i, _ := slices.BinarySearchFunc(r.snapshots, now, func(s Snapshot, t time.Time) int {
+		if s.Time.After(t) {
+			return 1
+		}
+		return -1
+	})
+	if i == 0 {
+		return Snapshot{}, NoSnapshotError
+	}
+	return r.snapshots[i-1], nil
+}
+
+func (r *Replay) GetCoins(_ context.Context) (Coins, error) {
+	snapshot, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	coins := make(Coins, len(snapshot.Coins))
+	for symbol, coin := range snapshot.Coins {
+		coin.Time = snapshot.Time
+		coins[symbol] = coin
+	}
+
+	return coins, nil
+}
+
+func (r *Replay) GetCoinsVolume(_ context.Context) (TradeVolumes, error) {
+	snapshot, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(TradeVolumes, len(snapshot.Coins))
+	for symbol, coin := range snapshot.Coins {
+		volumes[symbol] = coin.QuoteVolumeTraded
+	}
+
+	return volumes, nil
+}
+
+func (r *Replay) GetSymbolInfo(_ context.Context, symbol string) (SymbolInfo, error) {
+	snapshot, err := r.current()
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+
+	if _, ok := snapshot.Coins[symbol]; !ok {
+		return SymbolInfo{}, SymbolNotFoundError
+	}
+
+	// Recorded snapshots don't contain any exchange filters, so the volume is never rounded.
+	return SymbolInfo{Symbol: symbol}, nil
+}
+
+func (r *Replay) GetSymbolsInfo(_ context.Context) ([]SymbolInfo, error) {
+	snapshot, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]SymbolInfo, 0, len(snapshot.Coins))
+	for symbol := range snapshot.Coins {
+		symbols = append(symbols, SymbolInfo{Symbol: symbol})
+	}
+
+	return symbols, nil
+}
+
+// GetBalances always returns no balances, because replays don't keep track of a wallet.
+func (r *Replay) GetBalances(_ context.Context) (Balances, error) {
+	return Balances{}, nil
+}
+
+func (r *Replay) executeOrder(coin string, quantity float64) (Order, error) {
+	snapshot, err := r.current()
+	if err != nil {
+		return Order{}, err
+	}
+
+	c, ok := snapshot.Coins[coin]
+	if !ok {
+		return Order{}, SymbolNotFoundError
+	}
+
+	r.mu.Lock()
+	r.lastOrderID++
+	orderID := r.lastOrderID
+	r.mu.Unlock()
+
+	return Order{
+		OrderID:                 orderID,
+		Symbol:                  coin,
+		Price:                   c.Price,
+		TransactionTime:         r.clock.Now(),
+		Status:                  FilledOrderStatus,
+		FilledQuantity:          quantity,
+		CumulativeQuoteQuantity: c.Price * quantity,
+	}, nil
+}
+
+// PlaceOCO always returns NotSupportedError, because replays don't keep track of open orders.
+// Wrap the replay in a Simulator to simulate them.
+func (r *Replay) PlaceOCO(_ context.Context, _ string, _, _, _ float64) (OCO, error) {
+	return OCO{}, NotSupportedError
+}
+
+func (r *Replay) CancelOCO(_ context.Context, _ OCO) error {
+	return NotSupportedError
+}
+
+func (r *Replay) GetOCOFill(_ context.Context, _ OCO) (Order, bool, error) {
+	return Order{}, false, NotSupportedError
+}
+
+func (r *Replay) Buy(_ context.Context, coin string, quantity float64) (Order, error) {
+	return r.executeOrder(coin, quantity)
+}
+
+func (r *Replay) Sell(_ context.Context, coin string, quantity float64) (Order, error) {
+	return r.executeOrder(coin, quantity)
+}
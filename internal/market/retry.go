@@ -0,0 +1,136 @@
+package market
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Ensures Retry implements the Market interface.
+var _ Market = (*Retry)(nil)
+
+const (
+	// retryMaxAttempts is the maximum number of attempts per call, including the first one.
+	retryMaxAttempts = 5
+
+	// Delays between attempts, doubled after every failed attempt.
+	retryMinBackoff = 500 * time.Millisecond
+	retryMaxBackoff = 30 * time.Second
+)
+
+// Retry is a market that retries calls to the given market that failed with a transient error, using jittered exponential backoff.
+// Orders are only retried if the market is known to have rejected them, so that they are never executed twice.
+type Retry struct {
+	Market
+	onRetry func(err error, delay time.Duration)
+
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewRetry wraps the given market.
+// The given function is called with the error and the delay before each retry.
+func NewRetry(market Market, onRetry func(err error, delay time.Duration)) *Retry {
+	return &Retry{
+		Market:      market,
+		onRetry:     onRetry,
+		maxAttempts: retryMaxAttempts,
+		minBackoff:  retryMinBackoff,
+		maxBackoff:  retryMaxBackoff,
+	}
+}
+
+func (r *Retry) GetCoins(ctx context.Context) (Coins, error) {
+	return retry(ctx, r, true, func() (Coins, error) {
+		return r.Market.GetCoins(ctx)
+	})
+}
+
+func (r *Retry) GetCoinsVolume(ctx context.Context) (TradeVolumes, error) {
+	return retry(ctx, r, true, func() (TradeVolumes, error) {
+		return r.Market.GetCoinsVolume(ctx)
+	})
+}
+
+func (r *Retry) GetSymbolInfo(ctx context.Context, symbol string) (SymbolInfo, error) {
+	return retry(ctx, r, true, func() (SymbolInfo, error) {
+		return r.Market.GetSymbolInfo(ctx, symbol)
+	})
+}
+
+func (r *Retry) GetSymbolsInfo(ctx context.Context) ([]SymbolInfo, error) {
+	return retry(ctx, r, true, func() ([]SymbolInfo, error) {
+		return r.Market.GetSymbolsInfo(ctx)
+	})
+}
+
+func (r *Retry) GetBalances(ctx context.Context) (Balances, error) {
+	return retry(ctx, r, true, func() (Balances, error) {
+		return r.Market.GetBalances(ctx)
+	})
+}
+
+func (r *Retry) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return retry(ctx, r, false, func() (Order, error) {
+		return r.Market.Buy(ctx, coin, quantity)
+	})
+}
+
+func (r *Retry) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return retry(ctx, r, false, func() (Order, error) {
+		return r.Market.Sell(ctx, coin, quantity)
+	})
+}
+
+func (r *Retry) PlaceOCO(ctx context.Context, coin string, quantity, takeProfit, stopLoss float64) (OCO, error) {
+	return retry(ctx, r, false, func() (OCO, error) {
+		return r.Market.PlaceOCO(ctx, coin, quantity, takeProfit, stopLoss)
+	})
+}
+
+func (r *Retry) CancelOCO(ctx context.Context, oco OCO) error {
+	_, err := retry(ctx, r, true, func() (struct{}, error) {
+		return struct{}{}, r.Market.CancelOCO(ctx, oco)
+	})
+	return err
+}
+
+func (r *Retry) GetOCOFill(ctx context.Context, oco OCO) (Order, bool, error) {
+	type fill struct {
+		order Order
+		ok    bool
+	}
+	f, err := retry(ctx, r, true, func() (fill, error) {
+		order, ok, err := r.Market.GetOCOFill(ctx, oco)
+		return fill{order, ok}, err
+	})
+	return f.order, f.ok, err
+}
+
+// retry calls f until it succeeds, fails with an error that shouldn't be retried, the maximum number of attempts is reached or the given context is cancelled.
+// Calls that aren't idempotent are only retried if the market rejected them.
+func retry[T any](ctx context.Context, r *Retry, idempotent bool, f func() (T, error)) (T, error) {
+	backoff := r.minBackoff
+
+	for attempt := 1; ; attempt++ {
+		v, err := f()
+		if err == nil || attempt >= r.maxAttempts || ctx.Err() != nil {
+			return v, err
+		}
+
+		transient, rejected := classifyError(err)
+		if !transient || (!idempotent && !rejected) {
+			return v, err
+		}
+
+		// Pick a random delay between half and the full backoff, so that concurrent callers don't retry in lockstep.
+		delay := backoff/2 + rand.N(backoff/2+1)
+		r.onRetry(err, delay)
+		if sleep(ctx, delay) != nil {
+			return v, err
+		}
+
+		backoff = min(backoff*2, r.maxBackoff)
+	}
+}
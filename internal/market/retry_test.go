@@ -0,0 +1,117 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+type failingMarket struct {
+	Market
+	errs  []error
+	calls int
+}
+
+func (m *failingMarket) next() error {
+	m.calls++
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+func (m *failingMarket) GetCoins(_ context.Context) (Coins, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	return Coins{"BTCUSDT": Coin{Symbol: "BTCUSDT", Price: 100}}, nil
+}
+
+func (m *failingMarket) Buy(_ context.Context, coin string, _ float64) (Order, error) {
+	if err := m.next(); err != nil {
+		return Order{}, err
+	}
+	return Order{Symbol: coin, Price: 100}, nil
+}
+
+func newTestRetry(m Market) (*Retry, *[]time.Duration) {
+	var delays []time.Duration
+	r := NewRetry(m, func(err error, delay time.Duration) {
+		delays = append(delays, delay)
+	})
+	r.minBackoff = 2 * time.Millisecond
+	r.maxBackoff = 4 * time.Millisecond
+	return r, &delays
+}
+
+func TestRetry(t *testing.T) {
+	dnsErr := &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "api.binance.com"}}
+	timeoutErr := &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}
+	serverErr := &TransientError{Err: errors.New("503 Service Unavailable")}
+	rateLimitErr := &TransientError{Err: errors.New("too many requests"), Rejected: true}
+
+	t.Run("Retries transient errors with exponential backoff", func(t *testing.T) {
+		m := &failingMarket{errs: []error{dnsErr, serverErr, serverErr}}
+		r, delays := newTestRetry(m)
+
+		coins, err := r.GetCoins(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 100.0, coins["BTCUSDT"].Price)
+		assert.Equal(t, 4, m.calls)
+		assert.Equal(t, 3, len(*delays))
+		assert.True(t, (*delays)[0] >= time.Millisecond && (*delays)[0] <= 2*time.Millisecond)
+		assert.True(t, (*delays)[2] >= 2*time.Millisecond && (*delays)[2] <= 4*time.Millisecond)
+	})
+
+	t.Run("Doesn't retry permanent errors", func(t *testing.T) {
+		m := &failingMarket{errs: []error{SymbolNotFoundError}}
+		r, _ := newTestRetry(m)
+
+		_, err := r.GetCoins(context.Background())
+		assert.ErrorIs(t, err, SymbolNotFoundError)
+		assert.Equal(t, 1, m.calls)
+	})
+
+	t.Run("Gives up after the maximum amount of attempts", func(t *testing.T) {
+		m := &failingMarket{errs: []error{serverErr, serverErr, serverErr, serverErr, serverErr, serverErr}}
+		r, _ := newTestRetry(m)
+
+		_, err := r.GetCoins(context.Background())
+		assert.ErrorIs(t, err, serverErr)
+		assert.Equal(t, retryMaxAttempts, m.calls)
+	})
+
+	t.Run("Only retries orders that were rejected", func(t *testing.T) {
+		m := &failingMarket{errs: []error{rateLimitErr, dnsErr, timeoutErr}}
+		r, _ := newTestRetry(m)
+
+		_, err := r.Buy(context.Background(), "BTCUSDT", 1)
+		assert.ErrorIs(t, err, timeoutErr)
+		assert.Equal(t, 3, m.calls)
+
+		m = &failingMarket{errs: []error{serverErr}}
+		r, _ = newTestRetry(m)
+
+		_, err = r.Buy(context.Background(), "BTCUSDT", 1)
+		assert.ErrorIs(t, err, serverErr)
+		assert.Equal(t, 1, m.calls)
+	})
+
+	t.Run("Stops retrying when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		m := &failingMarket{errs: []error{serverErr, serverErr}}
+		r := NewRetry(m, func(err error, delay time.Duration) {
+			cancel()
+		})
+
+		_, err := r.GetCoins(ctx)
+		assert.ErrorIs(t, err, serverErr)
+		assert.Equal(t, 1, m.calls)
+	})
+}
@@ -0,0 +1,324 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/utils"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ensures Simulator implements the Market interface.
+var _ Market = (*Simulator)(nil)
+
+var InsufficientBalanceError = errors.New("insufficient balance")
+
+var InvalidQuantityError = errors.New("invalid quantity")
+
+// Simulator is a market that serves the prices of the given market, but only simulates the execution of orders.
+// Orders are filled at the price after the configured latency plus slippage, charging the taker fee.
+// Quantities that don't match the symbol filters are rejected, just like the real market would.
+// The balances are kept in a virtual wallet that starts with the configured test mode balance.
+// OCO orders are filled when their fill is requested and the price has reached the take profit (charging the maker fee) or the stop loss (with slippage, charging the taker fee).
+type Simulator struct {
+	Market
+	clock clock.Clock
+
+	quoteAsset  string
+	unlimited   bool
+	slippage    float64
+	takerFee    float64
+	makerFee    float64
+	fillLatency time.Duration
+
+	mu          sync.Mutex
+	balances    Balances
+	ocos        map[int64]simulatedOCO
+	lastOrderID int64
+}
+
+// simulatedOCO is an open OCO order of the simulator.
+type simulatedOCO struct {
+	quantity   float64
+	takeProfit float64
+	stopLoss   float64
+}
+
+// NewSimulator wraps the given market.
+// The fill latency is waited for on the given clock.
+func NewSimulator(market Market, c config.Configuration, clock clock.Clock) *Simulator {
+	quoteAsset := c.TradingOptions.PairWith
+	return &Simulator{
+		Market:      market,
+		clock:       clock,
+		quoteAsset:  quoteAsset,
+		unlimited:   c.TestModeBalance == 0,
+		slippage:    c.SimulatorOptions.SlippageBps / 10_000,
+		takerFee:    c.TradingOptions.TradingFeeTaker / 100,
+		makerFee:    c.TradingOptions.TradingFeeMaker / 100,
+		fillLatency: time.Duration(c.SimulatorOptions.FillLatency) * time.Millisecond,
+		balances: Balances{
+			quoteAsset: {Asset: quoteAsset, Free: c.TestModeBalance},
+		},
+		ocos: make(map[int64]simulatedOCO),
+	}
+}
+
+// GetBalances returns the balances of the virtual wallet.
+// An unlimited balance of the quote asset is reported as positive infinity.
+func (s *Simulator) GetBalances(_ context.Context) (Balances, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balances := make(Balances, len(s.balances))
+	for asset, balance := range s.balances {
+		if balance.Free == 0 && balance.Locked == 0 && asset != s.quoteAsset {
+			continue
+		}
+		balances[asset] = balance
+	}
+
+	if s.unlimited {
+		balances[s.quoteAsset] = Balance{Asset: s.quoteAsset, Free: math.Inf(1)}
+	}
+
+	return balances, nil
+}
+
+// Deposit adds the given quantity of the given asset to the free balance of the virtual wallet.
+// This restores the coins of the open positions that were bought before a restart, which would otherwise be missing from the wallet when they're sold.
+func (s *Simulator) Deposit(asset string, quantity float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.transfer(asset, quantity)
+}
+
+func (s *Simulator) Buy(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return s.executeOrder(ctx, coin, quantity, true)
+}
+
+func (s *Simulator) Sell(ctx context.Context, coin string, quantity float64) (Order, error) {
+	return s.executeOrder(ctx, coin, quantity, false)
+}
+
+// executeOrder simulates a market order for the given quantity of the given coin.
+func (s *Simulator) executeOrder(ctx context.Context, coin string, quantity float64, buy bool) (Order, error) {
+	info, err := s.Market.GetSymbolInfo(ctx, coin)
+	if err != nil {
+		return Order{}, err
+	}
+	if err = validateQuantity(info, quantity); err != nil {
+		return Order{}, err
+	}
+
+	s.clock.Sleep(s.fillLatency)
+
+	coins, err := s.Market.GetCoins(ctx)
+	if err != nil {
+		return Order{}, err
+	}
+	c, ok := coins[coin]
+	if !ok {
+		return Order{}, SymbolNotFoundError
+	}
+
+	price := c.Price
+	if buy {
+		price *= 1 + s.slippage
+	} else {
+		price *= 1 - s.slippage
+	}
+	if info.MinNotional != 0 && quantity*price < info.MinNotional {
+		return Order{}, fmt.Errorf("%w: order value %g is below the minimum of %g", InvalidQuantityError, quantity*price, info.MinNotional)
+	}
+
+	baseAsset := strings.TrimSuffix(coin, s.quoteAsset)
+	value := quantity * price
+	fee := value * s.takerFee
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if buy {
+		if err = s.transfer(s.quoteAsset, -(value + fee)); err != nil {
+			return Order{}, err
+		}
+		_ = s.transfer(baseAsset, quantity)
+	} else {
+		if err = s.transfer(baseAsset, -quantity); err != nil {
+			return Order{}, err
+		}
+		_ = s.transfer(s.quoteAsset, value-fee)
+	}
+
+	s.lastOrderID++
+
+	return Order{
+		OrderID:                 s.lastOrderID,
+		Symbol:                  coin,
+		Price:                   price,
+		TransactionTime:         s.clock.Now(),
+		Status:                  FilledOrderStatus,
+		FilledQuantity:          quantity,
+		CumulativeQuoteQuantity: value,
+		Commissions:             Commissions{s.quoteAsset: fee},
+	}, nil
+}
+
+// PlaceOCO locks the given quantity of the coin in the virtual wallet until the OCO is filled or cancelled.
+func (s *Simulator) PlaceOCO(ctx context.Context, coin string, quantity, takeProfit, stopLoss float64) (OCO, error) {
+	info, err := s.Market.GetSymbolInfo(ctx, coin)
+	if err != nil {
+		return OCO{}, err
+	}
+	if err = validateQuantity(info, quantity); err != nil {
+		return OCO{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	baseAsset := strings.TrimSuffix(coin, s.quoteAsset)
+	if err = s.transfer(baseAsset, -quantity); err != nil {
+		return OCO{}, err
+	}
+	s.lock(baseAsset, quantity)
+
+	s.lastOrderID += 3
+	oco := OCO{
+		OrderListID:       s.lastOrderID - 2,
+		Symbol:            coin,
+		TakeProfitOrderID: s.lastOrderID - 1,
+		StopLossOrderID:   s.lastOrderID,
+	}
+	s.ocos[oco.OrderListID] = simulatedOCO{quantity: quantity, takeProfit: takeProfit, stopLoss: stopLoss}
+
+	return oco, nil
+}
+
+func (s *Simulator) CancelOCO(_ context.Context, oco OCO) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.ocos[oco.OrderListID]
+	if !ok {
+		return OCOCanceledError
+	}
+	delete(s.ocos, oco.OrderListID)
+
+	baseAsset := strings.TrimSuffix(oco.Symbol, s.quoteAsset)
+	s.lock(baseAsset, -o.quantity)
+	_ = s.transfer(baseAsset, o.quantity)
+
+	return nil
+}
+
+func (s *Simulator) GetOCOFill(ctx context.Context, oco OCO) (Order, bool, error) {
+	s.mu.Lock()
+	o, ok := s.ocos[oco.OrderListID]
+	s.mu.Unlock()
+	if !ok {
+		return Order{}, false, OCOCanceledError
+	}
+
+	coins, err := s.Market.GetCoins(ctx)
+	if err != nil {
+		return Order{}, false, err
+	}
+	c, ok := coins[oco.Symbol]
+	if !ok {
+		return Order{}, false, SymbolNotFoundError
+	}
+
+	var price, fee float64
+	var orderID int64
+	switch {
+	case c.Price >= o.takeProfit:
+		price = o.takeProfit
+		fee = price * o.quantity * s.makerFee
+		orderID = oco.TakeProfitOrderID
+	case c.Price <= o.stopLoss:
+		price = c.Price * (1 - s.slippage)
+		fee = price * o.quantity * s.takerFee
+		orderID = oco.StopLossOrderID
+	default:
+		return Order{}, false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The OCO may have been cancelled in the meantime.
+	if _, ok = s.ocos[oco.OrderListID]; !ok {
+		return Order{}, false, OCOCanceledError
+	}
+	delete(s.ocos, oco.OrderListID)
+	s.lock(strings.TrimSuffix(oco.Symbol, s.quoteAsset), -o.quantity)
+	_ = s.transfer(s.quoteAsset, price*o.quantity-fee)
+
+	return Order{
+		OrderID:                 orderID,
+		Symbol:                  oco.Symbol,
+		Price:                   price,
+		TransactionTime:         s.clock.Now(),
+		Status:                  FilledOrderStatus,
+		FilledQuantity:          o.quantity,
+		CumulativeQuoteQuantity: price * o.quantity,
+		Commissions:             Commissions{s.quoteAsset: fee},
+	}, true, nil
+}
+
+// lock adds the given amount to the locked balance of the given asset.
+// The caller must hold the lock.
+func (s *Simulator) lock(asset string, amount float64) {
+	balance := s.balances[asset]
+	balance.Asset = asset
+	balance.Locked += amount
+	s.balances[asset] = balance
+}
+
+// transfer adds the given amount to the free balance of the given asset.
+// Returns an error without changing the balance if it would become negative.
+// The caller must hold the lock.
+func (s *Simulator) transfer(asset string, amount float64) error {
+	if asset == s.quoteAsset && s.unlimited {
+		return nil
+	}
+
+	balance := s.balances[asset]
+	if balance.Free+amount < 0 {
+		return fmt.Errorf("%w: %g %s is available, but %g %s is required", InsufficientBalanceError, balance.Free, asset, -amount, asset)
+	}
+
+	balance.Asset = asset
+	balance.Free += amount
+	s.balances[asset] = balance
+
+	return nil
+}
+
+// validateQuantity returns an error if the given quantity doesn't match the quantity filters of the given symbol.
+func validateQuantity(info SymbolInfo, quantity float64) error {
+	if quantity <= 0 {
+		return fmt.Errorf("%w: %g is not positive", InvalidQuantityError, quantity)
+	}
+	if info.MinQuantity != 0 && quantity < info.MinQuantity {
+		return fmt.Errorf("%w: %g is below the minimum of %g", InvalidQuantityError, quantity, info.MinQuantity)
+	}
+	if info.MaxQuantity != 0 && quantity > info.MaxQuantity {
+		return fmt.Errorf("%w: %g is above the maximum of %g", InvalidQuantityError, quantity, info.MaxQuantity)
+	}
+	if info.StepSize != 0 {
+		steps := quantity / info.StepSize
+		if math.Abs(steps-math.Round(steps)) > utils.StepSizeTolerance*math.Max(1, steps) {
+			return fmt.Errorf("%w: %g is not a multiple of the step size %g", InvalidQuantityError, quantity, info.StepSize)
+		}
+	}
+	return nil
+}
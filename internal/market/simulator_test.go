@@ -0,0 +1,182 @@
+package market
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+// symbolInfoFeed is a replay with fixed symbol info, like a live market would have.
+type symbolInfoFeed struct {
+	*Replay
+	info SymbolInfo
+}
+
+func (f symbolInfoFeed) GetSymbolInfo(_ context.Context, _ string) (SymbolInfo, error) {
+	return f.info, nil
+}
+
+func newSimulatorTest(c config.Configuration, info SymbolInfo) (*Simulator, *clock.Simulated) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sim := clock.NewSimulated(start)
+	replay := NewReplay("binance", []Snapshot{
+		{Time: start, Coins: Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: 100}}},
+		{Time: start.Add(time.Second), Coins: Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: 200}}},
+	}, sim)
+	return NewSimulator(symbolInfoFeed{Replay: replay, info: info}, c, sim), sim
+}
+
+func TestSimulator(t *testing.T) {
+	c := config.Configuration{
+		TestModeBalance:  100,
+		SimulatorOptions: config.SimulatorOptions{SlippageBps: 100},
+		TradingOptions:   config.TradingOptions{PairWith: "USDT", TradingFeeTaker: 1},
+	}
+	s, _ := newSimulatorTest(c, SymbolInfo{Symbol: "BTCUSDT", StepSize: 0.1})
+	ctx := context.Background()
+
+	// Buy orders are filled above the price, including the fee.
+	order, err := s.Buy(ctx, "BTCUSDT", 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), order.OrderID)
+	assert.InDelta(t, 101, order.Price, 1e-9)
+	assert.Equal(t, FilledOrderStatus, order.Status)
+	assert.Equal(t, 0.5, order.FilledQuantity)
+	assert.InDelta(t, 50.5, order.CumulativeQuoteQuantity, 1e-9)
+	assert.InDelta(t, 0.505, order.Commissions["USDT"], 1e-9)
+
+	balances, err := s.GetBalances(ctx)
+	assert.NoError(t, err)
+	assert.InDelta(t, 100-50.5*1.01, balances["USDT"].Free, 1e-9)
+	assert.InDelta(t, 0.5, balances["BTC"].Free, 1e-9)
+
+	// Not enough funds left to buy another 0.5 BTC.
+	_, err = s.Buy(ctx, "BTCUSDT", 0.5)
+	assert.ErrorIs(t, err, InsufficientBalanceError)
+
+	// Can't sell more than was bought.
+	_, err = s.Sell(ctx, "BTCUSDT", 0.6)
+	assert.ErrorIs(t, err, InsufficientBalanceError)
+
+	// Sell orders are filled below the price, minus the fee.
+	order, err = s.Sell(ctx, "BTCUSDT", 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), order.OrderID)
+	assert.InDelta(t, 99, order.Price, 1e-9)
+
+	balances, err = s.GetBalances(ctx)
+	assert.NoError(t, err)
+	assert.InDelta(t, 100-50.5*1.01+49.5*0.99, balances["USDT"].Free, 1e-9)
+	assert.NotContains(t, balances, "BTC")
+}
+
+func TestSimulator_rejects_invalid_quantity(t *testing.T) {
+	c := config.Configuration{TradingOptions: config.TradingOptions{PairWith: "USDT"}}
+	s, _ := newSimulatorTest(c, SymbolInfo{Symbol: "BTCUSDT", StepSize: 0.1, MinQuantity: 0.1, MinNotional: 20})
+	ctx := context.Background()
+
+	_, err := s.Buy(ctx, "BTCUSDT", 0.15)
+	assert.ErrorIs(t, err, InvalidQuantityError)
+
+	_, err = s.Buy(ctx, "BTCUSDT", 0.1)
+	assert.ErrorIs(t, err, InvalidQuantityError)
+
+	_, err = s.Buy(ctx, "BTCUSDT", 0.3)
+	assert.NoError(t, err)
+
+	// The balance is unlimited.
+	balances, err := s.GetBalances(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Balance{Asset: "USDT", Free: math.Inf(1)}, balances["USDT"])
+}
+
+func TestSimulator_fill_latency(t *testing.T) {
+	c := config.Configuration{
+		SimulatorOptions: config.SimulatorOptions{FillLatency: 1500},
+		TradingOptions:   config.TradingOptions{PairWith: "USDT"},
+	}
+	s, sim := newSimulatorTest(c, SymbolInfo{Symbol: "BTCUSDT"})
+	start := sim.Now()
+
+	// The order is filled at the price after the latency.
+	order, err := s.Buy(context.Background(), "BTCUSDT", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 200.0, order.Price)
+	assert.Equal(t, start.Add(1500*time.Millisecond), order.TransactionTime)
+}
+
+func TestSimulator_OCO(t *testing.T) {
+	c := config.Configuration{
+		TestModeBalance: 1000,
+		TradingOptions:  config.TradingOptions{PairWith: "USDT", TradingFeeMaker: 1},
+	}
+	s, sim := newSimulatorTest(c, SymbolInfo{Symbol: "BTCUSDT"})
+	ctx := context.Background()
+
+	_, err := s.Buy(ctx, "BTCUSDT", 2)
+	assert.NoError(t, err)
+
+	// The coins are locked until the OCO is filled or cancelled.
+	oco, err := s.PlaceOCO(ctx, "BTCUSDT", 2, 150, 90)
+	assert.NoError(t, err)
+	balances, _ := s.GetBalances(ctx)
+	assert.Equal(t, Balance{Asset: "BTC", Locked: 2}, balances["BTC"])
+
+	_, filled, err := s.GetOCOFill(ctx, oco)
+	assert.NoError(t, err)
+	assert.False(t, filled)
+
+	assert.NoError(t, s.CancelOCO(ctx, oco))
+	balances, _ = s.GetBalances(ctx)
+	assert.Equal(t, Balance{Asset: "BTC", Free: 2}, balances["BTC"])
+	_, _, err = s.GetOCOFill(ctx, oco)
+	assert.ErrorIs(t, err, OCOCanceledError)
+
+	// The take profit is filled once the price reaches it, charging the maker fee.
+	oco, err = s.PlaceOCO(ctx, "BTCUSDT", 2, 150, 90)
+	assert.NoError(t, err)
+	sim.Advance(time.Second)
+
+	order, filled, err := s.GetOCOFill(ctx, oco)
+	assert.NoError(t, err)
+	assert.True(t, filled)
+	assert.Equal(t, oco.TakeProfitOrderID, order.OrderID)
+	assert.Equal(t, 150.0, order.Price)
+	assert.Equal(t, Commissions{"USDT": 3.0}, order.Commissions)
+
+	balances, _ = s.GetBalances(ctx)
+	assert.NotContains(t, balances, "BTC")
+	assert.InDelta(t, 1000-200+297, balances["USDT"].Free, 1e-9)
+}
+
+func TestSimulator_restart(t *testing.T) {
+	c := config.Configuration{
+		TestModeBalance: 1000,
+		TradingOptions:  config.TradingOptions{PairWith: "USDT"},
+	}
+	s, _ := newSimulatorTest(c, SymbolInfo{Symbol: "BTCUSDT"})
+	ctx := context.Background()
+
+	_, err := s.Buy(ctx, "BTCUSDT", 2)
+	assert.NoError(t, err)
+
+	// The wallet of a new simulator is empty, so the coins bought before the restart can't be sold.
+	s, _ = newSimulatorTest(c, SymbolInfo{Symbol: "BTCUSDT"})
+	_, err = s.Sell(ctx, "BTCUSDT", 2)
+	assert.ErrorIs(t, err, InsufficientBalanceError)
+
+	// Unless they're deposited again.
+	s.Deposit("BTC", 2)
+	_, err = s.PlaceOCO(ctx, "BTCUSDT", 1, 150, 90)
+	assert.NoError(t, err)
+	order, err := s.Sell(ctx, "BTCUSDT", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, order.FilledQuantity)
+
+	balances, _ := s.GetBalances(ctx)
+	assert.Equal(t, Balance{Asset: "BTC", Locked: 1}, balances["BTC"])
+}
@@ -0,0 +1,15 @@
+package market
+
+import "time"
+
+// Snapshot holds the state of all coins on a market at a given point in time.
+type Snapshot struct {
+	// The name of the market the coins were fetched from.
+	Market string `json:"market"`
+
+	// The time the coins were fetched.
+	Time time.Time `json:"time"`
+
+	// The coins as returned by Market.GetCoins.
+	Coins Coins `json:"coins"`
+}
@@ -0,0 +1,152 @@
+package optimize
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/spf13/viper"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Values are rounded to this many decimals to avoid floating point artifacts such as 0.30000000000000004.
+const precision = 1e9
+
+// Parameter is a trading option and the values to try for it.
+type Parameter struct {
+	// Name of the trading option as it appears in the config file, e.g. `change_in_price` or `trailing_stop_options.trailing_stop_loss`.
+	Name string
+
+	// Values to try.
+	Values []float64
+}
+
+// ParseParameter parses the values of a trading option.
+// The values can be specified as:
+//   - a range: `3..10 step 1` (the step defaults to 1 if it's omitted)
+//   - a list: `0.5, 1, 2`
+//   - a single value: `5`
+func ParseParameter(name, values string) (Parameter, error) {
+	p := Parameter{Name: name}
+
+	if err := (&config.TradingOptions{}).Set(name, 0); err != nil {
+		return p, err
+	}
+
+	values = strings.TrimSpace(values)
+
+	if from, to, ok := strings.Cut(values, ".."); ok {
+		step := "1"
+		if t, s, ok := strings.Cut(to, "step"); ok {
+			to, step = t, s
+		}
+
+		start, err := parseFloat(from)
+		if err != nil {
+			return p, err
+		}
+		end, err := parseFloat(to)
+		if err != nil {
+			return p, err
+		}
+		increment, err := parseFloat(step)
+		if err != nil {
+			return p, err
+		}
+		if increment <= 0 {
+			return p, fmt.Errorf("%s: step must be positive", name)
+		}
+		if end < start {
+			return p, fmt.Errorf("%s: end of range must not be lower than its start", name)
+		}
+
+		for i := 0; ; i++ {
+			v := math.Round((start+float64(i)*increment)*precision) / precision
+			if v > end {
+				break
+			}
+			p.Values = append(p.Values, v)
+		}
+
+		return p, nil
+	}
+
+	for _, value := range strings.Split(values, ",") {
+		v, err := parseFloat(value)
+		if err != nil {
+			return p, err
+		}
+		p.Values = append(p.Values, v)
+	}
+
+	return p, nil
+}
+
+// LoadParameters loads the parameters to optimize from a YAML file.
+// Each key is the name of a trading option and each value specifies the values to try (see ParseParameter), for example:
+//
+//	change_in_price: 3..10 step 1
+//	take_profit: 0.5, 1, 2
+//	trailing_stop_options:
+//	  trailing_stop_loss: 0.2..0.6 step 0.2
+func LoadParameters(path string) ([]Parameter, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	keys := v.AllKeys()
+	slices.Sort(keys)
+
+	parameters := make([]Parameter, 0, len(keys))
+	for _, key := range keys {
+		p, err := ParseParameter(key, v.GetString(key))
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, p)
+	}
+
+	if len(parameters) == 0 {
+		return nil, errNoParameters
+	}
+
+	return parameters, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+// combinations returns every combination of the values of the given parameters.
+// Each combination contains one value per parameter, in the same order as the parameters.
+func combinations(parameters []Parameter) [][]float64 {
+	result := [][]float64{{}}
+	for _, p := range parameters {
+		var next [][]float64
+		for _, combination := range result {
+			for _, v := range p.Values {
+				c := make([]float64, len(combination), len(combination)+1)
+				copy(c, combination)
+				next = append(next, append(c, v))
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// apply returns a copy of the given configuration with the given values for the given parameters.
+func apply(c config.Configuration, parameters []Parameter, values []float64) (config.Configuration, error) {
+	for i, p := range parameters {
+		if err := c.TradingOptions.Set(p.Name, values[i]); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+var errNoParameters = errors.New("no parameters to optimize")
@@ -0,0 +1,65 @@
+package optimize
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseParameter(t *testing.T) {
+	p, err := ParseParameter("change_in_price", "3..6 step 1")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{3, 4, 5, 6}, p.Values)
+
+	p, err = ParseParameter("take_profit", "0.1..0.5 step 0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 0.2, 0.3, 0.4, 0.5}, p.Values)
+
+	p, err = ParseParameter("max_coins", "1..3")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, 3}, p.Values)
+
+	p, err = ParseParameter("stop_loss", "2, 5,10")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2, 5, 10}, p.Values)
+
+	p, err = ParseParameter("trailing_stop_loss", "0.4")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.4}, p.Values)
+
+	_, err = ParseParameter("unknown", "1")
+	assert.Error(t, err)
+	_, err = ParseParameter("stop_loss", "5..1")
+	assert.Error(t, err)
+	_, err = ParseParameter("stop_loss", "1..5 step 0")
+	assert.Error(t, err)
+	_, err = ParseParameter("stop_loss", "a, b")
+	assert.Error(t, err)
+}
+
+func TestLoadParameters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sweep.yml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+change_in_price: 3..5 step 1
+take_profit: 0.5, 1
+trailing_stop_options:
+  trailing_stop_loss: 0.2
+`), 0o644))
+
+	parameters, err := LoadParameters(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Parameter{
+		{Name: "change_in_price", Values: []float64{3, 4, 5}},
+		{Name: "take_profit", Values: []float64{0.5, 1}},
+		{Name: "trailing_stop_options.trailing_stop_loss", Values: []float64{0.2}},
+	}, parameters)
+}
+
+func TestCombinations(t *testing.T) {
+	c := combinations([]Parameter{
+		{Name: "a", Values: []float64{1, 2}},
+		{Name: "b", Values: []float64{3, 4, 5}},
+	})
+	assert.Equal(t, [][]float64{{1, 3}, {1, 4}, {1, 5}, {2, 3}, {2, 4}, {2, 5}}, c)
+}
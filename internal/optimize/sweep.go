@@ -0,0 +1,140 @@
+package optimize
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/bot"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Result is the outcome of a backtest with one combination of parameter values.
+type Result struct {
+	// The value of each parameter, in the same order as the parameters of the sweep.
+	Values []float64
+
+	// The report of the backtest.
+	Report bot.BacktestReport
+}
+
+// Sweep runs a backtest of the given snapshots for every combination of the values of the given parameters.
+// All other trading options are taken from the given configuration.
+// Backtests are run in parallel on all available CPUs.
+// The results are ranked from best to worst by net profit/loss, Sharpe ratio and max drawdown respectively.
+func Sweep(ctx context.Context, c config.Configuration, snapshots []market.Snapshot, parameters []Parameter) ([]Result, error) {
+	if len(parameters) == 0 {
+		return nil, errNoParameters
+	}
+
+	// Logging thousands of backtests in parallel isn't of any use.
+	c.LoggingOptions.Enable = false
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	combinations := combinations(parameters)
+	results := make([]Result, len(combinations))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for w := 0; w < min(runtime.NumCPU(), len(combinations)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				report, err := backtest(ctx, c, snapshots, parameters, combinations[i])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = Result{Values: combinations[i], Report: report}
+			}
+		}()
+	}
+
+	for i := range combinations {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rank(results)
+
+	return results, nil
+}
+
+func backtest(ctx context.Context, c config.Configuration, snapshots []market.Snapshot, parameters []Parameter, values []float64) (bot.BacktestReport, error) {
+	c, err := apply(c, parameters, values)
+	if err != nil {
+		return bot.BacktestReport{}, err
+	}
+	return bot.Backtest(ctx, c, snapshots)
+}
+
+// rank sorts the given results from best to worst.
+func rank(results []Result) {
+	slices.SortStableFunc(results, func(a, b Result) int {
+		return cmp.Or(
+			cmp.Compare(b.Report.NetProfitLoss, a.Report.NetProfitLoss),
+			cmp.Compare(b.Report.SharpeRatio(), a.Report.SharpeRatio()),
+			cmp.Compare(a.Report.MaxDrawdown, b.Report.MaxDrawdown),
+		)
+	})
+}
+
+// PrintResults writes a table of the given results to the given writer.
+// Only the first limit results are written, or all of them if limit is 0.
+func PrintResults(w io.Writer, parameters []Parameter, results []Result, limit int) error {
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	header := []string{"#"}
+	for _, p := range parameters {
+		header = append(header, strings.ToUpper(p.Name))
+	}
+	header = append(header, "TRADES", "WIN RATE", "NET P/L", "SHARPE", "MAX DRAWDOWN")
+	_, _ = fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+
+	for i, result := range results {
+		row := []string{fmt.Sprint(i + 1)}
+		for _, v := range result.Values {
+			row = append(row, fmt.Sprint(v))
+		}
+		row = append(row,
+			fmt.Sprint(len(result.Report.Trades)),
+			fmt.Sprintf("%.2f%%", result.Report.WinRate()),
+			fmt.Sprintf("$%.2f", result.Report.NetProfitLoss),
+			fmt.Sprintf("%.2f", result.Report.SharpeRatio()),
+			fmt.Sprintf("$%.2f", result.Report.MaxDrawdown),
+		)
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,72 @@
+package optimize
+
+import (
+	"bytes"
+	"context"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// testConfig returns a configuration that checks prices every minute.
+func testConfig() config.Configuration {
+	return config.Configuration{
+		TradingOptions: config.TradingOptions{
+			PairWith:        "USDT",
+			Quantity:        100,
+			TimeDifference:  2,
+			RecheckInterval: 2,
+			SellTimeout:     60,
+			ChangeInPrice:   10,
+			TakeProfit:      5,
+			StopLoss:        50,
+		},
+	}
+}
+
+// testSnapshots returns one snapshot per minute of the given prices of BTCUSDT.
+func testSnapshots(start time.Time, prices ...float64) []market.Snapshot {
+	snapshots := make([]market.Snapshot, len(prices))
+	for i, price := range prices {
+		snapshots[i] = market.Snapshot{
+			Market: "binance",
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			Coins:  market.Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: price}},
+		}
+	}
+	return snapshots
+}
+
+func TestSweep(t *testing.T) {
+	snapshots := testSnapshots(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 100, 110, 115, 120, 125, 130)
+	parameters := []Parameter{
+		{Name: "change_in_price", Values: []float64{5, 20}},
+		{Name: "take_profit", Values: []float64{5, 15}},
+	}
+
+	results, err := Sweep(context.Background(), testConfig(), snapshots, parameters)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(results))
+
+	// The coin is bought at 110 and sold at 120 with a take profit of 5% or at 130 with a take profit of 15%.
+	assert.Equal(t, []float64{5, 15}, results[0].Values)
+	assert.InDelta(t, 20/110.0*100, results[0].Report.NetProfitLoss, 1e-9)
+	assert.Equal(t, []float64{5, 5}, results[1].Values)
+	assert.InDelta(t, 10/110.0*100, results[1].Report.NetProfitLoss, 1e-9)
+
+	// The price never changes enough to buy with a threshold of 20%.
+	assert.Equal(t, 0, len(results[2].Report.Trades))
+	assert.Equal(t, 0, len(results[3].Report.Trades))
+
+	var buf bytes.Buffer
+	assert.NoError(t, PrintResults(&buf, parameters, results, 2))
+	assert.Contains(t, buf.String(), "CHANGE_IN_PRICE")
+	assert.Equal(t, 3, bytes.Count(buf.Bytes(), []byte("\n")))
+}
+
+func TestSweep_without_parameters(t *testing.T) {
+	_, err := Sweep(context.Background(), testConfig(), nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,213 @@
+package optimize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/bot"
+	"github.com/sleeyax/voltra/internal/config"
+	"github.com/sleeyax/voltra/internal/market"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Fold is a single step of a walk-forward analysis.
+// Parameters are optimized on the in-sample period and then tested on the out-of-sample period that immediately follows it.
+type Fold struct {
+	InSampleStart    time.Time
+	OutOfSampleStart time.Time
+	OutOfSampleEnd   time.Time
+
+	// The best parameter values of the in-sample period.
+	Values []float64
+
+	// The report of the best backtest of the in-sample period.
+	InSample bot.BacktestReport
+
+	// The report of the backtest of the out-of-sample period with the best parameter values of the in-sample period.
+	OutOfSample bot.BacktestReport
+}
+
+type WalkForwardReport struct {
+	// The optimized parameters.
+	Parameters []Parameter
+
+	// All folds in chronological order.
+	Folds []Fold
+
+	// The folds that were skipped because their in-sample or out-of-sample period has no snapshots, e.g. due to a gap in the data.
+	// Only their periods are set.
+	SkippedFolds []Fold
+}
+
+// OutOfSampleProfitLoss returns the total net profit/loss of all out-of-sample periods.
+func (r WalkForwardReport) OutOfSampleProfitLoss() float64 {
+	var total float64
+	for _, fold := range r.Folds {
+		total += fold.OutOfSample.NetProfitLoss
+	}
+	return total
+}
+
+// Efficiency returns the out-of-sample profit/loss per unit of time relative to the in-sample profit/loss per unit of time.
+// A value close to 1 means the optimized parameters perform just as well on unseen data, while a value close to or below 0 indicates overfitting.
+// Returns 0 if the in-sample periods didn't make any profit.
+func (r WalkForwardReport) Efficiency() float64 {
+	var inSample, outOfSample float64
+	var inSampleDuration, outOfSampleDuration time.Duration
+	for _, fold := range r.Folds {
+		inSample += fold.InSample.NetProfitLoss
+		outOfSample += fold.OutOfSample.NetProfitLoss
+		inSampleDuration += fold.OutOfSampleStart.Sub(fold.InSampleStart)
+		outOfSampleDuration += fold.OutOfSampleEnd.Sub(fold.OutOfSampleStart)
+	}
+	if inSample <= 0 || inSampleDuration == 0 || outOfSampleDuration == 0 {
+		return 0
+	}
+	return (outOfSample / outOfSampleDuration.Hours()) / (inSample / inSampleDuration.Hours())
+}
+
+// Apply returns a copy of the given configuration with the parameter values of the most recent fold, which are the values that should be traded with next.
+func (r WalkForwardReport) Apply(c config.Configuration) (config.Configuration, error) {
+	if len(r.Folds) == 0 {
+		return c, errors.New("no folds")
+	}
+	return apply(c, r.Parameters, r.Folds[len(r.Folds)-1].Values)
+}
+
+// Print writes a table of all folds and a summary of the out-of-sample results to the given writer.
+func (r WalkForwardReport) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := []string{"IN-SAMPLE", "OUT-OF-SAMPLE"}
+	for _, p := range r.Parameters {
+		header = append(header, strings.ToUpper(p.Name))
+	}
+	header = append(header, "IS P/L", "OOS TRADES", "OOS WIN RATE", "OOS P/L", "OOS MAX DRAWDOWN")
+	_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	var trades, wins int
+	for _, fold := range r.Folds {
+		row := []string{
+			fmt.Sprintf("%s - %s", fold.InSampleStart.Format(time.DateTime), fold.OutOfSampleStart.Format(time.DateTime)),
+			fmt.Sprintf("%s - %s", fold.OutOfSampleStart.Format(time.DateTime), fold.OutOfSampleEnd.Format(time.DateTime)),
+		}
+		for _, v := range fold.Values {
+			row = append(row, fmt.Sprint(v))
+		}
+		row = append(row,
+			fmt.Sprintf("$%.2f", fold.InSample.NetProfitLoss),
+			fmt.Sprint(len(fold.OutOfSample.Trades)),
+			fmt.Sprintf("%.2f%%", fold.OutOfSample.WinRate()),
+			fmt.Sprintf("$%.2f", fold.OutOfSample.NetProfitLoss),
+			fmt.Sprintf("$%.2f", fold.OutOfSample.MaxDrawdown),
+		)
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+
+		trades += len(fold.OutOfSample.Trades)
+		wins += fold.OutOfSample.Wins
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	for _, fold := range r.SkippedFolds {
+		_, _ = fmt.Fprintf(tw, "Warning: skipped %s - %s because there are no snapshots in one of its periods.\n", fold.InSampleStart.Format(time.DateTime), fold.OutOfSampleEnd.Format(time.DateTime))
+	}
+	if len(r.SkippedFolds) > 0 {
+		_, _ = fmt.Fprintln(tw)
+	}
+
+	var winRate float64
+	if trades > 0 {
+		winRate = float64(wins) / float64(trades) * 100
+	}
+	_, _ = fmt.Fprintf(tw, "Folds:\t%d\n", len(r.Folds))
+	_, _ = fmt.Fprintf(tw, "Skipped folds:\t%d\n", len(r.SkippedFolds))
+	_, _ = fmt.Fprintf(tw, "Out-of-sample trades:\t%d\n", trades)
+	_, _ = fmt.Fprintf(tw, "Out-of-sample win rate:\t%.2f%%\n", winRate)
+	_, _ = fmt.Fprintf(tw, "Out-of-sample net P/L:\t$%.2f\n", r.OutOfSampleProfitLoss())
+	_, _ = fmt.Fprintf(tw, "Walk-forward efficiency:\t%.2f\n", r.Efficiency())
+
+	return tw.Flush()
+}
+
+// WalkForward splits the given snapshots into rolling windows of an in-sample period followed by an out-of-sample period.
+// For each window, the parameters are optimized on the in-sample period (see Sweep) and the best values are backtested on the out-of-sample period.
+// Each next window starts one out-of-sample period later than the previous one, so that the out-of-sample periods never overlap.
+// Windows with a period without any snapshots can't be tested and are skipped.
+func WalkForward(ctx context.Context, c config.Configuration, snapshots []market.Snapshot, parameters []Parameter, inSample, outOfSample time.Duration) (WalkForwardReport, error) {
+	if inSample <= 0 || outOfSample <= 0 {
+		return WalkForwardReport{}, errors.New("the in-sample and out-of-sample periods must be positive")
+	}
+	if len(snapshots) == 0 {
+		return WalkForwardReport{}, errors.New("no snapshots to replay")
+	}
+
+	c.LoggingOptions.Enable = false
+
+	sorted := slices.Clone(snapshots)
+	slices.SortStableFunc(sorted, func(a, b market.Snapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+	end := sorted[len(sorted)-1].Time
+
+	report := WalkForwardReport{Parameters: parameters}
+
+	for start := sorted[0].Time; start.Add(inSample).Before(end); start = start.Add(outOfSample) {
+		fold := Fold{
+			InSampleStart:    start,
+			OutOfSampleStart: start.Add(inSample),
+			OutOfSampleEnd:   start.Add(inSample + outOfSample),
+		}
+		if fold.OutOfSampleEnd.After(end) {
+			fold.OutOfSampleEnd = end
+		}
+
+		inSampleSnapshots := between(sorted, fold.InSampleStart, fold.OutOfSampleStart)
+		outOfSampleSnapshots := between(sorted, fold.OutOfSampleStart, fold.OutOfSampleEnd)
+		if len(inSampleSnapshots) == 0 || len(outOfSampleSnapshots) == 0 {
+			report.SkippedFolds = append(report.SkippedFolds, fold)
+			continue
+		}
+
+		results, err := Sweep(ctx, c, inSampleSnapshots, parameters)
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+		fold.Values = results[0].Values
+		fold.InSample = results[0].Report
+
+		fold.OutOfSample, err = backtest(ctx, c, outOfSampleSnapshots, parameters, fold.Values)
+		if err != nil {
+			return WalkForwardReport{}, err
+		}
+
+		report.Folds = append(report.Folds, fold)
+	}
+
+	if len(report.Folds) == 0 && len(report.SkippedFolds) > 0 {
+		return WalkForwardReport{}, fmt.Errorf("all %d folds were skipped because of gaps in the snapshots", len(report.SkippedFolds))
+	}
+	if len(report.Folds) == 0 {
+		return WalkForwardReport{}, fmt.Errorf("the snapshots span %s, which is too short for an in-sample period of %s", end.Sub(sorted[0].Time), inSample)
+	}
+
+	return report, nil
+}
+
+// between returns the snapshots within the time range [from, to).
+// The snapshot at the end of the last range is included as well.
+func between(sorted []market.Snapshot, from, to time.Time) []market.Snapshot {
+	i, _ := slices.BinarySearchFunc(sorted, from, func(s market.Snapshot, t time.Time) int {
+		return s.Time.Compare(t)
+	})
+	j, found := slices.BinarySearchFunc(sorted, to, func(s market.Snapshot, t time.Time) int {
+		return s.Time.Compare(t)
+	})
+	if found && j == len(sorted)-1 {
+		j++
+	}
+	return sorted[i:j]
+}
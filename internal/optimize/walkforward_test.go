@@ -0,0 +1,82 @@
+package optimize
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWalkForward(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The same pattern repeats every 6 minutes: a volatile rise after which the price keeps rising.
+	var prices []float64
+	for i := 0; i < 4; i++ {
+		prices = append(prices, 100, 110, 115, 120, 125, 130)
+	}
+	snapshots := testSnapshots(start, prices...)
+	parameters := []Parameter{
+		{Name: "take_profit", Values: []float64{5, 15}},
+	}
+
+	report, err := WalkForward(context.Background(), testConfig(), snapshots, parameters, 12*time.Minute, 6*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report.Folds))
+
+	assert.Equal(t, start, report.Folds[0].InSampleStart)
+	assert.Equal(t, start.Add(12*time.Minute), report.Folds[0].OutOfSampleStart)
+	assert.Equal(t, start.Add(18*time.Minute), report.Folds[0].OutOfSampleEnd)
+	assert.Equal(t, start.Add(6*time.Minute), report.Folds[1].InSampleStart)
+	assert.Equal(t, start.Add(23*time.Minute), report.Folds[1].OutOfSampleEnd)
+
+	for _, fold := range report.Folds {
+		assert.Equal(t, []float64{15}, fold.Values)
+		assert.Equal(t, 1, len(fold.OutOfSample.Trades))
+	}
+	assert.InDelta(t, 2*20/110.0*100, report.OutOfSampleProfitLoss(), 1e-9)
+	// The last out-of-sample period is cut short by the end of the snapshots, so it earns the same in less time.
+	assert.InDelta(t, 24/22.0, report.Efficiency(), 1e-9)
+
+	c, err := report.Apply(testConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 15.0, c.TradingOptions.TakeProfit)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Print(&buf))
+	assert.Contains(t, buf.String(), "Walk-forward efficiency:  1.09")
+}
+
+func TestWalkForward_gap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Nothing was recorded between the 12th and the 18th minute.
+	var prices []float64
+	for i := 0; i < 3; i++ {
+		prices = append(prices, 100, 110, 115, 120, 125, 130)
+	}
+	snapshots := testSnapshots(start, prices...)
+	for i := 12; i < len(snapshots); i++ {
+		snapshots[i].Time = snapshots[i].Time.Add(6 * time.Minute)
+	}
+	parameters := []Parameter{{Name: "take_profit", Values: []float64{5, 15}}}
+
+	report, err := WalkForward(context.Background(), testConfig(), snapshots, parameters, 12*time.Minute, 6*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.Folds))
+	assert.Equal(t, start.Add(6*time.Minute), report.Folds[0].InSampleStart)
+	assert.Equal(t, 1, len(report.SkippedFolds))
+	assert.Equal(t, start, report.SkippedFolds[0].InSampleStart)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Print(&buf))
+	assert.Contains(t, buf.String(), "Warning: skipped 2024-01-01 00:00:00 - 2024-01-01 00:18:00")
+	assert.Contains(t, buf.String(), "Skipped folds:            1")
+}
+
+func TestWalkForward_too_short(t *testing.T) {
+	snapshots := testSnapshots(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 100, 110)
+	_, err := WalkForward(context.Background(), testConfig(), snapshots, []Parameter{{Name: "take_profit", Values: []float64{5}}}, time.Hour, time.Hour)
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package recorder
+
+import (
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/market"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Ensures Market implements the market.Market interface.
+var _ market.Market = (*Market)(nil)
+
+// Market wraps another market and records the results of GetCoins, at most one per interval.
+// The bot fetches the coins from several loops, so a result is skipped if the previous one was recorded less than an interval ago.
+// The most recent result of GetCoinsVolume is merged into each snapshot, so that recordings contain the same data the bot sees.
+type Market struct {
+	market.Market
+	writer   *Writer
+	clock    clock.Clock
+	interval time.Duration
+	onError  func(err error)
+
+	mu           sync.Mutex
+	volumes      market.TradeVolumes
+	lastRecorded time.Time
+}
+
+// NewMarket creates a new market that records the coins of m to w, at most once per given interval of the given clock.
+// Recording is best-effort: failures to write a snapshot are passed to onError and never interrupt trading.
+func NewMarket(m market.Market, w *Writer, clock clock.Clock, interval time.Duration, onError func(err error)) *Market {
+	return &Market{Market: m, writer: w, clock: clock, interval: interval, onError: onError}
+}
+
+func (m *Market) GetCoins(ctx context.Context) (market.Coins, error) {
+	coins, err := m.Market.GetCoins(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.clock.Now()
+	m.mu.Lock()
+	if !m.lastRecorded.IsZero() && now.Sub(m.lastRecorded) < m.interval {
+		m.mu.Unlock()
+		return coins, nil
+	}
+	m.lastRecorded = now
+	volumes := m.volumes
+	m.mu.Unlock()
+
+	recorded := maps.Clone(coins)
+	snapshotTime := time.Time{}
+	for symbol, coin := range recorded {
+		if volume, ok := volumes[symbol]; ok && coin.QuoteVolumeTraded == 0 {
+			coin.QuoteVolumeTraded = volume
+			recorded[symbol] = coin
+		}
+		if coin.Time.After(snapshotTime) {
+			snapshotTime = coin.Time
+		}
+	}
+	if snapshotTime.IsZero() {
+		snapshotTime = now
+	}
+
+	if err = m.writer.Write(market.Snapshot{Market: m.Name(), Time: snapshotTime, Coins: recorded}); err != nil && m.onError != nil {
+		m.onError(err)
+	}
+
+	return coins, nil
+}
+
+func (m *Market) GetCoinsVolume(ctx context.Context) (market.TradeVolumes, error) {
+	volumes, err := m.Market.GetCoinsVolume(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.volumes = volumes
+	m.mu.Unlock()
+
+	return volumes, nil
+}
@@ -0,0 +1,99 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"github.com/sleeyax/voltra/internal/clock"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockMarket struct {
+	market.Market
+	coins market.Coins
+}
+
+func (m *mockMarket) Name() string {
+	return "mock market"
+}
+
+func (m *mockMarket) GetCoins(_ context.Context) (market.Coins, error) {
+	return m.coins, nil
+}
+
+func (m *mockMarket) GetCoinsVolume(_ context.Context) (market.TradeVolumes, error) {
+	return market.TradeVolumes{"BTCUSDT": 5_000}, nil
+}
+
+func TestMarket_GetCoins(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &mockMarket{coins: market.Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: 42_000, Time: now}}}
+
+	clk := clock.NewSimulated(now)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	rm := NewMarket(m, w, clk, 10*time.Second, func(err error) {
+		t.Fatal(err)
+	})
+
+	_, err := rm.GetCoins(context.Background())
+	assert.NoError(t, err)
+	_, err = rm.GetCoinsVolume(context.Background())
+	assert.NoError(t, err)
+	clk.Advance(10 * time.Second)
+	coins, err := rm.GetCoins(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	// The coins returned to the bot are never altered.
+	assert.Equal(t, 0.0, coins["BTCUSDT"].QuoteVolumeTraded)
+
+	r, err := NewReader(&buf)
+	assert.NoError(t, err)
+
+	snapshot, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "mock market", snapshot.Market)
+	assert.Equal(t, now, snapshot.Time)
+	assert.Equal(t, 0.0, snapshot.Coins["BTCUSDT"].QuoteVolumeTraded)
+
+	snapshot, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 5_000.0, snapshot.Coins["BTCUSDT"].QuoteVolumeTraded)
+}
+
+func TestMarket_GetCoins_interval(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &mockMarket{coins: market.Coins{"BTCUSDT": {Symbol: "BTCUSDT", Price: 42_000}}}
+
+	clk := clock.NewSimulated(start)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	rm := NewMarket(m, w, clk, 10*time.Second, func(err error) {
+		t.Fatal(err)
+	})
+
+	// The coins are still returned when they aren't recorded.
+	for _, d := range []time.Duration{0, 5 * time.Second, 5 * time.Second, 3 * time.Second} {
+		clk.Advance(d)
+		coins, err := rm.GetCoins(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(coins))
+	}
+	assert.NoError(t, w.Close())
+
+	r, err := NewReader(&buf)
+	assert.NoError(t, err)
+
+	// Snapshots without the time of the coins are timed by the clock.
+	snapshot, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, start, snapshot.Time)
+	snapshot, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(10*time.Second), snapshot.Time)
+	_, err = r.Next()
+	assert.Error(t, err)
+}
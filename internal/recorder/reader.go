@@ -0,0 +1,92 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"github.com/sleeyax/voltra/internal/market"
+	"io"
+	"os"
+	"time"
+)
+
+// Reader streams snapshots from a recording.
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+// NewReader creates a new reader that reads a recording from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(gz)
+	// A single snapshot contains the price of every coin on the market, so lines can get long.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	return &Reader{scanner: scanner}, nil
+}
+
+// Next returns the next snapshot of the recording.
+// It returns io.EOF when there are no more snapshots.
+func (r *Reader) Next() (market.Snapshot, error) {
+	if !r.scanner.Scan() {
+		err := r.scanner.Err()
+		// Recordings of sessions that were killed are never properly closed.
+		// All flushed snapshots can still be read, so we treat the missing end of the stream as the end of the recording.
+		if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+			return market.Snapshot{}, io.EOF
+		}
+		return market.Snapshot{}, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(r.scanner.Bytes(), &rec); err != nil {
+		return market.Snapshot{}, err
+	}
+
+	snapshot := market.Snapshot{
+		Market: rec.Market,
+		Time:   time.UnixMilli(rec.Time).UTC(),
+		Coins:  make(market.Coins, len(rec.Prices)),
+	}
+	for symbol, price := range rec.Prices {
+		snapshot.Coins[symbol] = market.Coin{
+			Symbol:            symbol,
+			Price:             price,
+			QuoteVolumeTraded: rec.Volumes[symbol],
+			Time:              snapshot.Time,
+		}
+	}
+
+	return snapshot, nil
+}
+
+// ReadFile reads all snapshots from the recording at the given path.
+func ReadFile(path string) ([]market.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []market.Snapshot
+	for {
+		snapshot, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+}
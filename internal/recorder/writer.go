@@ -0,0 +1,119 @@
+package recorder
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/sleeyax/voltra/internal/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Directory within storage.DataPath where recordings are stored.
+const recordingsDirectory = "recordings"
+
+// FileExtension is the file extension of recordings.
+const FileExtension = ".jsonl.gz"
+
+// record is the compact on-disk representation of a market.Snapshot.
+// Each record is stored as a single line of JSON in a gzip compressed file.
+type record struct {
+	// Unix time in milliseconds.
+	Time int64 `json:"t"`
+
+	// Name of the market.
+	Market string `json:"m"`
+
+	// Price per symbol.
+	Prices map[string]float64 `json:"p"`
+
+	// 24h quote volume traded per symbol, omitted when unknown.
+	Volumes map[string]float64 `json:"v,omitempty"`
+}
+
+// Writer writes snapshots to a recording.
+// It's safe for concurrent use.
+type Writer struct {
+	mu     sync.Mutex
+	closer io.Closer
+	gzip   *gzip.Writer
+}
+
+// NewWriter creates a new writer that writes a recording to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{gzip: gzip.NewWriter(w)}
+}
+
+// Create creates a new recording file for the given market in the data directory.
+// The name of the file contains the given start time, so that each session is recorded to its own file.
+func Create(marketName string, start time.Time) (*Writer, string, error) {
+	dir := filepath.Join(storage.DataPath, recordingsDirectory)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s%s", marketName, start.UTC().Format("20060102T150405"), FileExtension))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	w := NewWriter(f)
+	w.closer = f
+
+	return w, path, nil
+}
+
+// Write appends the given snapshot to the recording.
+// The snapshot is flushed immediately, so that it's not lost when the process is killed.
+func (w *Writer) Write(snapshot market.Snapshot) error {
+	r := record{
+		Time:   snapshot.Time.UnixMilli(),
+		Market: snapshot.Market,
+		Prices: make(map[string]float64, len(snapshot.Coins)),
+	}
+	for symbol, coin := range snapshot.Coins {
+		r.Prices[symbol] = coin.Price
+		if coin.QuoteVolumeTraded != 0 {
+			if r.Volumes == nil {
+				r.Volumes = make(map[string]float64)
+			}
+			r.Volumes[symbol] = coin.QuoteVolumeTraded
+		}
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err = w.gzip.Write(line); err != nil {
+		return err
+	}
+
+	return w.gzip.Flush()
+}
+
+// Close finishes the recording.
+// If the writer was created with Create, the underlying file is closed as well.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.gzip.Close()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
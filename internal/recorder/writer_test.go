@@ -0,0 +1,64 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"github.com/sleeyax/voltra/internal/market"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := []market.Snapshot{
+		{
+			Market: "binance",
+			Time:   start,
+			Coins: market.Coins{
+				"BTCUSDT": {Symbol: "BTCUSDT", Price: 42_000.12, QuoteVolumeTraded: 1_000_000, Time: start},
+				"ETHUSDT": {Symbol: "ETHUSDT", Price: 2_500.5, Time: start},
+			},
+		},
+		{
+			Market: "binance",
+			Time:   start.Add(time.Minute),
+			Coins: market.Coins{
+				"BTCUSDT": {Symbol: "BTCUSDT", Price: 42_100, Time: start.Add(time.Minute)},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, snapshot := range snapshots {
+		assert.NoError(t, w.Write(snapshot))
+	}
+	assert.NoError(t, w.Close())
+
+	r, err := NewReader(&buf)
+	assert.NoError(t, err)
+	for _, expected := range snapshots {
+		actual, err := r.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	_, err = r.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestWriter_not_closed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.NoError(t, w.Write(market.Snapshot{Market: "binance", Time: time.Now(), Coins: market.Coins{"BTCUSDT": {Price: 1}}}))
+
+	// Without closing the writer, the snapshot must still be readable.
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	snapshot, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, snapshot.Coins["BTCUSDT"].Price)
+	_, err = r.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+}
@@ -1,6 +1,14 @@
 package utils
 
-import "math"
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// StepSizeTolerance is the fraction of a step that is tolerated when dividing a quantity by a step size.
+// It only compensates for floating point errors, e.g. 0.3 / 0.1 = 2.9999999999999996.
+const StepSizeTolerance = 1e-9
 
 // RoundStepSize rounds a given quantity to a specific step size.
 //
@@ -12,9 +20,25 @@ import "math"
 //
 //	The rounded quantity.
 func RoundStepSize(quantity, stepSize float64) float64 {
-	// Calculate the precision required for rounding.
-	precision := int(math.Round(-math.Log10(stepSize)))
+	return roundDecimals(math.Round(quantity/stepSize)*stepSize, stepSize)
+}
+
+// FloorStepSize rounds a given quantity down to a specific step size.
+// Unlike RoundStepSize, the result never exceeds the given quantity, which makes it suitable for quantities that are held and can't be rounded up.
+// Floating point errors are compensated for, e.g. 0.3 isn't rounded down to 0.2 with a step size of 0.1.
+func FloorStepSize(quantity, stepSize float64) float64 {
+	return roundDecimals(math.Floor(quantity/stepSize+StepSizeTolerance)*stepSize, stepSize)
+}
+
+// roundDecimals rounds the given multiple of the given step size to the number of decimals of the step size.
+// This removes the floating point errors of the multiplication, e.g. 3 * 0.1 = 0.30000000000000004.
+func roundDecimals(value, stepSize float64) float64 {
+	s := strconv.FormatFloat(stepSize, 'f', -1, 64)
+	var decimals int
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		decimals = len(s) - i - 1
+	}
 
-	// Round the quantity to the calculated precision.
-	return math.Round(quantity*math.Pow(10, float64(precision))) / math.Pow(10, float64(precision))
+	precision := math.Pow(10, float64(decimals))
+	return math.Round(value*precision) / precision
 }
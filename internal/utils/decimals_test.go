@@ -9,4 +9,17 @@ func TestRoundStepSize(t *testing.T) {
 	assert.Equal(t, 1.1, RoundStepSize(1.1, 0.01))
 	assert.Equal(t, 0.2, RoundStepSize(0.2, 0.01))
 	assert.Equal(t, 26.0, RoundStepSize(25.9, 1.0))
+	assert.Equal(t, 1.5, RoundStepSize(1.4, 0.5))
+	assert.Equal(t, 0.75, RoundStepSize(0.8, 0.25))
+}
+
+func TestFloorStepSize(t *testing.T) {
+	assert.Equal(t, 1.1, FloorStepSize(1.1, 0.01))
+	assert.Equal(t, 0.3, FloorStepSize(0.3, 0.1))
+	assert.Equal(t, 9.99, FloorStepSize(9.999, 0.01))
+	assert.Equal(t, 25.0, FloorStepSize(25.9, 1.0))
+	assert.Equal(t, 1.0, FloorStepSize(1.4, 0.5))
+	assert.Equal(t, 0.75, FloorStepSize(0.99, 0.25))
+	assert.Equal(t, 0.3, FloorStepSize(0.3, 0.3))
+	assert.Equal(t, 20.0, FloorStepSize(29, 10))
 }